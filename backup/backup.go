@@ -33,6 +33,7 @@ func DoInit(cmd *cobra.Command) {
 	_ = cmd.MarkFlagRequired(options.DBNAME)
 	utils.InitializeSignalHandler(DoCleanup, "backup process", &wasTerminated)
 	objectCounts = make(map[string]int)
+	reportTimings = report.NewTimings()
 }
 
 func DoFlagValidation(cmd *cobra.Command) {
@@ -48,6 +49,9 @@ func DoSetup() {
 
 	utils.CheckGpexpandRunning(utils.BackupPreventedByGpexpandMessage)
 	timestamp := history.CurrentTimestamp()
+	if MustGetFlagString(options.RESUME) != "" {
+		timestamp = MustGetFlagString(options.RESUME)
+	}
 	createBackupLockFile(timestamp)
 	initializeConnectionPool(timestamp)
 	gplog.Info("Cloudberry Database Version = %s", connectionPool.Version.VersionString)
@@ -93,8 +97,29 @@ func DoSetup() {
 		gplog.Debug("Plugin config path: %s", pluginConfig.ConfigPath)
 	}
 
+	if MustGetFlagString(options.ENCRYPTION_KEY_FILE) != "" {
+		initializeEncryption()
+	}
+
+	if MustGetFlagString(options.HOOK_CONFIG) != "" {
+		hookConfig, err = utils.ReadHookConfig(MustGetFlagString(options.HOOK_CONFIG))
+		gplog.FatalOnError(err)
+	}
+
+	if MustGetFlagString(options.ROW_FILTER_FILE) != "" {
+		initializeRowFilters()
+	}
+
+	if MustGetFlagString(options.MASKING_RULES_FILE) != "" {
+		initializeMaskingRules()
+	}
+
 	initializeBackupReport(*opts)
 
+	if MustGetFlagString(options.RESUME) != "" {
+		prepareToResumeBackup()
+	}
+
 	if pluginConfigFlag != "" {
 		backupReport.PluginVersion = pluginConfig.CheckPluginExistsOnAllHosts(globalCluster)
 		pluginConfig.CopyPluginConfigToAllHosts(globalCluster)
@@ -126,13 +151,19 @@ func DoBackup() {
 	gplog.Info("Gathering table state information")
 	metadataTables, dataTables := RetrieveAndProcessTables()
 	dataTables, numExtOrForeignTables := GetBackupDataSet(dataTables)
+	ValidateRowFilters(dataTables)
+	ValidateMaskingRules(dataTables)
+	if MustGetFlagFloat64(options.SAMPLE_PERCENT) > 0 {
+		initializeSampling(dataTables)
+	}
+	ValidateExternalPartitions(metadataTables, dataTables)
 	if len(dataTables) == 0 {
 		gplog.Warn("No tables in backup set contain data. Performing metadata-only backup instead.")
 		backupReport.MetadataOnly = true
 	}
 	// This must be a full backup with --leaf-parition-data to query for incremental metadata
 	if !(MustGetFlagBool(options.METADATA_ONLY) || MustGetFlagBool(options.DATA_ONLY)) && MustGetFlagBool(options.LEAF_PARTITION_DATA) {
-		backupIncrementalMetadata()
+		backupIncrementalMetadata(dataTables)
 	} else {
 		gplog.Verbose("Skipping query for incremental metadata.")
 	}
@@ -167,7 +198,7 @@ func DoBackup() {
 
 			targetBackupTOC := toc.NewTOC(targetBackupFPInfo.GetTOCFilePath())
 			targetBackupRestorePlan = history.ReadConfigFile(targetBackupFPInfo.GetConfigFilePath()).RestorePlan
-			backupSetTables = FilterTablesForIncremental(targetBackupTOC, globalTOC, dataTables)
+			backupSetTables = FilterTablesForIncremental(targetBackupTOC, globalTOC, heapAfterSnapshot, dataTables)
 		}
 
 		backupReport.RestorePlan = PopulateRestorePlan(backupSetTables, targetBackupRestorePlan, dataTables)
@@ -188,6 +219,13 @@ func DoBackup() {
 		}
 	}
 	metadataFile.Close()
+	if utils.GetEncryptionKey() != nil {
+		mustEncryptBackupFile(metadataFilename)
+		mustEncryptBackupFile(globalFPInfo.GetTOCFilePath())
+		if MustGetFlagBool(options.WITH_STATS) {
+			mustEncryptBackupFile(globalFPInfo.GetStatisticsFilePath())
+		}
+	}
 	if pluginConfigFlag != "" {
 		pluginConfig.MustBackupFile(metadataFilename)
 		pluginConfig.MustBackupFile(globalFPInfo.GetTOCFilePath())
@@ -201,6 +239,8 @@ func DoBackup() {
 
 func backupGlobals(metadataFile *utils.FileWithByteCount) {
 	gplog.Info("Writing global database metadata")
+	runHooks("before", "globals")
+	defer reportTimings.StartSection("globals")()
 
 	backupResourceQueues(metadataFile)
 	backupResourceGroups(metadataFile)
@@ -212,6 +252,7 @@ func backupGlobals(metadataFile *utils.FileWithByteCount) {
 	backupRoleGUCs(metadataFile)
 
 	logCompletionMessage("Global database metadata backup")
+	runHooks("after", "globals")
 }
 
 func backupPredata(metadataFile *utils.FileWithByteCount, tables []Table, tableOnly bool) {
@@ -219,6 +260,8 @@ func backupPredata(metadataFile *utils.FileWithByteCount, tables []Table, tableO
 		return
 	}
 	gplog.Info("Writing pre-data metadata")
+	runHooks("before", "predata")
+	defer reportTimings.StartSection("predata")()
 
 	var protocols []ExternalProtocol
 	var functions []Function
@@ -263,15 +306,21 @@ func backupPredata(metadataFile *utils.FileWithByteCount, tables []Table, tableO
 	backupConversions(metadataFile)
 
 	logCompletionMessage("Pre-data metadata metadata backup")
+	runHooks("after", "predata")
 }
 
 func backupData(tables []Table) {
+	runHooks("before", "data")
 	if len(tables) == 0 {
 		// No incremental data changes to backup
 		gplog.Info("No tables to backup")
 		gplog.Info("Data backup complete")
+		runHooks("after", "data")
 		return
 	}
+	defer reportTimings.StartSection("data")()
+	tableSizes := GetTableSizeEstimates(connectionPool, tables)
+	tables = SortTablesBySize(tables, tableSizes)
 	if MustGetFlagBool(options.SINGLE_DATA_FILE) {
 		gplog.Verbose("Initializing pipes and gpbackup_helper on segments for single data file backup")
 		utils.VerifyHelperVersionOnSegments(version, globalCluster)
@@ -283,19 +332,35 @@ func backupData(tables []Table) {
 		compressStr := fmt.Sprintf(" --compression-level %d --compression-type %s", MustGetFlagInt(options.COMPRESSION_LEVEL), MustGetFlagString(options.COMPRESSION_TYPE))
 		if MustGetFlagBool(options.NO_COMPRESSION) {
 			compressStr = " --compression-level 0"
+		} else if MustGetFlagInt(options.COMPRESSION_WORKERS) > 1 {
+			compressStr += fmt.Sprintf(" --compression-workers %d", MustGetFlagInt(options.COMPRESSION_WORKERS))
 		}
 		initialPipes := CreateInitialSegmentPipes(oidList, globalCluster, connectionPool, globalFPInfo)
 		// Do not pass through the --on-error-continue flag or the resizeClusterMap because neither apply to gpbackup
 		utils.StartGpbackupHelpers(globalCluster, globalFPInfo, "--backup-agent",
-			MustGetFlagString(options.PLUGIN_CONFIG), compressStr, false, false, &wasTerminated, initialPipes, true, false, 0, 0)
+			MustGetFlagString(options.PLUGIN_CONFIG), compressStr, false, false, &wasTerminated, initialPipes, true, MustGetFlagBool(options.VERIFY), MustGetFlagString(options.ENCRYPTION_KEY_FILE), false, 0, 0)
+	}
+	remainingTables := tables
+	var completedRowsCopied map[uint32]int64
+	if MustGetFlagString(options.RESUME) != "" {
+		remainingTables, completedRowsCopied = resumeDataBackup(tables)
+	}
+	if !MustGetFlagBool(options.SINGLE_DATA_FILE) {
+		openDataProgressFile()
+		defer closeDataProgressFile()
 	}
 	gplog.Info("Writing data to file")
-	rowsCopiedMaps := backupDataForAllTables(tables)
-	AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
+	rowsCopiedMaps := backupDataForAllTables(remainingTables)
+	if completedRowsCopied != nil {
+		rowsCopiedMaps = append(rowsCopiedMaps, completedRowsCopied)
+	}
+	AddTableDataEntriesToTOC(tables, rowsCopiedMaps, tableSizes)
+	reportTimings.SetEstimatedBytes(tableSizes)
 	if MustGetFlagBool(options.SINGLE_DATA_FILE) && MustGetFlagString(options.PLUGIN_CONFIG) != "" {
 		pluginConfig.BackupSegmentTOCs(globalCluster, globalFPInfo)
 	}
 	logCompletionMessage("Data backup")
+	runHooks("after", "data")
 }
 
 func backupPostdata(metadataFile *utils.FileWithByteCount) {
@@ -303,6 +368,8 @@ func backupPostdata(metadataFile *utils.FileWithByteCount) {
 		return
 	}
 	gplog.Info("Writing post-data metadata")
+	runHooks("before", "postdata")
+	defer reportTimings.StartSection("postdata")()
 
 	backupIndexes(metadataFile)
 	backupRules(metadataFile)
@@ -315,6 +382,7 @@ func backupPostdata(metadataFile *utils.FileWithByteCount) {
 	backupExtendedStatistic(metadataFile)
 
 	logCompletionMessage("Post-data metadata backup")
+	runHooks("after", "postdata")
 }
 
 func backupStatistics(tables []Table) {
@@ -323,11 +391,14 @@ func backupStatistics(tables []Table) {
 	}
 	statisticsFilename := globalFPInfo.GetStatisticsFilePath()
 	gplog.Info("Writing query planner statistics to %s", statisticsFilename)
+	runHooks("before", "statistics")
+	defer reportTimings.StartSection("statistics")()
 	statisticsFile := utils.NewFileWithByteCountFromFile(statisticsFilename)
 	defer statisticsFile.Close()
 	backupTableStatistics(statisticsFile, tables)
 
 	logCompletionMessage("Query planner statistics backup")
+	runHooks("after", "statistics")
 }
 
 func DoTeardown() {
@@ -380,6 +451,7 @@ func DoTeardown() {
 		}
 		historyFilename := globalFPInfo.GetBackupHistoryFilePath()
 		reportFilename := globalFPInfo.GetBackupReportFilePath()
+		jsonReportFilename := globalFPInfo.GetBackupJSONReportFilePath()
 		configFilename := globalFPInfo.GetConfigFilePath()
 
 		time.Sleep(time.Second) // We sleep for 1 second to ensure multiple backups do not start within the same second.
@@ -395,12 +467,29 @@ func DoTeardown() {
 				gplog.Error(fmt.Sprintf("%v", err))
 			}
 			history.WriteConfigFile(&backupReport.BackupConfig, configFilename)
+			if utils.GetEncryptionKey() != nil {
+				err = utils.EncryptFile(configFilename, utils.GetEncryptionKey())
+				if err != nil {
+					gplog.Error(fmt.Sprintf("%v", err))
+				}
+			}
 			if backupReport.BackupConfig.EndTime == "" {
 				backupReport.BackupConfig.EndTime = history.CurrentTimestamp()
 			}
 			endtime, _ := time.ParseInLocation("20060102150405", backupReport.BackupConfig.EndTime, operating.System.Local)
 			backupReport.WriteBackupReportFile(reportFilename, globalFPInfo.Timestamp, endtime, objectCounts, errMsg)
+			jsonReport := backupReport.NewBackupJSONReport(globalFPInfo.Timestamp, endtime, objectCounts, errMsg, reportTimings)
+			report.WriteJSONReportFiles(jsonReport, jsonReportFilename, MustGetFlagString(options.REPORT_JSON_FILE))
+			if metricsFilename := MustGetFlagString(options.METRICS_FILE); metricsFilename != "" {
+				err = report.WriteMetricsFile(metricsFilename, utils.UnquoteIdent(backupReport.DatabaseName), globalFPInfo.Timestamp, endtime, jsonReport)
+				if err != nil {
+					gplog.Error(fmt.Sprintf("%v", err))
+				}
+			}
 			report.EmailReport(globalCluster, globalFPInfo.Timestamp, reportFilename, "gpbackup", !backupFailed)
+			if notificationConfig := MustGetFlagString(options.NOTIFICATION_CONFIG); notificationConfig != "" {
+				report.SendNotifications(notificationConfig, "gpbackup", reportFilename, jsonReport)
+			}
 			if pluginConfig != nil {
 				err = pluginConfig.BackupFile(configFilename)
 				if err != nil {
@@ -412,8 +501,17 @@ func DoTeardown() {
 					gplog.Error(fmt.Sprintf("%v", err))
 					return
 				}
+				err = pluginConfig.BackupFile(jsonReportFilename)
+				if err != nil {
+					gplog.Error(fmt.Sprintf("%v", err))
+					return
+				}
 			}
 		}
+		if !backupFailed {
+			removeDataProgressFile()
+			pruneExpiredBackups(historyFilename)
+		}
 		if pluginConfig != nil {
 			pluginConfig.CleanupPluginForBackup(globalCluster, globalFPInfo)
 			pluginConfig.DeletePluginConfigWhenEncrypting(globalCluster)
@@ -434,6 +532,12 @@ func DoCleanup(backupFailed bool) {
 	}()
 
 	gplog.Verbose("Beginning cleanup")
+	if backupFailed && globalFPInfo.Timestamp != "" {
+		err := hookConfig.RunHooks(getHookContext(), "failure", "")
+		if err != nil {
+			gplog.Warn("%v", err)
+		}
+	}
 	if connectionPool != nil {
 		cancelBlockedQueries(globalFPInfo.Timestamp)
 	}
@@ -459,6 +563,23 @@ func DoCleanup(backupFailed bool) {
 	}
 }
 
+func getHookContext() utils.HookContext {
+	return utils.HookContext{
+		Utility:   "gpbackup",
+		Timestamp: globalFPInfo.Timestamp,
+		Database:  MustGetFlagString(options.DBNAME),
+		BackupDir: globalFPInfo.GetDirForContent(-1),
+	}
+}
+
+func runHooks(event string, phase string) {
+	if hookConfig == nil {
+		return
+	}
+	err := hookConfig.RunHooks(getHookContext(), event, phase)
+	gplog.FatalOnError(err)
+}
+
 // Cancel blocked gpbackup queries waiting for locks.
 func cancelBlockedQueries(timestamp string) {
 	conn := dbconn.NewDBConnFromEnvironment(MustGetFlagString(options.DBNAME))
@@ -7,6 +7,7 @@ package backup
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
 	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
 	"github.com/cloudberrydb/gpbackup/options"
 	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/jackc/pgconn"
@@ -40,7 +42,31 @@ func ConstructTableAttributesList(columnDefs []ColumnDefinition) string {
 	return ""
 }
 
-func AddTableDataEntriesToTOC(tables []Table, rowsCopiedMaps []map[uint32]int64) {
+/*
+ * Selects the same columns as ConstructTableAttributesList, in the same order,
+ * replacing each masked column with the projection of its masking rule, so the
+ * masked data is restored with the attribute list in the TOC like any other.
+ */
+func ConstructMaskedSelectList(columnDefs []ColumnDefinition, columnRules map[string]utils.MaskingRule) string {
+	rulesByName := make(map[string]utils.MaskingRule, len(columnRules))
+	for column, rule := range columnRules {
+		rulesByName[utils.UnquoteIdent(column)] = rule
+	}
+	projections := make([]string, 0)
+	for _, col := range columnDefs {
+		if col.AttGenerated != "" {
+			continue
+		}
+		if rule, ok := rulesByName[utils.UnquoteIdent(col.Name)]; ok {
+			projections = append(projections, rule.Projection(col.Name))
+		} else {
+			projections = append(projections, col.Name)
+		}
+	}
+	return strings.Join(projections, ",")
+}
+
+func AddTableDataEntriesToTOC(tables []Table, rowsCopiedMaps []map[uint32]int64, tableSizes map[uint32]int64) {
 	for _, table := range tables {
 		if !table.SkipDataBackup() {
 			var rowsCopied int64
@@ -51,11 +77,27 @@ func AddTableDataEntriesToTOC(tables []Table, rowsCopiedMaps []map[uint32]int64)
 				}
 			}
 			attributes := ConstructTableAttributesList(table.ColumnDefs)
-			globalTOC.AddCoordinatorDataEntry(table.Schema, table.Name, table.Oid, attributes, rowsCopied, table.PartitionLevelInfo.RootName, table.DistPolicy)
+			entry := globalTOC.AddCoordinatorDataEntry(table.Schema, table.Name, table.Oid, attributes, rowsCopied, table.PartitionLevelInfo.RootName, table.DistPolicy)
+			entry.EstimatedSize = tableSizes[table.Oid]
+			entry.RowFilter = rowFilters[table.FQN()]
 		}
 	}
 }
 
+/*
+ * Backing up the largest tables first keeps one large table that happens to
+ * be copied last from leaving the other workers idle while it finishes.
+ * Tables of the same size stay in oid order.
+ */
+func SortTablesBySize(tables []Table, tableSizes map[uint32]int64) []Table {
+	sortedTables := make([]Table, len(tables))
+	copy(sortedTables, tables)
+	sort.SliceStable(sortedTables, func(i, j int) bool {
+		return tableSizes[sortedTables[i].Oid] > tableSizes[sortedTables[j].Oid]
+	})
+	return sortedTables
+}
+
 type BackupProgressCounters struct {
 	NumRegTables   int64
 	TotalRegTables int64
@@ -66,6 +108,7 @@ func CopyTableOut(connectionPool *dbconn.DBConn, table Table, destinationToWrite
 	checkPipeExistsCommand := ""
 	customPipeThroughCommand := utils.GetPipeThroughProgram().OutputCommand
 	sendToDestinationCommand := ">"
+	checksumCommand := ""
 	if MustGetFlagBool(options.SINGLE_DATA_FILE) {
 		/*
 		 * The segment TOC files are always written to the segment data directory for
@@ -77,15 +120,43 @@ func CopyTableOut(connectionPool *dbconn.DBConn, table Table, destinationToWrite
 		customPipeThroughCommand = "cat -"
 	} else if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
 		sendToDestinationCommand = fmt.Sprintf("| %s backup_data %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath)
+	} else if MustGetFlagBool(options.VERIFY) {
+		/*
+		 * The checksum is computed from the file once it is complete, rather than
+		 * by splitting the stream with tee, so that a failed write still fails the
+		 * COPY instead of being masked by the exit status of sha256sum.
+		 */
+		checksumCommand = fmt.Sprintf(" && sha256sum < %[1]s > %[1]s%s", destinationToWrite, utils.ChecksumFileExtension)
 	}
 
-	copyCommand := fmt.Sprintf("PROGRAM '%s%s %s %s'", checkPipeExistsCommand, customPipeThroughCommand, sendToDestinationCommand, destinationToWrite)
+	copyCommand := fmt.Sprintf("PROGRAM '%s%s %s %s%s'", checkPipeExistsCommand, customPipeThroughCommand, sendToDestinationCommand, destinationToWrite, checksumCommand)
 
 	columnNames := ""
 		// process column names to exclude generated columns from data copy out
 	columnNames = ConstructTableAttributesList(table.ColumnDefs)
 
 	query := fmt.Sprintf("COPY %s%s TO %s WITH CSV DELIMITER '%s' ON SEGMENT IGNORE EXTERNAL PARTITIONS;", table.FQN(), columnNames, copyCommand, tableDelim)
+	rowFilter, isFiltered := rowFilters[table.FQN()]
+	columnRules, isMasked := maskingRules[table.FQN()]
+	samplePredicate, isSampled := samplePredicates[table.FQN()]
+	if isFiltered || isMasked || isSampled {
+		selectList := strings.Trim(columnNames, "()")
+		if isMasked {
+			selectList = ConstructMaskedSelectList(table.ColumnDefs, columnRules)
+		}
+		whereClause := ""
+		// The row filter is on lines of its own so that a trailing comment in it cannot swallow the rest of the query
+		if isFiltered && isSampled {
+			whereClause = fmt.Sprintf(" WHERE (\n%s\n) AND %s", rowFilter, samplePredicate)
+		} else if isFiltered {
+			whereClause = fmt.Sprintf(" WHERE (\n%s\n)", rowFilter)
+		} else if isSampled {
+			whereClause = fmt.Sprintf(" WHERE %s", samplePredicate)
+		}
+		// IGNORE EXTERNAL PARTITIONS is not supported when copying out a query
+		query = fmt.Sprintf("COPY (SELECT %s FROM %s%s) TO %s WITH CSV DELIMITER '%s' ON SEGMENT;",
+			selectList, table.FQN(), whereClause, copyCommand, tableDelim)
+	}
 	gplog.Verbose("Worker %d: %s", connNum, query)
 	result, err := connectionPool.Exec(query, connNum)
 	if err != nil {
@@ -112,11 +183,14 @@ func BackupSingleTableData(table Table, rowsCopiedMap map[uint32]int64, counters
 	} else {
 		destinationToWrite = globalFPInfo.GetTableBackupFilePathForCopyCommand(table.Oid, utils.GetPipeThroughProgram().Extension, false)
 	}
+	start := operating.System.Now()
 	rowsCopied, err := CopyTableOut(connectionPool, table, destinationToWrite, whichConn)
 	if err != nil {
 		return err
 	}
 	rowsCopiedMap[table.Oid] = rowsCopied
+	reportTimings.AddTable(table.Oid, table.Schema, table.Name, rowsCopied, start)
+	recordTableDataComplete(table, rowsCopied)
 	counters.ProgressBar.Increment()
 	return nil
 }
@@ -344,3 +418,136 @@ func LockTableNoWait(dataTable Table, connNum int) error {
 	}
 	return nil
 }
+
+/*
+ * Row filters are keyed by the quoted FQN of each table, so they can be looked
+ * up with Table.FQN() when the data of the table is copied out.
+ */
+func initializeRowFilters() {
+	filters, err := utils.ReadRowFilterFile(MustGetFlagString(options.ROW_FILTER_FILE))
+	gplog.FatalOnError(err)
+	fqns := make([]string, 0, len(filters))
+	for fqn := range filters {
+		fqns = append(fqns, fqn)
+	}
+	sort.Strings(fqns)
+	quotedFQNs, err := options.QuoteTableNames(connectionPool, fqns)
+	gplog.FatalOnError(err)
+	rowFilters = make(map[string]string, len(fqns))
+	for i, fqn := range fqns {
+		rowFilters[quotedFQNs[i]] = filters[fqn]
+	}
+}
+
+/*
+ * A table in the row filter file whose data is not being backed up is most
+ * likely a typo, and backing up the table it was meant for without its filter
+ * could leak the very rows the filter was meant to exclude, so we fail.
+ */
+func ValidateRowFilters(dataTables []Table) {
+	dataTableFQNs := make(map[string]bool, len(dataTables))
+	for _, table := range dataTables {
+		dataTableFQNs[table.FQN()] = true
+	}
+	filteredFQNs := make([]string, 0, len(rowFilters))
+	for fqn := range rowFilters {
+		filteredFQNs = append(filteredFQNs, fqn)
+	}
+	sort.Strings(filteredFQNs)
+	for _, fqn := range filteredFQNs {
+		if !dataTableFQNs[fqn] {
+			gplog.Fatal(nil, "Table %s in the row filter file is not among the tables whose data is being backed up", fqn)
+		}
+		gplog.Verbose("Backing up only the rows of table %s where %s", fqn, rowFilters[fqn])
+	}
+}
+
+/*
+ * The data of a table with a row filter, masking rules or a sample is copied
+ * out with a query, which cannot skip external partitions the way COPY does
+ * with IGNORE EXTERNAL PARTITIONS, so the data of the external partitions of a
+ * partitioned table would be read and backed up along with it.  The root name
+ * of a partition does not include its schema, so a partitioned table with the
+ * same name as the root of an external partition in another schema is
+ * rejected as well.
+ */
+func ValidateExternalPartitions(metadataTables []Table, dataTables []Table) {
+	rootsWithExternalPartitions := make(map[string]bool)
+	for _, table := range metadataTables {
+		if table.PartitionLevelInfo.Level == "l" && table.SkipDataBackup() {
+			rootsWithExternalPartitions[table.PartitionLevelInfo.RootName] = true
+		}
+	}
+	for _, table := range dataTables {
+		if table.PartitionLevelInfo.Level != "p" || !rootsWithExternalPartitions[table.Name] {
+			continue
+		}
+		_, isFiltered := rowFilters[table.FQN()]
+		_, isMasked := maskingRules[table.FQN()]
+		_, isSampled := samplePredicates[table.FQN()]
+		if isFiltered || isMasked || isSampled {
+			gplog.Fatal(nil, "Table %s has external partitions, so --%s must be used to back up its data with a row filter, masking rules or a sample",
+				table.FQN(), options.LEAF_PARTITION_DATA)
+		}
+	}
+}
+
+/*
+ * As with row filters, masking rules are keyed by the quoted FQN of each table.
+ * The columns are left as they are in the file and matched to the columns of
+ * the table once they are known.
+ */
+func initializeMaskingRules() {
+	rules, err := utils.ReadMaskingRulesFile(MustGetFlagString(options.MASKING_RULES_FILE))
+	gplog.FatalOnError(err)
+	fqns := make([]string, 0, len(rules))
+	for fqn := range rules {
+		fqns = append(fqns, fqn)
+	}
+	sort.Strings(fqns)
+	quotedFQNs, err := options.QuoteTableNames(connectionPool, fqns)
+	gplog.FatalOnError(err)
+	maskingRules = make(map[string]map[string]utils.MaskingRule, len(fqns))
+	for i, fqn := range fqns {
+		maskingRules[quotedFQNs[i]] = rules[fqn]
+	}
+}
+
+/*
+ * An unknown table or column in the masking rules file would leave the data it
+ * was meant to mask in the backup, so we fail instead.
+ */
+func ValidateMaskingRules(dataTables []Table) {
+	dataTablesByFQN := make(map[string]Table, len(dataTables))
+	for _, table := range dataTables {
+		dataTablesByFQN[table.FQN()] = table
+	}
+	maskedFQNs := make([]string, 0, len(maskingRules))
+	for fqn := range maskingRules {
+		maskedFQNs = append(maskedFQNs, fqn)
+	}
+	sort.Strings(maskedFQNs)
+	for _, fqn := range maskedFQNs {
+		table, ok := dataTablesByFQN[fqn]
+		if !ok {
+			gplog.Fatal(nil, "Table %s in the masking rules file is not among the tables whose data is being backed up", fqn)
+		}
+		columns := make(map[string]bool, len(table.ColumnDefs))
+		for _, col := range table.ColumnDefs {
+			if col.AttGenerated == "" {
+				columns[utils.UnquoteIdent(col.Name)] = true
+			}
+		}
+		maskedColumns := make([]string, 0, len(maskingRules[fqn]))
+		for column := range maskingRules[fqn] {
+			maskedColumns = append(maskedColumns, column)
+		}
+		sort.Strings(maskedColumns)
+		for _, column := range maskedColumns {
+			if !columns[utils.UnquoteIdent(column)] {
+				gplog.Fatal(nil, "Column %s of table %s in the masking rules file is not among the columns whose data is being backed up", column, fqn)
+			}
+			gplog.Verbose("Masking column %s of table %s with rule %s", column, fqn, maskingRules[fqn][column].Rule)
+		}
+	}
+}
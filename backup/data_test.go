@@ -5,6 +5,7 @@ import (
 	"regexp"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
 	"github.com/cloudberrydb/gpbackup/backup"
 	"github.com/cloudberrydb/gpbackup/history"
 	"github.com/cloudberrydb/gpbackup/options"
@@ -53,24 +54,57 @@ var _ = Describe("backup/data tests", func() {
 		})
 		It("adds an entry for a regular table to the TOC", func() {
 			tables := []backup.Table{table}
-			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, map[uint32]int64{})
 			expectedDataEntries := []toc.CoordinatorDataEntry{{Schema: "public", Name: "table", Oid: 1, AttributeString: "(a)"}}
 			Expect(tocfile.DataEntries).To(Equal(expectedDataEntries))
 		})
+		It("adds the estimated size of a table to its TOC entry", func() {
+			tables := []backup.Table{table}
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, map[uint32]int64{1: 32768})
+			expectedDataEntries := []toc.CoordinatorDataEntry{{Schema: "public", Name: "table", Oid: 1, AttributeString: "(a)", EstimatedSize: 32768}}
+			Expect(tocfile.DataEntries).To(Equal(expectedDataEntries))
+		})
+		It("adds the row filter of a table to its TOC entry", func() {
+			backup.SetRowFilters(map[string]string{"public.table": "a > 1"})
+			defer backup.SetRowFilters(nil)
+			tables := []backup.Table{table}
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, map[uint32]int64{})
+			expectedDataEntries := []toc.CoordinatorDataEntry{{Schema: "public", Name: "table", Oid: 1, AttributeString: "(a)", RowFilter: "a > 1"}}
+			Expect(tocfile.DataEntries).To(Equal(expectedDataEntries))
+		})
 		It("does not add an entry for an external table to the TOC", func() {
 			table.IsExternal = true
 			tables := []backup.Table{table}
-			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, map[uint32]int64{})
 			Expect(tocfile.DataEntries).To(BeNil())
 		})
 		It("does not add an entry for a foreign table to the TOC", func() {
 			foreignDef := backup.ForeignTableDefinition{Oid: 23, Options: "", Server: "fs"}
 			table.ForeignDef = foreignDef
 			tables := []backup.Table{table}
-			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps)
+			backup.AddTableDataEntriesToTOC(tables, rowsCopiedMaps, map[uint32]int64{})
 			Expect(tocfile.DataEntries).To(BeNil())
 		})
 	})
+	Describe("SortTablesBySize", func() {
+		It("orders tables from largest to smallest, keeping tables of the same size in their original order", func() {
+			tables := []backup.Table{
+				{Relation: backup.Relation{Oid: 1, Schema: "public", Name: "small"}},
+				{Relation: backup.Relation{Oid: 2, Schema: "public", Name: "large"}},
+				{Relation: backup.Relation{Oid: 3, Schema: "public", Name: "empty1"}},
+				{Relation: backup.Relation{Oid: 4, Schema: "public", Name: "empty2"}},
+				{Relation: backup.Relation{Oid: 5, Schema: "public", Name: "medium"}},
+			}
+			sortedTables := backup.SortTablesBySize(tables, map[uint32]int64{1: 8192, 2: 819200, 5: 81920})
+
+			sortedOids := make([]uint32, 0)
+			for _, table := range sortedTables {
+				sortedOids = append(sortedOids, table.Oid)
+			}
+			Expect(sortedOids).To(Equal([]uint32{2, 5, 1, 3, 4}))
+			Expect(tables[0].Oid).To(Equal(uint32(1)))
+		})
+	})
 	Describe("CopyTableOut", func() {
 		testTable := backup.Table{Relation: backup.Relation{SchemaOid: 2345, Oid: 3456, Schema: "public", Name: "foo"}}
 		It("will back up a table to its own file with gzip compression", func() {
@@ -129,6 +163,17 @@ var _ = Describe("backup/data tests", func() {
 
 			Expect(err).ShouldNot(HaveOccurred())
 		})
+		It("will back up a table to its own file and record its checksum", func() {
+			_ = cmdFlags.Set(options.VERIFY, "true")
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -8", InputCommand: "gzip -d -c", Extension: ".gz"})
+			execStr := regexp.QuoteMeta("COPY public.foo TO PROGRAM 'gzip -c -8 > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz && sha256sum < <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz.sha256' WITH CSV DELIMITER ',' ON SEGMENT IGNORE EXTERNAL PARTITIONS;")
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, testTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
 		It("will back up a table to its own file without compression using a plugin", func() {
 			_ = cmdFlags.Set(options.PLUGIN_CONFIG, "/tmp/plugin_config")
 			pluginConfig := utils.PluginConfig{ExecutablePath: "/tmp/fake-plugin.sh", ConfigPath: "/tmp/plugin_config"}
@@ -153,6 +198,194 @@ var _ = Describe("backup/data tests", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 		})
 	})
+	Describe("CopyTableOut with row filters", func() {
+		testTable := backup.Table{
+			Relation:        backup.Relation{SchemaOid: 2345, Oid: 3456, Schema: "public", Name: "foo"},
+			TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Name: "a"}, {Name: "b"}, {Name: "c", AttGenerated: "s"}}},
+		}
+		BeforeEach(func() {
+			backup.SetRowFilters(map[string]string{"public.foo": "region = 'EU'"})
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -8", InputCommand: "gzip -d -c", Extension: ".gz"})
+		})
+		AfterEach(func() {
+			backup.SetRowFilters(nil)
+		})
+		It("backs up only the rows of a filtered table that match its filter", func() {
+			execStr := regexp.QuoteMeta("COPY (SELECT a,b FROM public.foo WHERE (\nregion = 'EU'\n)) TO PROGRAM 'gzip -c -8 > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz' WITH CSV DELIMITER ',' ON SEGMENT;")
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, testTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+		It("keeps a trailing comment in a filter from affecting the rest of the query", func() {
+			backup.SetRowFilters(map[string]string{"public.foo": "region = 'EU' -- European customers only"})
+			execStr := regexp.QuoteMeta("COPY (SELECT a,b FROM public.foo WHERE (\nregion = 'EU' -- European customers only\n)) TO PROGRAM")
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, testTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+		It("backs up all of the rows of a table without a filter", func() {
+			unfilteredTable := testTable
+			unfilteredTable.Name = "bar"
+			execStr := regexp.QuoteMeta("COPY public.bar(a,b) TO PROGRAM 'gzip -c -8 > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz' WITH CSV DELIMITER ',' ON SEGMENT IGNORE EXTERNAL PARTITIONS;")
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, unfilteredTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+	Describe("CopyTableOut with masking rules", func() {
+		testTable := backup.Table{
+			Relation:        backup.Relation{SchemaOid: 2345, Oid: 3456, Schema: "public", Name: "foo"},
+			TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Name: "id"}, {Name: "email"}, {Name: `"SSN"`}}},
+		}
+		BeforeEach(func() {
+			backup.SetMaskingRules(map[string]map[string]utils.MaskingRule{
+				"public.foo": {"email": {Rule: "hash", Salt: "s3cr3t"}, "SSN": {Rule: "nullify"}},
+			})
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -8", InputCommand: "gzip -d -c", Extension: ".gz"})
+		})
+		AfterEach(func() {
+			backup.SetMaskingRules(nil)
+			backup.SetRowFilters(nil)
+		})
+		It("backs up the masked columns of a table in place of the original data", func() {
+			execStr := regexp.QuoteMeta(`COPY (SELECT id,md5('s3cr3t' || email::text) AS email,NULL AS "SSN" FROM public.foo) TO PROGRAM 'gzip -c -8 > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz' WITH CSV DELIMITER ',' ON SEGMENT;`)
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, testTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+		It("backs up the masked columns of only the rows matching the row filter of a table", func() {
+			backup.SetRowFilters(map[string]string{"public.foo": "id > 100"})
+			execStr := regexp.QuoteMeta("COPY (SELECT id,md5('s3cr3t' || email::text) AS email,NULL AS \"SSN\" FROM public.foo WHERE (\nid > 100\n)) TO PROGRAM 'gzip -c -8 > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz' WITH CSV DELIMITER ',' ON SEGMENT;")
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, testTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+	Describe("CopyTableOut with sampling", func() {
+		testTable := backup.Table{
+			Relation:        backup.Relation{SchemaOid: 2345, Oid: 3456, Schema: "public", Name: "foo"},
+			TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Name: "a"}}},
+		}
+		BeforeEach(func() {
+			backup.SetSamplePredicates(map[string]string{"public.foo": "abs(hashtext(ROW(public.foo.a)::text)::bigint) % 1000000 < 100000"})
+			utils.SetPipeThroughProgram(utils.PipeThroughProgram{Name: "gzip", OutputCommand: "gzip -c -8", InputCommand: "gzip -d -c", Extension: ".gz"})
+		})
+		AfterEach(func() {
+			backup.SetSamplePredicates(nil)
+			backup.SetRowFilters(nil)
+		})
+		It("backs up only the sampled rows of a table", func() {
+			execStr := regexp.QuoteMeta("COPY (SELECT a FROM public.foo WHERE abs(hashtext(ROW(public.foo.a)::text)::bigint) % 1000000 < 100000) TO PROGRAM 'gzip -c -8 > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz' WITH CSV DELIMITER ',' ON SEGMENT;")
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, testTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+		It("backs up only the sampled rows of a table that match its row filter", func() {
+			backup.SetRowFilters(map[string]string{"public.foo": "a > 1 OR a < -1"})
+			execStr := regexp.QuoteMeta("COPY (SELECT a FROM public.foo WHERE (\na > 1 OR a < -1\n) AND abs(hashtext(ROW(public.foo.a)::text)::bigint) % 1000000 < 100000) TO PROGRAM 'gzip -c -8 > <SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz' WITH CSV DELIMITER ',' ON SEGMENT;")
+			mock.ExpectExec(execStr).WillReturnResult(sqlmock.NewResult(10, 0))
+			filename := "<SEG_DATA_DIR>/backups/20170101/20170101010101/gpbackup_<SEGID>_20170101010101_3456.gz"
+
+			_, err := backup.CopyTableOut(connectionPool, testTable, filename, defaultConnNum)
+
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+	Describe("ConstructMaskedSelectList", func() {
+		It("replaces masked columns and excludes generated columns", func() {
+			columnDefs := []backup.ColumnDefinition{{Name: "a"}, {Name: "b", AttGenerated: "s"}, {Name: "c"}, {Name: "d"}}
+			columnRules := map[string]utils.MaskingRule{
+				"c": {Rule: "value", Value: "O'Brien"},
+				"d": {Rule: "expression", Expression: "left(d, 1)"},
+			}
+			Expect(backup.ConstructMaskedSelectList(columnDefs, columnRules)).To(Equal("a,'O''Brien' AS c,(left(d, 1)) AS d"))
+		})
+	})
+	Describe("ValidateMaskingRules", func() {
+		dataTables := []backup.Table{{
+			Relation:        backup.Relation{Schema: "public", Name: "foo"},
+			TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Name: "a"}, {Name: "b", AttGenerated: "s"}}},
+		}}
+		AfterEach(func() {
+			backup.SetMaskingRules(nil)
+		})
+		It("accepts masking rules on columns whose data is backed up", func() {
+			backup.SetMaskingRules(map[string]map[string]utils.MaskingRule{"public.foo": {"a": {Rule: "hash"}}})
+			backup.ValidateMaskingRules(dataTables)
+		})
+		It("panics on masking rules for a table whose data is not backed up", func() {
+			backup.SetMaskingRules(map[string]map[string]utils.MaskingRule{"public.bar": {"a": {Rule: "hash"}}})
+			defer testhelper.ShouldPanicWithMessage("Table public.bar in the masking rules file is not among the tables whose data is being backed up")
+			backup.ValidateMaskingRules(dataTables)
+		})
+		It("panics on a masking rule for a generated column", func() {
+			backup.SetMaskingRules(map[string]map[string]utils.MaskingRule{"public.foo": {"b": {Rule: "hash"}}})
+			defer testhelper.ShouldPanicWithMessage("Column b of table public.foo in the masking rules file is not among the columns whose data is being backed up")
+			backup.ValidateMaskingRules(dataTables)
+		})
+	})
+	Describe("ValidateExternalPartitions", func() {
+		root := backup.Table{Relation: backup.Relation{Schema: "public", Name: "sales"}, TableDefinition: backup.TableDefinition{PartitionLevelInfo: backup.PartitionLevelInfo{Level: "p"}}}
+		leaf := backup.Table{Relation: backup.Relation{Schema: "public", Name: "sales_2017"}, TableDefinition: backup.TableDefinition{PartitionLevelInfo: backup.PartitionLevelInfo{Level: "l", RootName: "sales"}}}
+		externalLeaf := backup.Table{Relation: backup.Relation{Schema: "public", Name: "sales_2016"}, TableDefinition: backup.TableDefinition{IsExternal: true, PartitionLevelInfo: backup.PartitionLevelInfo{Level: "l", RootName: "sales"}}}
+		AfterEach(func() {
+			backup.SetRowFilters(nil)
+			backup.SetSamplePredicates(nil)
+		})
+		It("accepts a row filter on a partitioned table without external partitions", func() {
+			backup.SetRowFilters(map[string]string{"public.sales": "amount > 0"})
+			backup.ValidateExternalPartitions([]backup.Table{root, leaf}, []backup.Table{root})
+		})
+		It("accepts a partitioned table with external partitions that has no row filter, masking rules or sample", func() {
+			backup.ValidateExternalPartitions([]backup.Table{root, leaf, externalLeaf}, []backup.Table{root})
+		})
+		It("panics on a row filter on a partitioned table with external partitions", func() {
+			backup.SetRowFilters(map[string]string{"public.sales": "amount > 0"})
+			defer testhelper.ShouldPanicWithMessage("Table public.sales has external partitions, so --leaf-partition-data must be used to back up its data with a row filter, masking rules or a sample")
+			backup.ValidateExternalPartitions([]backup.Table{root, leaf, externalLeaf}, []backup.Table{root})
+		})
+		It("panics on a sample of a partitioned table with external partitions", func() {
+			backup.SetSamplePredicates(map[string]string{"public.sales": "true"})
+			defer testhelper.ShouldPanicWithMessage("Table public.sales has external partitions, so --leaf-partition-data must be used to back up its data with a row filter, masking rules or a sample")
+			backup.ValidateExternalPartitions([]backup.Table{root, leaf, externalLeaf}, []backup.Table{root})
+		})
+	})
+	Describe("ValidateRowFilters", func() {
+		dataTables := []backup.Table{
+			{Relation: backup.Relation{Schema: "public", Name: "foo"}},
+			{Relation: backup.Relation{Schema: "public", Name: "bar"}},
+		}
+		AfterEach(func() {
+			backup.SetRowFilters(nil)
+		})
+		It("accepts row filters on tables whose data is backed up", func() {
+			backup.SetRowFilters(map[string]string{"public.foo": "a > 1"})
+			backup.ValidateRowFilters(dataTables)
+		})
+		It("panics on a row filter for a table whose data is not backed up", func() {
+			backup.SetRowFilters(map[string]string{"public.foo": "a > 1", "public.baz": "a > 1"})
+			defer testhelper.ShouldPanicWithMessage("Table public.baz in the row filter file is not among the tables whose data is being backed up")
+			backup.ValidateRowFilters(dataTables)
+		})
+	})
 	Describe("BackupSingleTableData", func() {
 		var (
 			testTable     backup.Table
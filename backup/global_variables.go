@@ -1,6 +1,7 @@
 package backup
 
 import (
+	"os"
 	"sync"
 
 	"github.com/cloudberrydb/gp-common-go-libs/cluster"
@@ -39,13 +40,23 @@ var (
 	globalFPInfo         filepath.FilePathInfo
 	globalTOC            *toc.TOC
 	objectCounts         map[string]int
+	reportTimings        *report.Timings
+	hookConfig           *utils.HookConfig
 	pluginConfig         *utils.PluginConfig
 	version              string
 	wasTerminated        bool
 	backupLockFile       lockfile.Lockfile
 	filterRelationClause string
 	quotedRoleNames      map[string]string
+	rowFilters           map[string]string
+	maskingRules         map[string]map[string]utils.MaskingRule
+	samplePredicates     map[string]string
+	heapTableEntries     map[string]toc.HeapEntry
+	heapStatsTimestamp   string
+	heapAfterSnapshot    *toc.IncrementalEntries
 	backupSnapshot       string
+	dataProgressFile     *os.File
+	dataProgressMutex    sync.Mutex
 	/*
 	 * Used for synchronizing DoCleanup.  In DoInit() we increment the group
 	 * and then wait for at least one DoCleanup to finish, either in DoTeardown
@@ -108,6 +119,18 @@ func SetQuotedRoleNames(quotedRoles map[string]string) {
 	quotedRoleNames = quotedRoles
 }
 
+func SetRowFilters(filters map[string]string) {
+	rowFilters = filters
+}
+
+func SetMaskingRules(rules map[string]map[string]utils.MaskingRule) {
+	maskingRules = rules
+}
+
+func SetSamplePredicates(predicates map[string]string) {
+	samplePredicates = predicates
+}
+
 // Util functions to enable ease of access to global flag values
 
 func FlagChanged(flagName string) bool {
@@ -122,6 +145,10 @@ func MustGetFlagInt(flagName string) int {
 	return options.MustGetFlagInt(cmdFlags, flagName)
 }
 
+func MustGetFlagFloat64(flagName string) float64 {
+	return options.MustGetFlagFloat64(cmdFlags, flagName)
+}
+
 func MustGetFlagBool(flagName string) bool {
 	return options.MustGetFlagBool(cmdFlags, flagName)
 }
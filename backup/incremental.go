@@ -8,16 +8,17 @@ import (
 	"github.com/cloudberrydb/gpbackup/history"
 	"github.com/cloudberrydb/gpbackup/options"
 	"github.com/cloudberrydb/gpbackup/toc"
-	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/pkg/errors"
 )
 
-func FilterTablesForIncremental(lastBackupTOC, currentTOC *toc.TOC, tables []Table) []Table {
+func FilterTablesForIncremental(lastBackupTOC, currentTOC *toc.TOC, heapAfterSnapshot *toc.IncrementalEntries, tables []Table) []Table {
 	var filteredTables []Table
 	for _, table := range tables {
 		currentAOEntry, isAOTable := currentTOC.IncrementalMetadata.AO[table.FQN()]
 		if !isAOTable {
-			filteredTables = append(filteredTables, table)
+			if !heapTableIsUnchanged(lastBackupTOC, currentTOC, heapAfterSnapshot, table.FQN()) {
+				filteredTables = append(filteredTables, table)
+			}
 			continue
 		}
 		previousAOEntry := lastBackupTOC.IncrementalMetadata.AO[table.FQN()]
@@ -30,6 +31,29 @@ func FilterTablesForIncremental(lastBackupTOC, currentTOC *toc.TOC, tables []Tab
 	return filteredTables
 }
 
+/*
+ * A heap table is only skipped with --incremental-heap, and only if it was
+ * tracked by both backups and nothing about it changed, including the time the
+ * statistics were last reset, both before and after the snapshot of this
+ * backup was taken.  A table is backed up again whenever its statistics cannot
+ * be trusted or a change to it may have been counted during the snapshot.
+ */
+func heapTableIsUnchanged(lastBackupTOC, currentTOC *toc.TOC, heapAfterSnapshot *toc.IncrementalEntries, tableFQN string) bool {
+	if heapAfterSnapshot == nil {
+		return false
+	}
+	previousHeapEntry, previouslyTracked := lastBackupTOC.IncrementalMetadata.Heap[tableFQN]
+	currentHeapEntry, currentlyTracked := currentTOC.IncrementalMetadata.Heap[tableFQN]
+	afterSnapshotHeapEntry, trackedAfterSnapshot := heapAfterSnapshot.Heap[tableFQN]
+	if !previouslyTracked || !currentlyTracked || !trackedAfterSnapshot {
+		return false
+	}
+	statsTimestamp := lastBackupTOC.IncrementalMetadata.HeapStatsTimestamp
+	return statsTimestamp != "" && statsTimestamp == currentTOC.IncrementalMetadata.HeapStatsTimestamp &&
+		statsTimestamp == heapAfterSnapshot.HeapStatsTimestamp &&
+		previousHeapEntry == currentHeapEntry && previousHeapEntry == afterSnapshotHeapEntry
+}
+
 func GetTargetBackupTimestamp() string {
 	targetTimestamp := ""
 	if fromTimestamp := MustGetFlagString(options.FROM_TIMESTAMP); fromTimestamp != "" {
@@ -62,9 +86,16 @@ func GetLatestMatchingBackupTimestamp() string {
 	return latestTimestamp
 }
 
+/*
+ * A differential backup is based off the latest full backup, rather than the
+ * latest backup, so that its restore plan is only that backup and itself.
+ */
 func GetLatestMatchingBackupConfig(history *history.History, currentBackupConfig *history.BackupConfig) *history.BackupConfig {
 	for _, backupConfig := range history.BackupConfigs {
-		if matchesIncrementalFlags(&backupConfig, currentBackupConfig) && !backupConfig.Failed() {
+		if currentBackupConfig.Differential && backupConfig.Incremental {
+			continue
+		}
+		if matchesIncrementalFlags(&backupConfig, currentBackupConfig) && !backupConfig.Failed() && !backupConfig.Deleted() {
 			return &backupConfig
 		}
 	}
@@ -72,6 +103,11 @@ func GetLatestMatchingBackupConfig(history *history.History, currentBackupConfig
 	return nil
 }
 
+/*
+ * The data of a table that is unchanged since the base backup is restored from
+ * that backup, so the base backup must not have selected, filtered, masked or
+ * sampled the data any differently than this backup would.
+ */
 func matchesIncrementalFlags(backupConfig *history.BackupConfig, currentBackupConfig *history.BackupConfig) bool {
 	_, pluginBinaryName := path.Split(backupConfig.Plugin)
 	return backupConfig.BackupDir == MustGetFlagString(options.BACKUP_DIR) &&
@@ -81,10 +117,7 @@ func matchesIncrementalFlags(backupConfig *history.BackupConfig, currentBackupCo
 		backupConfig.SingleDataFile == MustGetFlagBool(options.SINGLE_DATA_FILE) &&
 		backupConfig.Compressed == currentBackupConfig.Compressed &&
 		// Expanding of the include list happens before this now so we must compare again current backup config
-		utils.NewIncludeSet(backupConfig.IncludeRelations).Equals(utils.NewIncludeSet(currentBackupConfig.IncludeRelations)) &&
-		utils.NewIncludeSet(backupConfig.IncludeSchemas).Equals(utils.NewIncludeSet(MustGetFlagStringArray(options.INCLUDE_SCHEMA))) &&
-		utils.NewIncludeSet(backupConfig.ExcludeRelations).Equals(utils.NewIncludeSet(MustGetFlagStringArray(options.EXCLUDE_RELATION))) &&
-		utils.NewIncludeSet(backupConfig.ExcludeSchemas).Equals(utils.NewIncludeSet(MustGetFlagStringArray(options.EXCLUDE_SCHEMA)))
+		backupConfig.SelectsSameData(currentBackupConfig)
 }
 
 func PopulateRestorePlan(changedTables []Table,
@@ -10,6 +10,7 @@ import (
 	"github.com/cloudberrydb/gpbackup/report"
 	"github.com/cloudberrydb/gpbackup/testutils"
 	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -59,7 +60,7 @@ var _ = Describe("backup/incremental tests", func() {
 			tblAOUnchanged,
 		}
 
-		filteredTables := backup.FilterTablesForIncremental(&prevTOC, &currTOC, tables)
+		filteredTables := backup.FilterTablesForIncremental(&prevTOC, &currTOC, nil, tables)
 
 		It("Should include the heap table in the filtered list", func() {
 			Expect(filteredTables).To(ContainElement(tblHeap))
@@ -76,6 +77,91 @@ var _ = Describe("backup/incremental tests", func() {
 		It("Should NOT include the unmodified AO table", func() {
 			Expect(filteredTables).To(Not(ContainElement(tblAOUnchanged)))
 		})
+
+		Context("heap tables", func() {
+			defaultHeapEntry := toc.HeapEntry{
+				Relfilenode:      16384,
+				TuplesInserted:   10,
+				TuplesUpdated:    2,
+				TuplesDeleted:    1,
+				LastDDLTimestamp: "00000",
+			}
+			changedHeapEntry := defaultHeapEntry
+			changedHeapEntry.TuplesUpdated = 3
+			prevHeapTOC := toc.TOC{
+				IncrementalMetadata: toc.IncrementalEntries{
+					Heap: map[string]toc.HeapEntry{
+						"public.heap_changed":   defaultHeapEntry,
+						"public.heap_unchanged": defaultHeapEntry,
+					},
+					HeapStatsTimestamp: "2024-01-01 00:00:00+00",
+				},
+			}
+			currHeapTOC := toc.TOC{
+				IncrementalMetadata: toc.IncrementalEntries{
+					Heap: map[string]toc.HeapEntry{
+						"public.heap_changed":   changedHeapEntry,
+						"public.heap_unchanged": defaultHeapEntry,
+						"public.heap_new":       defaultHeapEntry,
+					},
+					HeapStatsTimestamp: "2024-01-01 00:00:00+00",
+				},
+			}
+			tblHeapChanged := backup.Table{Relation: backup.Relation{Schema: "public", Name: "heap_changed"}}
+			tblHeapUnchanged := backup.Table{Relation: backup.Relation{Schema: "public", Name: "heap_unchanged"}}
+			tblHeapNew := backup.Table{Relation: backup.Relation{Schema: "public", Name: "heap_new"}}
+			heapTables := []backup.Table{tblHeapChanged, tblHeapUnchanged, tblHeapNew}
+
+			heapAfterSnapshot := currHeapTOC.IncrementalMetadata
+
+			It("Should include only the heap tables that changed or were not tracked by the previous backup", func() {
+				filteredHeapTables := backup.FilterTablesForIncremental(&prevHeapTOC, &currHeapTOC, &heapAfterSnapshot, heapTables)
+
+				Expect(filteredHeapTables).To(Equal([]backup.Table{tblHeapChanged, tblHeapNew}))
+			})
+			It("Should include every heap table without --incremental-heap", func() {
+				filteredHeapTables := backup.FilterTablesForIncremental(&prevHeapTOC, &currHeapTOC, nil, heapTables)
+
+				Expect(filteredHeapTables).To(Equal(heapTables))
+			})
+			It("Should include a heap table whose statistics changed after the snapshot was taken", func() {
+				changedAfterSnapshot := toc.IncrementalEntries{
+					Heap: map[string]toc.HeapEntry{
+						"public.heap_changed":   changedHeapEntry,
+						"public.heap_unchanged": changedHeapEntry,
+						"public.heap_new":       defaultHeapEntry,
+					},
+					HeapStatsTimestamp: "2024-01-01 00:00:00+00",
+				}
+
+				filteredHeapTables := backup.FilterTablesForIncremental(&prevHeapTOC, &currHeapTOC, &changedAfterSnapshot, heapTables)
+
+				Expect(filteredHeapTables).To(Equal(heapTables))
+			})
+			It("Should include every heap table when the statistics were reset since the previous backup", func() {
+				resetHeapTOC := currHeapTOC
+				resetHeapTOC.IncrementalMetadata.HeapStatsTimestamp = "2024-02-01 00:00:00+00"
+
+				filteredHeapTables := backup.FilterTablesForIncremental(&prevHeapTOC, &resetHeapTOC, &heapAfterSnapshot, heapTables)
+
+				Expect(filteredHeapTables).To(Equal(heapTables))
+			})
+			It("Should include every heap table when the statistics were reset after the snapshot was taken", func() {
+				resetAfterSnapshot := heapAfterSnapshot
+				resetAfterSnapshot.HeapStatsTimestamp = "2024-02-01 00:00:00+00"
+
+				filteredHeapTables := backup.FilterTablesForIncremental(&prevHeapTOC, &currHeapTOC, &resetAfterSnapshot, heapTables)
+
+				Expect(filteredHeapTables).To(Equal(heapTables))
+			})
+			It("Should include every heap table when heap tables are not being tracked", func() {
+				untrackedTOC := toc.TOC{}
+
+				filteredHeapTables := backup.FilterTablesForIncremental(&prevHeapTOC, &untrackedTOC, &heapAfterSnapshot, heapTables)
+
+				Expect(filteredHeapTables).To(Equal(heapTables))
+			})
+		})
 	})
 
 	Describe("GetLatestMatchingBackupConfig", func() {
@@ -99,6 +185,62 @@ var _ = Describe("backup/incremental tests", func() {
 
 			structmatcher.ExpectStructsToMatch(contents.BackupConfigs[2], latestBackupHistoryEntry)
 		})
+		It("Should return the latest matching backup's timestamp that was not deleted", func() {
+			deletedContents := history.History{BackupConfigs: []history.BackupConfig{
+				{DatabaseName: "test1", Timestamp: "timestamp2", DateDeleted: "20170101010101"},
+				{DatabaseName: "test1", Timestamp: "timestamp1"},
+			}}
+			currentBackupConfig := history.BackupConfig{DatabaseName: "test1"}
+
+			latestBackupHistoryEntry := backup.GetLatestMatchingBackupConfig(&deletedContents, &currentBackupConfig)
+
+			structmatcher.ExpectStructsToMatch(deletedContents.BackupConfigs[1], latestBackupHistoryEntry)
+		})
+		It("Should return the latest matching full backup for a differential backup", func() {
+			differentialContents := history.History{BackupConfigs: []history.BackupConfig{
+				{DatabaseName: "test1", Timestamp: "timestamp3", Incremental: true, Differential: true},
+				{DatabaseName: "test1", Timestamp: "timestamp2", Incremental: true},
+				{DatabaseName: "test1", Timestamp: "timestamp1"},
+			}}
+			currentBackupConfig := history.BackupConfig{DatabaseName: "test1", Incremental: true, Differential: true}
+
+			latestBackupHistoryEntry := backup.GetLatestMatchingBackupConfig(&differentialContents, &currentBackupConfig)
+
+			structmatcher.ExpectStructsToMatch(differentialContents.BackupConfigs[2], latestBackupHistoryEntry)
+		})
+		It("Should not return a backup whose data was filtered by row filters", func() {
+			filteredContents := history.History{BackupConfigs: []history.BackupConfig{
+				{DatabaseName: "test1", Timestamp: "timestamp2", RowFilters: map[string]string{"public.foo": "a > 1"}},
+				{DatabaseName: "test1", Timestamp: "timestamp1"},
+			}}
+			currentBackupConfig := history.BackupConfig{DatabaseName: "test1"}
+
+			latestBackupHistoryEntry := backup.GetLatestMatchingBackupConfig(&filteredContents, &currentBackupConfig)
+
+			structmatcher.ExpectStructsToMatch(filteredContents.BackupConfigs[1], latestBackupHistoryEntry)
+		})
+		It("Should not return a backup whose data was masked", func() {
+			maskedContents := history.History{BackupConfigs: []history.BackupConfig{
+				{DatabaseName: "test1", Timestamp: "timestamp2", MaskingRules: map[string]map[string]utils.MaskingRule{"public.foo": {"email": {Rule: "nullify"}}}},
+				{DatabaseName: "test1", Timestamp: "timestamp1"},
+			}}
+			currentBackupConfig := history.BackupConfig{DatabaseName: "test1"}
+
+			latestBackupHistoryEntry := backup.GetLatestMatchingBackupConfig(&maskedContents, &currentBackupConfig)
+
+			structmatcher.ExpectStructsToMatch(maskedContents.BackupConfigs[1], latestBackupHistoryEntry)
+		})
+		It("Should not return a backup whose data was sampled", func() {
+			sampledContents := history.History{BackupConfigs: []history.BackupConfig{
+				{DatabaseName: "test1", Timestamp: "timestamp2", SamplePercent: 5},
+				{DatabaseName: "test1", Timestamp: "timestamp1"},
+			}}
+			currentBackupConfig := history.BackupConfig{DatabaseName: "test1"}
+
+			latestBackupHistoryEntry := backup.GetLatestMatchingBackupConfig(&sampledContents, &currentBackupConfig)
+
+			structmatcher.ExpectStructsToMatch(sampledContents.BackupConfigs[1], latestBackupHistoryEntry)
+		})
 		It("should return nil with no matching Dbname", func() {
 			currentBackupConfig := history.BackupConfig{DatabaseName: "test3"}
 
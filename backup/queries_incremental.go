@@ -124,3 +124,96 @@ func getLastDDLTimestamps(connectionPool *dbconn.DBConn) map[string]string {
 	}
 	return resultMap
 }
+
+/*
+ * The tuple statistics of heap tables are not transactional, and a change is
+ * only counted some time after it commits, so they are queried both before and
+ * after the backup snapshot is taken.  The values from before the snapshot are
+ * recorded, so a change counted during the snapshot differs from them when the
+ * next backup compares its statistics; a table whose statistics changed across
+ * the snapshot is copied by this backup as well.
+ *
+ * If the statistics are not being collected on every segment, no heap tables
+ * are tracked and all of them are backed up by the next incremental backup.
+ */
+func GetHeapIncrementalMetadata(connectionPool *dbconn.DBConn) (map[string]toc.HeapEntry, string) {
+	gplog.Verbose("Querying heap table statistics")
+	statsTimestamp, trackCounts := getHeapStatsTimestamp(connectionPool)
+	if !trackCounts {
+		gplog.Warn("Heap table statistics are not being collected on every segment, so heap tables will not be tracked for incremental backups; check that track_counts is on")
+		return nil, ""
+	}
+
+	query := fmt.Sprintf(`
+	SELECT quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS tablefqn,
+		c.relfilenode,
+		stats.tuplesinserted,
+		stats.tuplesupdated,
+		stats.tuplesdeleted,
+		COALESCE(lastop.lastddltimestamp::text, '') AS lastddltimestamp
+	FROM pg_class c
+		JOIN pg_namespace n ON c.relnamespace = n.oid
+		JOIN pg_am a ON c.relam = a.oid
+		JOIN ( SELECT oid,
+				pg_catalog.sum(pg_stat_get_tuples_inserted(oid))::bigint AS tuplesinserted,
+				pg_catalog.sum(pg_stat_get_tuples_updated(oid))::bigint AS tuplesupdated,
+				pg_catalog.sum(pg_stat_get_tuples_deleted(oid))::bigint AS tuplesdeleted
+			FROM gp_dist_random('pg_class')
+			WHERE relkind = 'r'
+			GROUP BY oid
+		) stats ON c.oid = stats.oid
+		LEFT JOIN ( SELECT lo.objid,
+				MAX(lo.statime) AS lastddltimestamp
+			FROM pg_stat_last_operation lo
+			WHERE lo.staactionname IN ('CREATE', 'ALTER', 'TRUNCATE')
+			GROUP BY lo.objid
+		) lastop ON c.oid = lastop.objid
+	WHERE a.amname = 'heap'
+		AND c.relkind = 'r'
+		AND %s`, SchemaFilterClause("n"))
+
+	var results []struct {
+		TableFQN         string
+		Relfilenode      uint32
+		TuplesInserted   int64
+		TuplesUpdated    int64
+		TuplesDeleted    int64
+		LastDDLTimestamp string
+	}
+	err := connectionPool.Select(&results, query)
+	gplog.FatalOnError(err)
+	heapTableEntries := make(map[string]toc.HeapEntry)
+	for _, result := range results {
+		heapTableEntries[result.TableFQN] = toc.HeapEntry{
+			Relfilenode:      result.Relfilenode,
+			TuplesInserted:   result.TuplesInserted,
+			TuplesUpdated:    result.TuplesUpdated,
+			TuplesDeleted:    result.TuplesDeleted,
+			LastDDLTimestamp: result.LastDDLTimestamp,
+		}
+	}
+
+	return heapTableEntries, statsTimestamp
+}
+
+/*
+ * The statistics of a segment are lost when they are reset or when its server
+ * is restarted after a crash or a failover to its mirror, either of which
+ * changes the latest of its statistics reset time and its server start time.
+ */
+func getHeapStatsTimestamp(connectionPool *dbconn.DBConn) (string, bool) {
+	query := `
+	SELECT COALESCE(pg_catalog.max(GREATEST(pg_stat_get_db_stat_reset_time(d.oid), pg_postmaster_start_time()))::text, '') AS statstimestamp,
+		COALESCE(bool_and(current_setting('track_counts')::bool), false) AS trackcounts
+	FROM gp_dist_random('pg_database') d
+	WHERE d.datname = current_database()`
+
+	var result struct {
+		StatsTimestamp string
+		TrackCounts    bool
+	}
+	err := connectionPool.Get(&result, query)
+	gplog.FatalOnError(err)
+
+	return result.StatsTimestamp, result.TrackCounts && result.StatsTimestamp != ""
+}
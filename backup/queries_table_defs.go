@@ -378,6 +378,39 @@ func GetTableStorage(connectionPool *dbconn.DBConn) (map[uint32]string, map[uint
 	return tableSpaces, relOptions
 }
 
+/*
+ * Estimates the size of each table from the page counts last recorded by
+ * VACUUM or ANALYZE, which is much cheaper than calculating the size of each
+ * table on every segment.  The data of a partitioned table is in its leaves.
+ */
+func GetTableSizeEstimates(connectionPool *dbconn.DBConn, tables []Table) map[uint32]int64 {
+	resultMap := make(map[uint32]int64)
+	if len(tables) == 0 {
+		return resultMap
+	}
+	oids := make([]string, 0, len(tables))
+	for _, table := range tables {
+		oids = append(oids, fmt.Sprintf("%d", table.Oid))
+	}
+	query := fmt.Sprintf(`
+	SELECT c.oid,
+		(CASE WHEN c.relkind = 'p' THEN
+			(SELECT coalesce(sum(l.relpages), 0) FROM pg_partition_tree(c.oid) t JOIN pg_class l ON t.relid = l.oid WHERE t.isleaf)
+		ELSE c.relpages END)::bigint * current_setting('block_size')::bigint AS size
+	FROM pg_class c
+	WHERE c.oid IN (%s)`, strings.Join(oids, ", "))
+	var results []struct {
+		Oid  uint32
+		Size int64
+	}
+	err := connectionPool.Select(&results, query)
+	gplog.FatalOnError(err)
+	for _, result := range results {
+		resultMap[result.Oid] = result.Size
+	}
+	return resultMap
+}
+
 func GetUnloggedTables(connectionPool *dbconn.DBConn) map[uint32]bool {
 	query := `SELECT oid FROM pg_class WHERE relpersistence = 'u'`
 	var results []struct {
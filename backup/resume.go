@@ -0,0 +1,322 @@
+package backup
+
+/*
+ * This file contains functions related to resuming a backup that failed while
+ * backing up table data, given by --resume.
+ *
+ * A multi-file backup records each table whose data was completely written in
+ * its data progress file.  When the backup is resumed, metadata is backed up
+ * again in full under a new snapshot, and only the tables that are not in the
+ * progress file, whose columns changed or whose data file is missing on a
+ * segment are copied again.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"maps"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gpbackup/history"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+func prepareToResumeBackup() {
+	timestamp := globalFPInfo.Timestamp
+	gplog.Info("Resuming backup %s", timestamp)
+	_, err := os.Stat(globalFPInfo.GetDataProgressFilePath())
+	if err != nil {
+		gplog.Fatal(errors.Errorf("Backup %s cannot be resumed, as there is no record of its table data in %s",
+			timestamp, globalFPInfo.GetDirForContent(-1)), "")
+	}
+
+	// The options of the failed attempt are only recorded in the history file
+	historyFilename := globalFPInfo.GetBackupHistoryFilePath()
+	if _, err = os.Stat(historyFilename); err != nil {
+		gplog.Fatal(errors.Errorf("Backup %s cannot be resumed, as history file %s cannot be read: %v", timestamp, historyFilename, err), "")
+	}
+	backupHistory, _, err := history.NewHistory(historyFilename)
+	gplog.FatalOnError(err)
+	recorded := false
+	for _, backupConfig := range backupHistory.BackupConfigs {
+		if backupConfig.Timestamp == timestamp {
+			ValidateResumedBackupConfig(&backupConfig, &backupReport.BackupConfig)
+			recorded = true
+		}
+	}
+	if !recorded {
+		gplog.Fatal(errors.Errorf("Backup %s cannot be resumed, as it is not recorded in history file %s", timestamp, historyFilename), "")
+	}
+	err = history.RemoveBackupFromHistory(historyFilename, timestamp)
+	gplog.FatalOnError(err)
+
+	// The metadata files of the failed attempt are read-only and are written again from scratch
+	for _, filename := range []string{globalFPInfo.GetMetadataFilePath(), globalFPInfo.GetTOCFilePath(),
+		globalFPInfo.GetStatisticsFilePath(), globalFPInfo.GetConfigFilePath(), globalFPInfo.GetBackupReportFilePath(),
+		globalFPInfo.GetBackupJSONReportFilePath()} {
+		err = os.Remove(filename)
+		if err != nil && !os.IsNotExist(err) {
+			gplog.Fatal(errors.Errorf("Unable to remove %s from the failed backup: %v", filename, err), "")
+		}
+	}
+}
+
+/*
+ * A resumed backup keeps the data files written by the failed attempt, so it
+ * must back up the same tables in the same way for the result to be one
+ * consistent backup.
+ */
+func ValidateResumedBackupConfig(backupConfig *history.BackupConfig, currentConfig *history.BackupConfig) {
+	if !backupConfig.Failed() {
+		gplog.Fatal(errors.Errorf("Backup %s did not fail and cannot be resumed", backupConfig.Timestamp), "")
+	}
+	if backupConfig.Deleted() {
+		gplog.Fatal(errors.Errorf("Backup %s has been deleted and cannot be resumed", backupConfig.Timestamp), "")
+	}
+	if backupConfig.DatabaseName != currentConfig.DatabaseName {
+		gplog.Fatal(errors.Errorf("Backup %s is a backup of database %s and cannot be resumed against database %s",
+			backupConfig.Timestamp, backupConfig.DatabaseName, currentConfig.DatabaseName), "")
+	}
+	if backupConfig.Compressed != currentConfig.Compressed || (currentConfig.Compressed && backupConfig.CompressionType != currentConfig.CompressionType) {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same compression options it was started with", backupConfig.Timestamp), "")
+	}
+	if backupConfig.WithChecksums != currentConfig.WithChecksums {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same --verify option it was started with", backupConfig.Timestamp), "")
+	}
+	if !maps.Equal(backupConfig.RowFilters, currentConfig.RowFilters) {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same row filters it was started with", backupConfig.Timestamp), "")
+	}
+	columnRulesEqual := func(rules1, rules2 map[string]utils.MaskingRule) bool { return maps.Equal(rules1, rules2) }
+	if !maps.EqualFunc(backupConfig.MaskingRules, currentConfig.MaskingRules, columnRulesEqual) {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same masking rules it was started with", backupConfig.Timestamp), "")
+	}
+	if backupConfig.SamplePercent != currentConfig.SamplePercent || backupConfig.SampleForeignKeys != currentConfig.SampleForeignKeys {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same sampling options it was started with", backupConfig.Timestamp), "")
+	}
+	if !backupConfig.SelectsSameData(currentConfig) {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same table and schema filters it was started with", backupConfig.Timestamp), "")
+	}
+	if backupConfig.LeafPartitionData != currentConfig.LeafPartitionData {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same --%s option it was started with", backupConfig.Timestamp, options.LEAF_PARTITION_DATA), "")
+	}
+	if backupConfig.Incremental != currentConfig.Incremental || backupConfig.Differential != currentConfig.Differential {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same --%s and --%s options it was started with", backupConfig.Timestamp, options.INCREMENTAL, options.DIFFERENTIAL), "")
+	}
+	if backupConfig.WithStatistics != currentConfig.WithStatistics {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same --%s option it was started with", backupConfig.Timestamp, options.WITH_STATS), "")
+	}
+	if backupConfig.WithoutGlobals != currentConfig.WithoutGlobals {
+		gplog.Fatal(errors.Errorf("Backup %s must be resumed with the same --%s option it was started with", backupConfig.Timestamp, options.WITHOUT_GLOBALS), "")
+	}
+}
+
+/*
+ * The row count of a table whose data was completely written, along with a
+ * signature of its columns at the time, so that a table altered since then is
+ * copied again rather than mixing data files of different column layouts.
+ */
+type TableDataProgress struct {
+	RowsCopied int64
+	Columns    string
+}
+
+/*
+ * A table that was dropped and recreated since the failed attempt has a new
+ * oid, so only the columns of a table need to be compared to detect a schema
+ * change; its stale data file is removed by removeStaleDataFiles.
+ */
+func GetColumnsSignature(table Table) string {
+	hash := sha256.New()
+	for _, column := range table.ColumnDefs {
+		_, _ = fmt.Fprintf(hash, "%d %s %s\n", column.Num, column.Name, column.Type)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+/*
+ * Each line of the data progress file holds the oid of a table, the number of
+ * rows copied for it and the signature of its columns.  Only lines ending in a
+ * newline are complete, so a line that was only partially written when the
+ * backup failed is ignored and that table is copied again.
+ */
+func ParseDataProgress(contents string) map[uint32]TableDataProgress {
+	progressMap := make(map[uint32]TableDataProgress)
+	lines := strings.Split(contents, "\n")
+	for _, line := range lines[:len(lines)-1] {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		oid, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			continue
+		}
+		rowsCopied, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		progressMap[uint32(oid)] = TableDataProgress{RowsCopied: rowsCopied, Columns: fields[2]}
+	}
+	return progressMap
+}
+
+/*
+ * Returns the oids for which a data file exists in the given directory
+ * listing, one file name per line.
+ */
+func ParseDataFileOids(listing string, contentID int, timestamp string, extension string) map[uint32]bool {
+	dataFileRegex := regexp.MustCompile(fmt.Sprintf(`^gpbackup_%d_%s_(\d+)%s$`, contentID, timestamp, regexp.QuoteMeta(extension)))
+	oids := make(map[uint32]bool)
+	for _, filename := range strings.Split(listing, "\n") {
+		matches := dataFileRegex.FindStringSubmatch(strings.TrimSpace(filename))
+		if matches == nil {
+			continue
+		}
+		oid, err := strconv.ParseUint(matches[1], 10, 32)
+		if err == nil {
+			oids[uint32(oid)] = true
+		}
+	}
+	return oids
+}
+
+/*
+ * A table only counts as backed up if it is recorded in the progress file with
+ * its current columns and its data file exists on every segment.  The row
+ * counts of those tables are returned so their TOC entries can be written
+ * along with the others.
+ */
+func FindTablesToResume(tables []Table, progressMap map[uint32]TableDataProgress, segmentOids map[int]map[uint32]bool) ([]Table, map[uint32]int64) {
+	remainingTables := make([]Table, 0)
+	completedRowsCopied := make(map[uint32]int64)
+	for _, table := range tables {
+		progress, recorded := progressMap[table.Oid]
+		if recorded && progress.Columns != GetColumnsSignature(table) {
+			gplog.Info("Columns of table %s changed since the failed backup, so its data will be backed up again", table.FQN())
+			recorded = false
+		}
+		onAllSegments := len(segmentOids) > 0
+		for _, oids := range segmentOids {
+			if !oids[table.Oid] {
+				onAllSegments = false
+				break
+			}
+		}
+		if recorded && onAllSegments {
+			completedRowsCopied[table.Oid] = progress.RowsCopied
+		} else {
+			remainingTables = append(remainingTables, table)
+		}
+	}
+	return remainingTables, completedRowsCopied
+}
+
+func getDataFileOidsOnSegments() map[int]map[uint32]bool {
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Listing data files of the failed backup", cluster.ON_SEGMENTS, func(contentID int) string {
+		return fmt.Sprintf("ls -1 %s", globalFPInfo.GetDirForContent(contentID))
+	})
+	globalCluster.CheckClusterError(remoteOutput, "Unable to list data files of the failed backup", func(contentID int) string {
+		return fmt.Sprintf("Unable to list backup directory %s", globalFPInfo.GetDirForContent(contentID))
+	})
+	extension := utils.GetPipeThroughProgram().Extension
+	segmentOids := make(map[int]map[uint32]bool)
+	for _, command := range remoteOutput.Commands {
+		segmentOids[command.Content] = ParseDataFileOids(command.Stdout, command.Content, globalFPInfo.Timestamp, extension)
+	}
+	return segmentOids
+}
+
+/*
+ * Data files for tables that are no longer in the backup set, such as tables
+ * dropped since the failed attempt, would otherwise be left in the backup and
+ * counted when the backup is restored.
+ */
+func removeStaleDataFiles(tables []Table, segmentOids map[int]map[uint32]bool) {
+	backupSet := make(map[uint32]bool, len(tables))
+	for _, table := range tables {
+		backupSet[table.Oid] = true
+	}
+	extension := utils.GetPipeThroughProgram().Extension
+	remoteOutput := globalCluster.GenerateAndExecuteCommand("Removing stale data files of the failed backup", cluster.ON_SEGMENTS, func(contentID int) string {
+		staleFiles := make([]string, 0)
+		for oid := range segmentOids[contentID] {
+			if !backupSet[oid] {
+				dataFile := globalFPInfo.GetTableBackupFilePath(contentID, oid, extension, false)
+				staleFiles = append(staleFiles, dataFile, dataFile+utils.ChecksumFileExtension)
+			}
+		}
+		if len(staleFiles) == 0 {
+			return ""
+		}
+		return fmt.Sprintf("rm -f %s", strings.Join(staleFiles, " "))
+	})
+	globalCluster.CheckClusterError(remoteOutput, "Unable to remove stale data files of the failed backup", func(contentID int) string {
+		return fmt.Sprintf("Unable to remove stale data files in %s", globalFPInfo.GetDirForContent(contentID))
+	})
+}
+
+/*
+ * Returns the tables whose data must still be backed up, along with the row
+ * counts of the tables that were completely backed up before the failure.
+ */
+func resumeDataBackup(tables []Table) ([]Table, map[uint32]int64) {
+	contents, err := ioutil.ReadFile(globalFPInfo.GetDataProgressFilePath())
+	gplog.FatalOnError(err)
+	segmentOids := getDataFileOidsOnSegments()
+	removeStaleDataFiles(tables, segmentOids)
+	remainingTables, completedRowsCopied := FindTablesToResume(tables, ParseDataProgress(string(contents)), segmentOids)
+	gplog.Info("Skipping %d table(s) whose data was backed up before the failure", len(completedRowsCopied))
+	return remainingTables, completedRowsCopied
+}
+
+func openDataProgressFile() {
+	var err error
+	dataProgressFile, err = os.OpenFile(globalFPInfo.GetDataProgressFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	gplog.FatalOnError(err)
+}
+
+func closeDataProgressFile() {
+	if dataProgressFile == nil {
+		return
+	}
+	err := dataProgressFile.Close()
+	if err != nil {
+		gplog.Warn("Unable to close data progress file: %v", err)
+	}
+	dataProgressFile = nil
+}
+
+/*
+ * Failing to record a table only means that its data is copied again if the
+ * backup is resumed, so errors are logged rather than failing the backup.
+ */
+func recordTableDataComplete(table Table, rowsCopied int64) {
+	if dataProgressFile == nil {
+		return
+	}
+	dataProgressMutex.Lock()
+	defer dataProgressMutex.Unlock()
+	_, err := fmt.Fprintf(dataProgressFile, "%d %d %s\n", table.Oid, rowsCopied, GetColumnsSignature(table))
+	if err == nil {
+		err = dataProgressFile.Sync()
+	}
+	if err != nil {
+		gplog.Warn("Unable to record completed data for table %s: %v", table.FQN(), err)
+	}
+}
+
+func removeDataProgressFile() {
+	err := os.Remove(globalFPInfo.GetDataProgressFilePath())
+	if err != nil && !os.IsNotExist(err) {
+		gplog.Warn("Unable to remove data progress file: %v", err)
+	}
+}
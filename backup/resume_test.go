@@ -0,0 +1,112 @@
+package backup_test
+
+import (
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/cloudberrydb/gpbackup/backup"
+	"github.com/cloudberrydb/gpbackup/history"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup/resume tests", func() {
+	Describe("ParseDataProgress", func() {
+		It("parses the oid, row count and columns of each completed table", func() {
+			progressMap := backup.ParseDataProgress("16384 10 abc123\n16390 0 def456\n")
+			Expect(progressMap).To(Equal(map[uint32]backup.TableDataProgress{16384: {RowsCopied: 10, Columns: "abc123"}, 16390: {RowsCopied: 0, Columns: "def456"}}))
+		})
+		It("ignores a line that was only partially written", func() {
+			progressMap := backup.ParseDataProgress("16384 10 abc123\n1639")
+			Expect(progressMap).To(Equal(map[uint32]backup.TableDataProgress{16384: {RowsCopied: 10, Columns: "abc123"}}))
+		})
+		It("ignores a last line that is not terminated by a newline", func() {
+			progressMap := backup.ParseDataProgress("16384 10 abc123\n16390 1 def456")
+			Expect(progressMap).To(Equal(map[uint32]backup.TableDataProgress{16384: {RowsCopied: 10, Columns: "abc123"}}))
+		})
+	})
+	Describe("ParseDataFileOids", func() {
+		It("returns the oids of the data files for the segment and timestamp", func() {
+			listing := "gpbackup_0_20170101010101_16384.gz\ngpbackup_0_20170101010101_16384.gz.sha256\n" +
+				"gpbackup_0_20170101010101_16390.gz\ngpbackup_0_20170101010101_pipe_123_error\n" +
+				"gpbackup_1_20170101010101_16400.gz\ngpbackup_0_20170101010101_16410\n"
+			oids := backup.ParseDataFileOids(listing, 0, "20170101010101", ".gz")
+			Expect(oids).To(Equal(map[uint32]bool{16384: true, 16390: true}))
+		})
+	})
+	Describe("FindTablesToResume", func() {
+		table1 := backup.Table{Relation: backup.Relation{Oid: 1, Schema: "public", Name: "table1"},
+			TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Num: 1, Name: "i", Type: "integer"}}}}
+		table2 := backup.Table{Relation: backup.Relation{Oid: 2, Schema: "public", Name: "table2"}}
+		table3 := backup.Table{Relation: backup.Relation{Oid: 3, Schema: "public", Name: "table3"}}
+		tables := []backup.Table{table1, table2, table3}
+
+		It("skips only tables that are recorded and have a data file on every segment", func() {
+			progressMap := map[uint32]backup.TableDataProgress{
+				1: {RowsCopied: 100, Columns: backup.GetColumnsSignature(table1)},
+				2: {RowsCopied: 200, Columns: backup.GetColumnsSignature(table2)},
+			}
+			segmentOids := map[int]map[uint32]bool{
+				0: {1: true, 2: true, 3: true},
+				1: {1: true, 3: true},
+			}
+			remainingTables, completedRowsCopied := backup.FindTablesToResume(tables, progressMap, segmentOids)
+			Expect(remainingTables).To(Equal([]backup.Table{table2, table3}))
+			Expect(completedRowsCopied).To(Equal(map[uint32]int64{1: 100}))
+		})
+		It("backs up every table when no data files exist", func() {
+			progressMap := map[uint32]backup.TableDataProgress{1: {RowsCopied: 100, Columns: backup.GetColumnsSignature(table1)}}
+			remainingTables, completedRowsCopied := backup.FindTablesToResume(tables, progressMap, map[int]map[uint32]bool{})
+			Expect(remainingTables).To(Equal(tables))
+			Expect(completedRowsCopied).To(BeEmpty())
+		})
+		It("backs up a table again when its columns changed since the failed backup", func() {
+			alteredTable1 := table1
+			alteredTable1.ColumnDefs = []backup.ColumnDefinition{{Num: 1, Name: "i", Type: "bigint"}}
+			progressMap := map[uint32]backup.TableDataProgress{
+				1: {RowsCopied: 100, Columns: backup.GetColumnsSignature(table1)},
+				3: {RowsCopied: 300, Columns: backup.GetColumnsSignature(table3)},
+			}
+			segmentOids := map[int]map[uint32]bool{0: {1: true, 3: true}}
+			remainingTables, completedRowsCopied := backup.FindTablesToResume([]backup.Table{alteredTable1, table3}, progressMap, segmentOids)
+			Expect(remainingTables).To(Equal([]backup.Table{alteredTable1}))
+			Expect(completedRowsCopied).To(Equal(map[uint32]int64{3: 300}))
+		})
+	})
+	Describe("ValidateResumedBackupConfig", func() {
+		var failedConfig, currentConfig history.BackupConfig
+		BeforeEach(func() {
+			failedConfig = history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170101010101", Compressed: true, CompressionType: "gzip",
+				IncludeSchemas: []string{"public"}, LeafPartitionData: true, Status: history.BackupStatusFailed}
+			currentConfig = failedConfig
+			currentConfig.Status = ""
+		})
+		It("accepts a backup resumed with the options it was started with", func() {
+			backup.ValidateResumedBackupConfig(&failedConfig, &currentConfig)
+		})
+		It("rejects a backup resumed with other table or schema filters", func() {
+			currentConfig.IncludeSchemas = []string{"other"}
+			defer testhelper.ShouldPanicWithMessage("Backup 20170101010101 must be resumed with the same table and schema filters it was started with")
+			backup.ValidateResumedBackupConfig(&failedConfig, &currentConfig)
+		})
+		It("rejects a full backup resumed as an incremental backup", func() {
+			currentConfig.Incremental = true
+			defer testhelper.ShouldPanicWithMessage("Backup 20170101010101 must be resumed with the same --incremental and --differential options it was started with")
+			backup.ValidateResumedBackupConfig(&failedConfig, &currentConfig)
+		})
+		It("rejects a backup resumed without --leaf-partition-data", func() {
+			currentConfig.LeafPartitionData = false
+			defer testhelper.ShouldPanicWithMessage("Backup 20170101010101 must be resumed with the same --leaf-partition-data option it was started with")
+			backup.ValidateResumedBackupConfig(&failedConfig, &currentConfig)
+		})
+		It("rejects a backup resumed with other statistics or globals options", func() {
+			currentConfig.WithStatistics = true
+			defer testhelper.ShouldPanicWithMessage("Backup 20170101010101 must be resumed with the same --with-stats option it was started with")
+			backup.ValidateResumedBackupConfig(&failedConfig, &currentConfig)
+		})
+		It("rejects a backup that did not fail", func() {
+			failedConfig.Status = history.BackupStatusSucceed
+			defer testhelper.ShouldPanicWithMessage("Backup 20170101010101 did not fail and cannot be resumed")
+			backup.ValidateResumedBackupConfig(&failedConfig, &currentConfig)
+		})
+	})
+})
@@ -0,0 +1,74 @@
+package backup
+
+/*
+ * This file contains functions related to pruning backups that fall outside
+ * of the retention policy given by --retain-full and --retain-days.
+ */
+
+import (
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/filepath"
+	"github.com/cloudberrydb/gpbackup/history"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/utils"
+)
+
+/*
+ * Failing to prune an old backup does not make the current backup fail, so
+ * errors are logged as warnings and the affected backup is left untouched in
+ * the history file to be retried by the next run.
+ */
+func pruneExpiredBackups(historyFilename string) {
+	policy := history.RetentionPolicy{
+		RetainFull: MustGetFlagInt(options.RETAIN_FULL),
+		RetainDays: MustGetFlagInt(options.RETAIN_DAYS),
+	}
+	if !policy.IsSet() || backupReport == nil {
+		return
+	}
+	backupHistory, _, err := history.NewHistory(historyFilename)
+	if err != nil {
+		gplog.Warn("Unable to read history file %s, skipping pruning of old backups: %v", historyFilename, err)
+		return
+	}
+	expiredBackups := backupHistory.FindExpiredBackups(&backupReport.BackupConfig, policy, operating.System.Now())
+	if len(expiredBackups) == 0 {
+		gplog.Verbose("No backups fall outside of the retention policy")
+		return
+	}
+
+	gplog.Info("Deleting %d backup(s) that fall outside of the retention policy", len(expiredBackups))
+	for _, expiredBackup := range expiredBackups {
+		deleteExpiredBackup(historyFilename, expiredBackup)
+	}
+}
+
+func deleteExpiredBackup(historyFilename string, expiredBackup history.BackupConfig) {
+	timestamp := expiredBackup.Timestamp
+	gplog.Verbose("Deleting backup %s", timestamp)
+	if pluginConfig != nil {
+		err := pluginConfig.DeleteBackup(timestamp)
+		if err != nil {
+			gplog.Warn("Unable to delete backup %s using plugin: %v", timestamp, err)
+			return
+		}
+	}
+	// The expired backup may have been taken into another directory than the current one
+	segPrefix, err := filepath.ParseSegPrefix(expiredBackup.BackupDir)
+	if err != nil {
+		gplog.Warn("Unable to locate the files of backup %s: %v", timestamp, err)
+		return
+	}
+	fpInfo := filepath.NewFilePathInfo(globalCluster, expiredBackup.BackupDir, timestamp, segPrefix)
+	if !utils.DeleteBackupDirectoriesOnAllHosts(globalCluster, fpInfo) {
+		gplog.Warn("Unable to remove all files for backup %s; it will be retried during the next backup", timestamp)
+		return
+	}
+	err = history.MarkBackupDeletedInHistory(historyFilename, timestamp)
+	if err != nil {
+		gplog.Warn("Unable to mark backup %s as deleted in history file: %v", timestamp, err)
+		return
+	}
+	gplog.Info("Deleted backup %s", timestamp)
+}
@@ -0,0 +1,158 @@
+package backup
+
+/*
+ * This file contains structs and functions related to backing up a sample of
+ * the rows of each table, given by --sample-percent.
+ *
+ * A row is sampled if a hash of its text falls in the sampled fraction of
+ * buckets, rather than by a random TABLESAMPLE, so the same rows are sampled
+ * each time the predicate is evaluated.  With --sample-follow-foreign-keys, a
+ * table with foreign keys to other tables being backed up is not sampled on
+ * its own; instead a row of the table is backed up only if each row it
+ * references is backed up, so the rows of the sample still reference each
+ * other as they would in the database.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gpbackup/options"
+)
+
+const sampleHashBuckets = 1000000
+
+type ForeignKey struct {
+	Columns           []string
+	ReferencedTable   string
+	ReferencedColumns []string
+}
+
+type ForeignKeyColumn struct {
+	Oid                  uint32
+	OwningTable          string
+	ReferencedTable      string
+	ColumnName           string
+	ReferencedColumnName string
+}
+
+/*
+ * The columns of each foreign key are read from conkey and confkey, one row
+ * per pair of columns in the order they are paired, rather than parsed from
+ * pg_get_constraintdef, whose quoted identifiers may contain any character.
+ */
+func GetForeignKeys(connectionPool *dbconn.DBConn, tables []Table) map[string][]ForeignKey {
+	oidList := make([]string, 0, len(tables))
+	for _, table := range tables {
+		oidList = append(oidList, fmt.Sprintf("%d", table.Oid))
+	}
+	query := fmt.Sprintf(`
+	SELECT con.oid,
+		quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS owningtable,
+		quote_ident(rn.nspname) || '.' || quote_ident(rc.relname) AS referencedtable,
+		quote_ident(a.attname) AS columnname,
+		quote_ident(ra.attname) AS referencedcolumnname
+	FROM pg_constraint con
+		JOIN pg_class c ON con.conrelid = c.oid
+		JOIN pg_namespace n ON c.relnamespace = n.oid
+		JOIN pg_class rc ON con.confrelid = rc.oid
+		JOIN pg_namespace rn ON rc.relnamespace = rn.oid
+		CROSS JOIN unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refattnum, position)
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+		JOIN pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refattnum
+	WHERE con.contype = 'f'
+		AND con.conrelid IN (%s)
+	ORDER BY con.oid, k.position`, strings.Join(oidList, ","))
+
+	results := make([]ForeignKeyColumn, 0)
+	err := connectionPool.Select(&results, query)
+	gplog.FatalOnError(err)
+
+	foreignKeys := make(map[string][]ForeignKey)
+	for i, column := range results {
+		tableForeignKeys := foreignKeys[column.OwningTable]
+		if i == 0 || column.Oid != results[i-1].Oid {
+			tableForeignKeys = append(tableForeignKeys, ForeignKey{ReferencedTable: column.ReferencedTable})
+		}
+		foreignKey := &tableForeignKeys[len(tableForeignKeys)-1]
+		foreignKey.Columns = append(foreignKey.Columns, column.ColumnName)
+		foreignKey.ReferencedColumns = append(foreignKey.ReferencedColumns, column.ReferencedColumnName)
+		foreignKeys[column.OwningTable] = tableForeignKeys
+	}
+	for _, tableForeignKeys := range foreignKeys {
+		sort.SliceStable(tableForeignKeys, func(i, j int) bool {
+			return strings.Join(tableForeignKeys[i].Columns, ",") < strings.Join(tableForeignKeys[j].Columns, ",")
+		})
+	}
+	return foreignKeys
+}
+
+func getSampleHashPredicate(table Table, ref string, percent float64) string {
+	columns := make([]string, 0)
+	for _, col := range table.ColumnDefs {
+		if col.AttGenerated == "" {
+			columns = append(columns, fmt.Sprintf("%s.%s", ref, col.Name))
+		}
+	}
+	threshold := int64(percent * sampleHashBuckets / 100)
+	return fmt.Sprintf("abs(hashtext(ROW(%s)::text)::bigint) %% %d < %d", strings.Join(columns, ","), sampleHashBuckets, threshold)
+}
+
+/*
+ * Columns of the table are qualified with ref, which is the FQN of the table
+ * when it is the table being copied out, or an alias when it is referenced by
+ * another table.  Tables already in visited are not followed again, so that a
+ * table referencing itself or a cycle of foreign keys is sampled on its own.
+ */
+func getSamplePredicate(table Table, ref string, percent float64, tablesByFQN map[string]Table, foreignKeys map[string][]ForeignKey, visited map[string]bool) string {
+	visited[table.FQN()] = true
+	defer delete(visited, table.FQN())
+
+	conditions := make([]string, 0)
+	for _, foreignKey := range foreignKeys[table.FQN()] {
+		referencedTable, ok := tablesByFQN[foreignKey.ReferencedTable]
+		if !ok || visited[foreignKey.ReferencedTable] {
+			continue
+		}
+		alias := fmt.Sprintf("gpbackup_sample_%d", len(visited))
+		nullChecks := make([]string, 0, len(foreignKey.Columns))
+		joinConditions := make([]string, 0, len(foreignKey.Columns))
+		for i, column := range foreignKey.Columns {
+			nullChecks = append(nullChecks, fmt.Sprintf("%s.%s IS NULL", ref, column))
+			joinConditions = append(joinConditions, fmt.Sprintf("%s.%s = %s.%s", alias, foreignKey.ReferencedColumns[i], ref, column))
+		}
+		joinConditions = append(joinConditions, getSamplePredicate(referencedTable, alias, percent, tablesByFQN, foreignKeys, visited))
+		// As with a foreign key, a row with a NULL in any of its columns references no row
+		conditions = append(conditions, fmt.Sprintf("(%s OR EXISTS (SELECT 1 FROM %s %s WHERE %s))",
+			strings.Join(nullChecks, " OR "), referencedTable.FQN(), alias, strings.Join(joinConditions, " AND ")))
+	}
+	if len(conditions) == 0 {
+		return getSampleHashPredicate(table, ref, percent)
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func GetSamplePredicates(tables []Table, percent float64, foreignKeys map[string][]ForeignKey) map[string]string {
+	tablesByFQN := make(map[string]Table, len(tables))
+	for _, table := range tables {
+		tablesByFQN[table.FQN()] = table
+	}
+	predicates := make(map[string]string, len(tables))
+	for _, table := range tables {
+		predicates[table.FQN()] = getSamplePredicate(table, table.FQN(), percent, tablesByFQN, foreignKeys, make(map[string]bool))
+	}
+	return predicates
+}
+
+func initializeSampling(dataTables []Table) {
+	percent := MustGetFlagFloat64(options.SAMPLE_PERCENT)
+	gplog.Info("Backing up a %g%% sample of the rows of each table", percent)
+	foreignKeys := make(map[string][]ForeignKey)
+	if MustGetFlagBool(options.SAMPLE_FOREIGN_KEYS) && len(dataTables) > 0 {
+		foreignKeys = GetForeignKeys(connectionPool, dataTables)
+	}
+	samplePredicates = GetSamplePredicates(dataTables, percent, foreignKeys)
+}
@@ -0,0 +1,81 @@
+package backup_test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gpbackup/backup"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("backup/sample tests", func() {
+	Describe("GetForeignKeys", func() {
+		It("pairs the columns of each foreign key in order, whatever characters their names contain", func() {
+			header := []string{"oid", "owningtable", "referencedtable", "columnname", "referencedcolumnname"}
+			fakeRows := sqlmock.NewRows(header).
+				AddRow("1", `public."child, (1)"`, "public.parent", `"a, b"`, "x").
+				AddRow("1", `public."child, (1)"`, "public.parent", "c", `"y("`).
+				AddRow("2", `public."child, (1)"`, `"other(schema"."parent, 2"`, "d", "z")
+			mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(fakeRows)
+
+			foreignKeys := backup.GetForeignKeys(connectionPool, []backup.Table{{Relation: backup.Relation{Oid: 3, Schema: "public", Name: `"child, (1)"`}}})
+
+			Expect(foreignKeys).To(Equal(map[string][]backup.ForeignKey{
+				`public."child, (1)"`: {
+					{Columns: []string{`"a, b"`, "c"}, ReferencedTable: "public.parent", ReferencedColumns: []string{"x", `"y("`}},
+					{Columns: []string{"d"}, ReferencedTable: `"other(schema"."parent, 2"`, ReferencedColumns: []string{"z"}},
+				},
+			}))
+		})
+	})
+	Describe("GetSamplePredicates", func() {
+		parent := backup.Table{
+			Relation:        backup.Relation{Schema: "public", Name: "parent"},
+			TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Name: "id"}, {Name: "name"}, {Name: "upper_name", AttGenerated: "s"}}},
+		}
+		child := backup.Table{
+			Relation:        backup.Relation{Schema: "public", Name: "child"},
+			TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Name: "id"}, {Name: "parent_id"}}},
+		}
+		grandchild := backup.Table{
+			Relation:        backup.Relation{Schema: "public", Name: "grandchild"},
+			TableDefinition: backup.TableDefinition{ColumnDefs: []backup.ColumnDefinition{{Name: "child_id"}}},
+		}
+		It("samples each table on its own without foreign keys", func() {
+			predicates := backup.GetSamplePredicates([]backup.Table{parent, child}, 10, map[string][]backup.ForeignKey{})
+
+			Expect(predicates).To(Equal(map[string]string{
+				"public.parent": "abs(hashtext(ROW(public.parent.id,public.parent.name)::text)::bigint) % 1000000 < 100000",
+				"public.child":  "abs(hashtext(ROW(public.child.id,public.child.parent_id)::text)::bigint) % 1000000 < 100000",
+			}))
+		})
+		It("samples the rows of a table that reference sampled rows", func() {
+			foreignKeys := map[string][]backup.ForeignKey{
+				"public.child":      {{Columns: []string{"parent_id"}, ReferencedTable: "public.parent", ReferencedColumns: []string{"id"}}},
+				"public.grandchild": {{Columns: []string{"child_id"}, ReferencedTable: "public.child", ReferencedColumns: []string{"id"}}},
+			}
+			predicates := backup.GetSamplePredicates([]backup.Table{parent, child, grandchild}, 0.5, foreignKeys)
+
+			Expect(predicates["public.parent"]).To(Equal("abs(hashtext(ROW(public.parent.id,public.parent.name)::text)::bigint) % 1000000 < 5000"))
+			Expect(predicates["public.child"]).To(Equal("(public.child.parent_id IS NULL OR EXISTS (SELECT 1 FROM public.parent gpbackup_sample_1 " +
+				"WHERE gpbackup_sample_1.id = public.child.parent_id AND " +
+				"abs(hashtext(ROW(gpbackup_sample_1.id,gpbackup_sample_1.name)::text)::bigint) % 1000000 < 5000))"))
+			Expect(predicates["public.grandchild"]).To(Equal("(public.grandchild.child_id IS NULL OR EXISTS (SELECT 1 FROM public.child gpbackup_sample_1 " +
+				"WHERE gpbackup_sample_1.id = public.grandchild.child_id AND " +
+				"(gpbackup_sample_1.parent_id IS NULL OR EXISTS (SELECT 1 FROM public.parent gpbackup_sample_2 " +
+				"WHERE gpbackup_sample_2.id = gpbackup_sample_1.parent_id AND " +
+				"abs(hashtext(ROW(gpbackup_sample_2.id,gpbackup_sample_2.name)::text)::bigint) % 1000000 < 5000))))"))
+		})
+		It("samples a table on its own when its only foreign key references itself or a table not being backed up", func() {
+			foreignKeys := map[string][]backup.ForeignKey{
+				"public.child": {
+					{Columns: []string{"parent_id"}, ReferencedTable: "public.child", ReferencedColumns: []string{"id"}},
+					{Columns: []string{"parent_id"}, ReferencedTable: "public.other", ReferencedColumns: []string{"id"}},
+				},
+			}
+			predicates := backup.GetSamplePredicates([]backup.Table{child}, 10, foreignKeys)
+
+			Expect(predicates["public.child"]).To(Equal("abs(hashtext(ROW(public.child.id,public.child.parent_id)::text)::bigint) % 1000000 < 100000"))
+		})
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"github.com/cloudberrydb/gpbackup/filepath"
 	"github.com/cloudberrydb/gpbackup/history"
 	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/report"
 	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
@@ -104,16 +105,50 @@ func validateFlagCombinations(flags *pflag.FlagSet) {
 	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.LEAF_PARTITION_DATA)
 	options.CheckExclusiveFlags(flags, options.NO_COMPRESSION, options.COMPRESSION_TYPE)
 	options.CheckExclusiveFlags(flags, options.NO_COMPRESSION, options.COMPRESSION_LEVEL)
+	options.CheckExclusiveFlags(flags, options.NO_COMPRESSION, options.COMPRESSION_WORKERS)
 	options.CheckExclusiveFlags(flags, options.PLUGIN_CONFIG, options.BACKUP_DIR)
+	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.VERIFY)
+	options.CheckExclusiveFlags(flags, options.PLUGIN_CONFIG, options.ENCRYPTION_KEY_FILE)
+	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.ROW_FILTER_FILE)
+	options.CheckExclusiveFlags(flags, options.INCREMENTAL, options.ROW_FILTER_FILE)
+	options.CheckExclusiveFlags(flags, options.WITH_STATS, options.ROW_FILTER_FILE)
+	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.MASKING_RULES_FILE)
+	options.CheckExclusiveFlags(flags, options.INCREMENTAL, options.MASKING_RULES_FILE)
+	options.CheckExclusiveFlags(flags, options.WITH_STATS, options.MASKING_RULES_FILE)
+	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.SAMPLE_PERCENT)
+	options.CheckExclusiveFlags(flags, options.INCREMENTAL, options.SAMPLE_PERCENT)
+	if MustGetFlagString(options.RESUME) != "" {
+		for _, flag := range []string{options.SINGLE_DATA_FILE, options.PLUGIN_CONFIG, options.METADATA_ONLY} {
+			options.CheckExclusiveFlags(flags, options.RESUME, flag)
+		}
+	}
 	if FlagChanged(options.COPY_QUEUE_SIZE) && !MustGetFlagBool(options.SINGLE_DATA_FILE) {
 		gplog.Fatal(errors.Errorf("--copy-queue-size must be specified with --single-data-file"), "")
 	}
+	if FlagChanged(options.COMPRESSION_WORKERS) && !MustGetFlagBool(options.SINGLE_DATA_FILE) {
+		gplog.Fatal(errors.Errorf("--compression-workers must be specified with --single-data-file"), "")
+	}
+	if MustGetFlagBool(options.SAMPLE_FOREIGN_KEYS) && !FlagChanged(options.SAMPLE_PERCENT) {
+		gplog.Fatal(errors.Errorf("--sample-percent must be specified with --sample-follow-foreign-keys"), "")
+	}
 	if MustGetFlagString(options.FROM_TIMESTAMP) != "" && !MustGetFlagBool(options.INCREMENTAL) {
 		gplog.Fatal(errors.Errorf("--from-timestamp must be specified with --incremental"), "")
 	}
+	if MustGetFlagBool(options.DIFFERENTIAL) && !MustGetFlagBool(options.INCREMENTAL) {
+		gplog.Fatal(errors.Errorf("--incremental must be specified with --differential"), "")
+	}
+	if MustGetFlagBool(options.INCREMENTAL_HEAP) && !MustGetFlagBool(options.INCREMENTAL) {
+		gplog.Fatal(errors.Errorf("--incremental must be specified with --incremental-heap"), "")
+	}
 	if MustGetFlagBool(options.INCREMENTAL) && !MustGetFlagBool(options.LEAF_PARTITION_DATA) {
 		gplog.Fatal(errors.Errorf("--leaf-partition-data must be specified with --incremental"), "")
 	}
+	if MustGetFlagBool(options.VERIFY) && MustGetFlagString(options.PLUGIN_CONFIG) != "" && !MustGetFlagBool(options.SINGLE_DATA_FILE) {
+		gplog.Fatal(errors.Errorf("--single-data-file must be specified with --verify when using --plugin-config"), "")
+	}
+	if MustGetFlagString(options.ENCRYPTION_KEY_FILE) != "" && !MustGetFlagBool(options.SINGLE_DATA_FILE) && !MustGetFlagBool(options.METADATA_ONLY) {
+		gplog.Fatal(errors.Errorf("--single-data-file must be specified with --encryption-key-file unless --metadata-only is used, as only a single data file per segment can be encrypted"), "")
+	}
 }
 
 func validateFlagValues() {
@@ -121,16 +156,53 @@ func validateFlagValues() {
 	gplog.FatalOnError(err)
 	err = utils.ValidateFullPath(MustGetFlagString(options.PLUGIN_CONFIG))
 	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.ENCRYPTION_KEY_FILE))
+	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.REPORT_JSON_FILE))
+	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.METRICS_FILE))
+	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.NOTIFICATION_CONFIG))
+	gplog.FatalOnError(err)
+	if MustGetFlagString(options.NOTIFICATION_CONFIG) != "" {
+		_, err = report.ReadNotificationConfig(MustGetFlagString(options.NOTIFICATION_CONFIG))
+		gplog.FatalOnError(err)
+	}
+	err = utils.ValidateFullPath(MustGetFlagString(options.HOOK_CONFIG))
+	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.ROW_FILTER_FILE))
+	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.MASKING_RULES_FILE))
+	gplog.FatalOnError(err)
+	if FlagChanged(options.SAMPLE_PERCENT) && (MustGetFlagFloat64(options.SAMPLE_PERCENT) <= 0 || MustGetFlagFloat64(options.SAMPLE_PERCENT) >= 100) {
+		gplog.Fatal(errors.Errorf("--sample-percent must be greater than 0 and less than 100"), "")
+	}
 	err = utils.ValidateCompressionTypeAndLevel(MustGetFlagString(options.COMPRESSION_TYPE), MustGetFlagInt(options.COMPRESSION_LEVEL))
 	gplog.FatalOnError(err)
 	if MustGetFlagString(options.FROM_TIMESTAMP) != "" && !filepath.IsValidTimestamp(MustGetFlagString(options.FROM_TIMESTAMP)) {
 		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.",
 			MustGetFlagString(options.FROM_TIMESTAMP)), "")
 	}
+	if MustGetFlagString(options.RESUME) != "" && !filepath.IsValidTimestamp(MustGetFlagString(options.RESUME)) {
+		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.",
+			MustGetFlagString(options.RESUME)), "")
+	}
 	if FlagChanged(options.COPY_QUEUE_SIZE) && MustGetFlagInt(options.COPY_QUEUE_SIZE) < 2 {
 		gplog.Fatal(errors.Errorf("--copy-queue-size %d is invalid. Must be at least 2",
 			MustGetFlagInt(options.COPY_QUEUE_SIZE)), "")
 	}
+	if MustGetFlagInt(options.COMPRESSION_WORKERS) < 1 {
+		gplog.Fatal(errors.Errorf("--compression-workers %d is invalid. Must be at least 1",
+			MustGetFlagInt(options.COMPRESSION_WORKERS)), "")
+	}
+	if FlagChanged(options.RETAIN_FULL) && MustGetFlagInt(options.RETAIN_FULL) < 1 {
+		gplog.Fatal(errors.Errorf("--retain-full %d is invalid. Must be at least 1",
+			MustGetFlagInt(options.RETAIN_FULL)), "")
+	}
+	if FlagChanged(options.RETAIN_DAYS) && MustGetFlagInt(options.RETAIN_DAYS) < 1 {
+		gplog.Fatal(errors.Errorf("--retain-days %d is invalid. Must be at least 1",
+			MustGetFlagInt(options.RETAIN_DAYS)), "")
+	}
 }
 
 func validateFromTimestamp(fromTimestamp string) {
@@ -147,4 +219,8 @@ func validateFromTimestamp(fromTimestamp string) {
 			"that of the current one. Please refer to the report to view the flags supplied for the "+
 			"previous backup.", fromTimestampFPInfo.Timestamp), "")
 	}
+	if MustGetFlagBool(options.DIFFERENTIAL) && fromBackupConfig.Incremental {
+		gplog.Fatal(errors.Errorf("The backup with timestamp = %s is an incremental backup. "+
+			"A differential backup must be based off a full backup.", fromTimestampFPInfo.Timestamp), "")
+	}
 }
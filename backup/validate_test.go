@@ -275,6 +275,10 @@ var _ = Describe("backup/validate tests", func() {
 			Entry("incremental combos", "--incremental --from-timestamp 20211507152558 --leaf-partition-data", true),
 			Entry("incremental combos", "--incremental --leaf-partition-data --data-only", false),
 			Entry("incremental combos", "--incremental --leaf-partition-data --metadata-only", false),
+			Entry("incremental combos", "--differential --leaf-partition-data", false),
+			Entry("incremental combos", "--incremental --differential --leaf-partition-data", true),
+			Entry("incremental combos", "--incremental-heap --leaf-partition-data", false),
+			Entry("incremental combos", "--incremental --incremental-heap --leaf-partition-data", true),
 
 			/*
 			 * Below are various different jobs combinations
@@ -283,6 +287,61 @@ var _ = Describe("backup/validate tests", func() {
 			Entry("jobs combos", "--jobs 2 --single-data-file", false),
 			Entry("jobs combos", "--jobs 2 --plugin-config /tmp/file", true),
 			Entry("jobs combos", "--jobs 2 --data-only", true),
+
+			/*
+			 * Below are various different retention policy values
+			 */
+			Entry("retention values", "--retain-full 2 --retain-days 7", true),
+			Entry("retention values", "--retain-full 0", false),
+			Entry("retention values", "--retain-days -1", false),
+
+			/*
+			 * Below are various different verify combinations
+			 */
+			Entry("verify combos", "--verify", true),
+			Entry("verify combos", "--verify --metadata-only", false),
+			Entry("verify combos", "--verify --plugin-config /tmp/file", false),
+			Entry("verify combos", "--verify --plugin-config /tmp/file --single-data-file", true),
+
+			/*
+			 * Below are various different encryption combinations
+			 */
+			Entry("encryption combos", "--encryption-key-file /tmp/key --single-data-file", true),
+			Entry("encryption combos", "--encryption-key-file /tmp/key --metadata-only", true),
+			Entry("encryption combos", "--encryption-key-file /tmp/key", false),
+			Entry("encryption combos", "--encryption-key-file tmp/key --single-data-file", false),
+			Entry("encryption combos", "--encryption-key-file /tmp/key --single-data-file --plugin-config /tmp/file", false),
+
+			/*
+			 * Below are various different resume combinations
+			 */
+			Entry("resume combos", "--resume 20170101010101", true),
+			Entry("resume combos", "--resume 20170101010101 --incremental --leaf-partition-data", true),
+			Entry("resume combos", "--resume 2017010101", false),
+			Entry("resume combos", "--resume 20170101010101 --single-data-file", false),
+			Entry("resume combos", "--resume 20170101010101 --plugin-config /tmp/file", false),
+			Entry("resume combos", "--resume 20170101010101 --metadata-only", false),
+
+			/*
+			 * Below are various different compression worker combinations
+			 */
+			Entry("compression worker combos", "--compression-workers 4 --single-data-file", true),
+			Entry("compression worker combos", "--compression-workers 4", false),
+			Entry("compression worker combos", "--compression-workers 0 --single-data-file", false),
+			Entry("compression worker combos", "--compression-workers 4 --single-data-file --no-compression", false),
+
+			/*
+			 * Below are various different row filter combinations
+			 */
+			Entry("row filter combos", "--row-filter-file /tmp/file", true),
+			Entry("row filter combos", "--row-filter-file /tmp/file --metadata-only", false),
+			Entry("row filter combos", "--row-filter-file /tmp/file --with-stats", false),
+
+			/*
+			 * Below are various different masking rule combinations
+			 */
+			Entry("masking rule combos", "--masking-rules-file /tmp/file", true),
+			Entry("masking rule combos", "--masking-rules-file /tmp/file --with-stats", false),
 		)
 	})
 })
@@ -11,6 +11,7 @@ import (
 	"github.com/cloudberrydb/gpbackup/history"
 	"github.com/cloudberrydb/gpbackup/options"
 	"github.com/cloudberrydb/gpbackup/report"
+	"github.com/cloudberrydb/gpbackup/toc"
 	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/nightlyone/lockfile"
 	"github.com/pkg/errors"
@@ -62,6 +63,9 @@ func initializeConnectionPool(timestamp string) {
 
 	utils.ValidateGPDBVersionCompatibility(connectionPool)
 	InitializeMetadataParams(connectionPool)
+	if !(MustGetFlagBool(options.METADATA_ONLY) || MustGetFlagBool(options.DATA_ONLY)) && MustGetFlagBool(options.LEAF_PARTITION_DATA) {
+		heapTableEntries, heapStatsTimestamp = GetHeapIncrementalMetadata(connectionPool)
+	}
 	// Begin transactions, initialize the synchronized snapshot, and set session GUCs
 	for connNum := 0; connNum < connectionPool.NumConns; connNum++ {
 		connectionPool.MustExec(fmt.Sprintf("SET application_name TO 'gpbackup_%s'", timestamp), connNum)
@@ -76,6 +80,10 @@ func initializeConnectionPool(timestamp string) {
 
 		SetSessionGUCs(connNum)
 	}
+	if heapTableEntries != nil && MustGetFlagBool(options.INCREMENTAL_HEAP) {
+		heapAfterSnapshot = &toc.IncrementalEntries{}
+		heapAfterSnapshot.Heap, heapAfterSnapshot.HeapStatsTimestamp = GetHeapIncrementalMetadata(connectionPool)
+	}
 }
 
 func SetSessionGUCs(connNum int) {
@@ -120,6 +128,7 @@ func NewBackupConfig(dbName string, dbVersion string, backupVersion string, plug
 		IncludeSchemas:        MustGetFlagStringArray(options.INCLUDE_SCHEMA),
 		IncludeTableFiltered:  len(opts.GetOriginalIncludedTables()) > 0,
 		Incremental:           MustGetFlagBool(options.INCREMENTAL),
+		Differential:          MustGetFlagBool(options.DIFFERENTIAL),
 		LeafPartitionData:     MustGetFlagBool(options.LEAF_PARTITION_DATA),
 		MetadataOnly:          MustGetFlagBool(options.METADATA_ONLY),
 		Plugin:                plugin,
@@ -127,8 +136,23 @@ func NewBackupConfig(dbName string, dbVersion string, backupVersion string, plug
 		Timestamp:             timestamp,
 		WithoutGlobals:        MustGetFlagBool(options.WITHOUT_GLOBALS),
 		WithStatistics:        MustGetFlagBool(options.WITH_STATS),
+		WithChecksums:         MustGetFlagBool(options.VERIFY),
+		Resumed:               MustGetFlagString(options.RESUME) != "",
 		Status:                history.BackupStatusFailed,
 	}
+	if key := utils.GetEncryptionKey(); key != nil {
+		backupConfig.EncryptionFingerprint = utils.GetKeyFingerprint(key)
+	}
+	if len(rowFilters) > 0 {
+		backupConfig.RowFilters = rowFilters
+	}
+	if len(maskingRules) > 0 {
+		backupConfig.MaskingRules = utils.MaskingRulesWithoutSalts(maskingRules)
+	}
+	if MustGetFlagFloat64(options.SAMPLE_PERCENT) > 0 {
+		backupConfig.SamplePercent = MustGetFlagFloat64(options.SAMPLE_PERCENT)
+		backupConfig.SampleForeignKeys = MustGetFlagBool(options.SAMPLE_FOREIGN_KEYS)
+	}
 
 	return &backupConfig
 }
@@ -158,6 +182,26 @@ func initializeBackupReport(opts options.Options) {
 	backupReport.ConstructBackupParamsString()
 }
 
+func initializeEncryption() {
+	keyFile := MustGetFlagString(options.ENCRYPTION_KEY_FILE)
+	key, err := utils.ReadEncryptionKeyFile(keyFile)
+	gplog.FatalOnError(err)
+	utils.SetEncryptionKey(key)
+	if !MustGetFlagBool(options.METADATA_ONLY) {
+		utils.CheckEncryptionKeyFileOnAllHosts(globalCluster, keyFile)
+	}
+	gplog.Info("Backup will be encrypted with the key with fingerprint %s", utils.GetKeyFingerprint(key))
+}
+
+/*
+ * Coordinator backup files are written in plaintext, as their byte offsets are
+ * recorded while they are written, and then replaced with their encrypted form.
+ */
+func mustEncryptBackupFile(filename string) {
+	err := utils.EncryptFile(filename, utils.GetEncryptionKey())
+	gplog.FatalOnError(err)
+}
+
 func createBackupLockFile(timestamp string) {
 	var err error
 	timestampLockFile := fmt.Sprintf("/tmp/%s.lck", timestamp)
@@ -688,7 +732,16 @@ func backupTableStatistics(statisticsFile *utils.FileWithByteCount, tables []Tab
 	PrintStatisticsStatements(statisticsFile, globalTOC, tables, attStats, tupleStats)
 }
 
-func backupIncrementalMetadata() {
+func backupIncrementalMetadata(dataTables []Table) {
 	aoTableEntries := GetAOIncrementalMetadata(connectionPool)
 	globalTOC.IncrementalMetadata.AO = aoTableEntries
+	if heapTableEntries != nil {
+		globalTOC.IncrementalMetadata.Heap = make(map[string]toc.HeapEntry)
+		for _, table := range dataTables {
+			if heapEntry, ok := heapTableEntries[table.FQN()]; ok {
+				globalTOC.IncrementalMetadata.Heap[table.FQN()] = heapEntry
+			}
+		}
+		globalTOC.IncrementalMetadata.HeapStatsTimestamp = heapStatsTimestamp
+	}
 }
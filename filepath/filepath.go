@@ -102,9 +102,12 @@ var metadataFilenameMap = map[string]string{
 	"statistics":            "statistics.sql",
 	"table of contents":     "toc.yaml",
 	"report":                "report",
+	"json report":           "report.json",
 	"plugin_config":         "plugin_config.yaml",
 	"error_tables_metadata": "error_tables_metadata",
 	"error_tables_data":     "error_tables_data",
+	"data_progress":         "data_progress",
+	"restore_progress":      "progress",
 }
 
 func (backupFPInfo *FilePathInfo) GetBackupFilePath(filetype string) string {
@@ -132,6 +135,14 @@ func (backupFPInfo *FilePathInfo) GetBackupReportFilePath() string {
 	return backupFPInfo.GetBackupFilePath("report")
 }
 
+func (backupFPInfo *FilePathInfo) GetBackupJSONReportFilePath() string {
+	return backupFPInfo.GetBackupFilePath("json report")
+}
+
+func (backupFPInfo *FilePathInfo) GetDataProgressFilePath() string {
+	return backupFPInfo.GetBackupFilePath("data_progress")
+}
+
 func (backupFPInfo *FilePathInfo) GetRestoreFilePath(restoreTimestamp string, filetype string) string {
 	return path.Join(backupFPInfo.GetDirForContent(-1), fmt.Sprintf("gprestore_%s_%s_%s", backupFPInfo.Timestamp, restoreTimestamp, metadataFilenameMap[filetype]))
 }
@@ -140,6 +151,14 @@ func (backupFPInfo *FilePathInfo) GetRestoreReportFilePath(restoreTimestamp stri
 	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "report")
 }
 
+func (backupFPInfo *FilePathInfo) GetRestoreJSONReportFilePath(restoreTimestamp string) string {
+	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "json report")
+}
+
+func (backupFPInfo *FilePathInfo) GetRestoreProgressFilePath(restoreTimestamp string) string {
+	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "restore_progress")
+}
+
 func (backupFPInfo *FilePathInfo) GetErrorTablesMetadataFilePath(restoreTimestamp string) string {
 	return backupFPInfo.GetRestoreFilePath(restoreTimestamp, "error_tables_metadata")
 }
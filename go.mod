@@ -10,10 +10,12 @@ require (
 	github.com/jackc/pgconn v1.14.1
 	github.com/jmoiron/sqlx v1.3.5
 	github.com/klauspost/compress v1.17.4
+	github.com/klauspost/pgzip v1.2.6
 	github.com/lib/pq v1.10.9
 	github.com/nightlyone/lockfile v1.0.0
 	github.com/onsi/ginkgo/v2 v2.13.2
 	github.com/onsi/gomega v1.30.0
+	github.com/pierrec/lz4/v4 v4.1.21
 	github.com/pkg/errors v0.9.1
 	github.com/sergi/go-diff v1.3.1
 	github.com/spf13/cobra v1.8.0
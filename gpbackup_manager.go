@@ -0,0 +1,25 @@
+// +build gpbackup_manager
+
+package main
+
+import (
+	"os"
+
+	. "github.com/cloudberrydb/gpbackup/manager"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	var rootCmd = &cobra.Command{
+		Use:     "gpbackup_manager",
+		Short:   "gpbackup_manager lists, displays and deletes backups taken by gpbackup",
+		Args:    cobra.NoArgs,
+		Version: GetVersion(),
+	}
+	rootCmd.SetArgs(options.HandleSingleDashes(os.Args[1:]))
+	DoInit(rootCmd)
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(2)
+	}
+}
@@ -19,9 +19,11 @@ import (
 
 func doBackupAgent() error {
 	var lastRead uint64
+	var lastFrameEnd uint64
 	var (
-		pipeWriter BackupPipeWriterCloser
-		writeCmd   *exec.Cmd
+		pipeWriter     BackupPipeWriterCloser
+		writeCmd       *exec.Cmd
+		checksumWriter *ChecksumWriterCloser
 	)
 	tocfile := &toc.SegmentTOC{}
 	tocfile.DataEntries = make(map[uint]toc.SegmentDataEntry)
@@ -62,7 +64,7 @@ func doBackupAgent() error {
 			return err
 		}
 		if i == 0 {
-			pipeWriter, writeCmd, err = getBackupPipeWriter()
+			pipeWriter, writeCmd, checksumWriter, err = getBackupPipeWriter()
 			if err != nil {
 				logError(fmt.Sprintf("Oid %d: Error encountered getting backup pipe writer: %v", oid, err))
 				return err
@@ -78,7 +80,11 @@ func doBackupAgent() error {
 		log(fmt.Sprintf("Oid %d: Read %d bytes\n", oid, numBytes))
 
 		lastProcessed := lastRead + uint64(numBytes)
-		tocfile.AddSegmentDataEntry(uint(oid), lastRead, lastProcessed)
+		lastFrameEnd, err = addSegmentDataEntry(tocfile, pipeWriter, oid, lastRead, lastProcessed, lastFrameEnd)
+		if err != nil {
+			logError(fmt.Sprintf("Oid %d: Error encountered finishing frame: %v", oid, err))
+			return err
+		}
 		lastRead = lastProcessed
 
 		_ = readHandle.Close()
@@ -87,6 +93,9 @@ func doBackupAgent() error {
 	}
 
 	_ = pipeWriter.Close()
+	if checksumWriter != nil {
+		tocfile.Checksum = checksumWriter.Checksum()
+	}
 	if *pluginConfigFile != "" {
 		/*
 		 * When using a plugin, the agent may take longer to finish than the
@@ -107,10 +116,37 @@ func doBackupAgent() error {
 		// error logging handled in util.go
 		return err
 	}
+	if *encryptionKeyFile != "" {
+		err = encryptSegmentTOC(tocfile)
+		if err != nil {
+			logError(fmt.Sprintf("Error encountered encrypting segment TOC: %v", err))
+			return err
+		}
+	}
 	log("Finished writing segment TOC")
 	return nil
 }
 
+/*
+ * Records where the data of a table is in the data file, finishing its frame
+ * first if the data file is written in frames.  Returns the end of the frame,
+ * which is where the frame of the next table starts.
+ */
+func addSegmentDataEntry(tocfile *toc.SegmentTOC, pipeWriter BackupPipeWriterCloser, oid int, startByte uint64, endByte uint64, frameStart uint64) (uint64, error) {
+	framedWriter, ok := pipeWriter.(FramedBackupPipeWriterCloser)
+	if !ok {
+		tocfile.AddSegmentDataEntry(uint(oid), startByte, endByte)
+		return frameStart, nil
+	}
+	frameEnd, err := framedWriter.EndFrame()
+	if err != nil {
+		return frameStart, err
+	}
+	tocfile.Framed = true
+	tocfile.AddFramedSegmentDataEntry(uint(oid), startByte, endByte, frameStart, frameEnd)
+	return frameEnd, nil
+}
+
 func getBackupPipeReader(currentPipe string) (io.Reader, io.ReadCloser, error) {
 	readHandle, err := os.OpenFile(currentPipe, os.O_RDONLY, os.ModeNamedPipe)
 	if err != nil {
@@ -124,7 +160,7 @@ func getBackupPipeReader(currentPipe string) (io.Reader, io.ReadCloser, error) {
 	return reader, readHandle, nil
 }
 
-func getBackupPipeWriter() (pipe BackupPipeWriterCloser, writeCmd *exec.Cmd, err error) {
+func getBackupPipeWriter() (pipe BackupPipeWriterCloser, writeCmd *exec.Cmd, checksumWriter *ChecksumWriterCloser, err error) {
 	var writeHandle io.WriteCloser
 	if *pluginConfigFile != "" {
 		writeCmd, writeHandle, err = startBackupPluginCommand()
@@ -133,7 +169,19 @@ func getBackupPipeWriter() (pipe BackupPipeWriterCloser, writeCmd *exec.Cmd, err
 	}
 	if err != nil {
 		// error logging handled by calling functions
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+	// The checksum covers the encrypted bytes, so a backup can be verified without its key
+	if *withChecksum {
+		checksumWriter = NewChecksumWriterCloser(writeHandle)
+		writeHandle = checksumWriter
+	}
+	if *encryptionKeyFile != "" {
+		writeHandle, err = getEncryptingWriter(writeHandle)
+		if err != nil {
+			// error logging handled by calling functions
+			return nil, nil, nil, err
+		}
 	}
 
 	if *compressionLevel == 0 {
@@ -142,17 +190,60 @@ func getBackupPipeWriter() (pipe BackupPipeWriterCloser, writeCmd *exec.Cmd, err
 	}
 
 	if *compressionType == "gzip" {
-		pipe, err = NewGZipBackupPipeWriterCloser(writeHandle, *compressionLevel)
+		pipe, err = NewGZipBackupPipeWriterCloser(writeHandle, *compressionLevel, *compressWorkers)
 		return
 	}
 	if *compressionType == "zstd" {
-		pipe, err = NewZSTDBackupPipeWriterCloser(writeHandle, *compressionLevel)
+		pipe, err = NewZSTDBackupPipeWriterCloser(writeHandle, *compressionLevel, *compressWorkers)
+		return
+	}
+	if *compressionType == "lz4" {
+		pipe, err = NewLZ4BackupPipeWriterCloser(writeHandle, *compressionLevel, *compressWorkers)
+		return
+	}
+	if *compressionType == "none" {
+		pipe = NewRawBackupPipeWriterCloser(writeHandle)
 		return
 	}
 
 	writeHandle.Close()
 	// error logging handled by calling functions
-	return nil, nil, fmt.Errorf("unknown compression type '%s' (compression level %d)", *compressionType, *compressionLevel)
+	return nil, nil, nil, fmt.Errorf("unknown compression type '%s' (compression level %d)", *compressionType, *compressionLevel)
+}
+
+func getEncryptingWriter(writeHandle io.WriteCloser) (io.WriteCloser, error) {
+	key, err := utils.ReadEncryptionKeyFile(*encryptionKeyFile)
+	if err != nil {
+		_ = writeHandle.Close()
+		return nil, err
+	}
+	encryptingWriter, err := utils.NewEncryptingWriter(writeHandle, key)
+	if err != nil {
+		_ = writeHandle.Close()
+		return nil, err
+	}
+	return encryptingWriter, nil
+}
+
+/*
+ * The segment TOC records which tables are in the data file and where, so it
+ * is encrypted along with the data file.  The checksum of the data file is
+ * then also written alongside it, in the same form as for per-table data
+ * files, so that the backup can still be verified without its key.
+ */
+func encryptSegmentTOC(tocfile *toc.SegmentTOC) error {
+	key, err := utils.ReadEncryptionKeyFile(*encryptionKeyFile)
+	if err != nil {
+		return err
+	}
+	err = utils.EncryptFile(*tocFile, key)
+	if err != nil {
+		return err
+	}
+	if tocfile.Checksum == "" {
+		return nil
+	}
+	return utils.WriteToFileAndMakeReadOnly(*dataFile+utils.ChecksumFileExtension, []byte(fmt.Sprintf("%s  -\n", tocfile.Checksum)))
 }
 
 func startBackupPluginCommand() (*exec.Cmd, io.WriteCloser, error) {
@@ -3,16 +3,54 @@ package helper
 import (
 	"bufio"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
 	"io"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
 )
 
+/*
+ * Data is compressed in blocks of this size when more than one compression
+ * worker is used, so each worker has enough data to keep it busy.
+ */
+const parallelCompressionBlockSize = 1 << 20
+
 type BackupPipeWriterCloser interface {
 	io.Writer
 	io.Closer
 }
 
+/*
+ * Compression formats whose frames can be concatenated into a single stream
+ * write the data of each table in its own frame.  EndFrame finishes the
+ * current frame and returns the number of compressed bytes written so far,
+ * so a filtered restore can seek straight to the frames of the tables it
+ * needs and decompress only those.
+ */
+type FramedBackupPipeWriterCloser interface {
+	BackupPipeWriterCloser
+	EndFrame() (uint64, error)
+}
+
+/*
+ * Counts the compressed bytes written before they are buffered, so the count
+ * matches the offset in the data file once the buffer is flushed.
+ */
+type countingWriter struct {
+	writer io.Writer
+	count  uint64
+}
+
+func (cWriter *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = cWriter.writer.Write(p)
+	cWriter.count += uint64(n)
+	return
+}
+
 type CommonBackupPipeWriterCloser struct {
 	writeHandle io.WriteCloser
 	bufIoWriter *bufio.Writer
@@ -37,9 +75,38 @@ func NewCommonBackupPipeWriterCloser(writeHandle io.WriteCloser) (cPipe CommonBa
 	return
 }
 
+/*
+ * Sits between the compressor and the data file (or plugin), so the checksum
+ * covers exactly the bytes that end up stored in the backup.
+ */
+type ChecksumWriterCloser struct {
+	writeHandle io.WriteCloser
+	hash        hash.Hash
+}
+
+func (cWriter *ChecksumWriterCloser) Write(p []byte) (n int, err error) {
+	n, err = cWriter.writeHandle.Write(p)
+	_, _ = cWriter.hash.Write(p[:n])
+	return
+}
+
+func (cWriter *ChecksumWriterCloser) Close() error {
+	return cWriter.writeHandle.Close()
+}
+
+func (cWriter *ChecksumWriterCloser) Checksum() string {
+	return hex.EncodeToString(cWriter.hash.Sum(nil))
+}
+
+func NewChecksumWriterCloser(writeHandle io.WriteCloser) *ChecksumWriterCloser {
+	return &ChecksumWriterCloser{writeHandle: writeHandle, hash: sha256.New()}
+}
+
 type GZipBackupPipeWriterCloser struct {
 	cPipe      CommonBackupPipeWriterCloser
-	gzipWriter *gzip.Writer
+	counter    *countingWriter
+	gzipWriter io.WriteCloser
+	workers    int
 }
 
 func (gzPipe GZipBackupPipeWriterCloser) Write(p []byte) (n int, err error) {
@@ -52,9 +119,40 @@ func (gzPipe GZipBackupPipeWriterCloser) Close() error {
 	return gzPipe.cPipe.Close()
 }
 
-func NewGZipBackupPipeWriterCloser(writeHandle io.WriteCloser, compressLevel int) (gzPipe GZipBackupPipeWriterCloser, err error) {
+// Each frame is a separate gzip member, which gzip readers decompress as one stream
+func (gzPipe GZipBackupPipeWriterCloser) EndFrame() (uint64, error) {
+	err := gzPipe.gzipWriter.Close()
+	if err != nil {
+		return 0, err
+	}
+	switch gzipWriter := gzPipe.gzipWriter.(type) {
+	case *pgzip.Writer:
+		gzipWriter.Reset(gzPipe.counter)
+		err = gzipWriter.SetConcurrency(parallelCompressionBlockSize, gzPipe.workers)
+	case *gzip.Writer:
+		gzipWriter.Reset(gzPipe.counter)
+	}
+	return gzPipe.counter.count, err
+}
+
+/*
+ * With more than one worker, blocks are compressed in parallel and written out
+ * in order, which produces a standard gzip stream.
+ */
+func NewGZipBackupPipeWriterCloser(writeHandle io.WriteCloser, compressLevel int, compressionWorkers int) (gzPipe GZipBackupPipeWriterCloser, err error) {
 	gzPipe.cPipe = NewCommonBackupPipeWriterCloser(writeHandle)
-	gzPipe.gzipWriter, err = gzip.NewWriterLevel(gzPipe.cPipe.bufIoWriter, compressLevel)
+	gzPipe.counter = &countingWriter{writer: gzPipe.cPipe.bufIoWriter}
+	gzPipe.workers = compressionWorkers
+	if compressionWorkers > 1 {
+		var parallelWriter *pgzip.Writer
+		parallelWriter, err = pgzip.NewWriterLevel(gzPipe.counter, compressLevel)
+		if err == nil {
+			err = parallelWriter.SetConcurrency(parallelCompressionBlockSize, compressionWorkers)
+		}
+		gzPipe.gzipWriter = parallelWriter
+	} else {
+		gzPipe.gzipWriter, err = gzip.NewWriterLevel(gzPipe.counter, compressLevel)
+	}
 	if err != nil {
 		gzPipe.cPipe.Close()
 	}
@@ -63,6 +161,7 @@ func NewGZipBackupPipeWriterCloser(writeHandle io.WriteCloser, compressLevel int
 
 type ZSTDBackupPipeWriterCloser struct {
 	cPipe       CommonBackupPipeWriterCloser
+	counter     *countingWriter
 	zstdEncoder *zstd.Encoder
 }
 
@@ -76,11 +175,109 @@ func (zstdPipe ZSTDBackupPipeWriterCloser) Close() error {
 	return zstdPipe.cPipe.Close()
 }
 
-func NewZSTDBackupPipeWriterCloser(writeHandle io.WriteCloser, compressLevel int) (zstdPipe ZSTDBackupPipeWriterCloser, err error) {
+// Concatenated zstd frames are decompressed as one stream
+func (zstdPipe ZSTDBackupPipeWriterCloser) EndFrame() (uint64, error) {
+	err := zstdPipe.zstdEncoder.Close()
+	if err != nil {
+		return 0, err
+	}
+	zstdPipe.zstdEncoder.Reset(zstdPipe.counter)
+	return zstdPipe.counter.count, nil
+}
+
+func NewZSTDBackupPipeWriterCloser(writeHandle io.WriteCloser, compressLevel int, compressionWorkers int) (zstdPipe ZSTDBackupPipeWriterCloser, err error) {
 	zstdPipe.cPipe = NewCommonBackupPipeWriterCloser(writeHandle)
-	zstdPipe.zstdEncoder, err = zstd.NewWriter(zstdPipe.cPipe.bufIoWriter, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(compressLevel)))
+	zstdPipe.counter = &countingWriter{writer: zstdPipe.cPipe.bufIoWriter}
+	encoderOptions := []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(compressLevel))}
+	if compressionWorkers > 1 {
+		encoderOptions = append(encoderOptions, zstd.WithEncoderConcurrency(compressionWorkers))
+	}
+	zstdPipe.zstdEncoder, err = zstd.NewWriter(zstdPipe.counter, encoderOptions...)
 	if err != nil {
 		zstdPipe.cPipe.Close()
 	}
 	return
 }
+
+type LZ4BackupPipeWriterCloser struct {
+	cPipe         CommonBackupPipeWriterCloser
+	counter       *countingWriter
+	lz4Writer     *lz4.Writer
+	writerOptions []lz4.Option
+}
+
+func (lz4Pipe *LZ4BackupPipeWriterCloser) Write(p []byte) (n int, err error) {
+	return lz4Pipe.lz4Writer.Write(p)
+}
+
+// Returns errors from underlying common writer only
+func (lz4Pipe *LZ4BackupPipeWriterCloser) Close() error {
+	_ = lz4Pipe.lz4Writer.Close()
+	return lz4Pipe.cPipe.Close()
+}
+
+// Resetting a closed writer hangs when it compresses in parallel, so each frame gets a new writer
+func (lz4Pipe *LZ4BackupPipeWriterCloser) EndFrame() (uint64, error) {
+	err := lz4Pipe.lz4Writer.Close()
+	if err != nil {
+		return 0, err
+	}
+	err = lz4Pipe.newFrameWriter()
+	return lz4Pipe.counter.count, err
+}
+
+func (lz4Pipe *LZ4BackupPipeWriterCloser) newFrameWriter() error {
+	lz4Pipe.lz4Writer = lz4.NewWriter(lz4Pipe.counter)
+	return lz4Pipe.lz4Writer.Apply(lz4Pipe.writerOptions...)
+}
+
+/*
+ * Level 1 is the fast lz4 mode used by default by the lz4 command line tool,
+ * and higher levels use lz4 HC, as with lz4 -2 through -9.
+ */
+func NewLZ4BackupPipeWriterCloser(writeHandle io.WriteCloser, compressLevel int, compressionWorkers int) (lz4Pipe *LZ4BackupPipeWriterCloser, err error) {
+	lz4Pipe = &LZ4BackupPipeWriterCloser{cPipe: NewCommonBackupPipeWriterCloser(writeHandle)}
+	lz4Pipe.counter = &countingWriter{writer: lz4Pipe.cPipe.bufIoWriter}
+	level := lz4.Fast
+	if compressLevel > 1 {
+		level = lz4.Level1 << (compressLevel - 1)
+	}
+	lz4Pipe.writerOptions = []lz4.Option{lz4.CompressionLevelOption(level)}
+	if compressionWorkers > 1 {
+		lz4Pipe.writerOptions = append(lz4Pipe.writerOptions, lz4.ConcurrencyOption(compressionWorkers))
+	}
+	err = lz4Pipe.newFrameWriter()
+	if err != nil {
+		lz4Pipe.cPipe.Close()
+	}
+	return
+}
+
+/*
+ * Writes data uncompressed, but records the same per-table frames as the
+ * compressed formats, so data files of every compression type can be restored
+ * by frame, and a plugin's restore_data_subset is always given frame offsets.
+ */
+type RawBackupPipeWriterCloser struct {
+	cPipe   CommonBackupPipeWriterCloser
+	counter *countingWriter
+}
+
+func (rawPipe RawBackupPipeWriterCloser) Write(p []byte) (n int, err error) {
+	return rawPipe.counter.Write(p)
+}
+
+func (rawPipe RawBackupPipeWriterCloser) Close() error {
+	return rawPipe.cPipe.Close()
+}
+
+// Uncompressed data needs no frame trailer, so a frame ends at the current offset
+func (rawPipe RawBackupPipeWriterCloser) EndFrame() (uint64, error) {
+	return rawPipe.counter.count, nil
+}
+
+func NewRawBackupPipeWriterCloser(writeHandle io.WriteCloser) (rawPipe RawBackupPipeWriterCloser) {
+	rawPipe.cPipe = NewCommonBackupPipeWriterCloser(writeHandle)
+	rawPipe.counter = &countingWriter{writer: rawPipe.cPipe.bufIoWriter}
+	return
+}
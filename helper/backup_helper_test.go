@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"github.com/cloudberrydb/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("helper/backup_helper tests", func() {
+	var (
+		tocfile  *toc.SegmentTOC
+		dataFile *os.File
+	)
+	BeforeEach(func() {
+		tocfile = &toc.SegmentTOC{DataEntries: make(map[uint]toc.SegmentDataEntry)}
+		var err error
+		dataFile, err = os.Create(path.Join(GinkgoT().TempDir(), "gpbackup_0_20170101010101"))
+		Expect(err).ToNot(HaveOccurred())
+	})
+	Describe("addSegmentDataEntry", func() {
+		It("records only the byte offsets of a table when the data file is not written in frames", func() {
+			pipeWriter := NewCommonBackupPipeWriterCloser(dataFile)
+			defer pipeWriter.Close()
+
+			frameEnd, err := addSegmentDataEntry(tocfile, pipeWriter, 1, 0, 20, 0)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frameEnd).To(Equal(uint64(0)))
+			Expect(tocfile.Framed).To(BeFalse())
+			Expect(tocfile.DataEntries).To(Equal(map[uint]toc.SegmentDataEntry{1: {StartByte: 0, EndByte: 20}}))
+		})
+		It("ends the frame of each table and records it along with the byte offsets", func() {
+			pipeWriter := NewRawBackupPipeWriterCloser(dataFile)
+			defer pipeWriter.Close()
+
+			_, _ = pipeWriter.Write([]byte("data of table 1\n"))
+			frameEnd, err := addSegmentDataEntry(tocfile, pipeWriter, 1, 0, 16, 0)
+			Expect(err).ToNot(HaveOccurred())
+			_, _ = pipeWriter.Write([]byte("data of table 2\n"))
+			frameEnd, err = addSegmentDataEntry(tocfile, pipeWriter, 2, 16, 32, frameEnd)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(frameEnd).To(Equal(uint64(32)))
+			Expect(tocfile.Framed).To(BeTrue())
+			Expect(tocfile.DataEntries).To(Equal(map[uint]toc.SegmentDataEntry{
+				1: {StartByte: 0, EndByte: 16, FrameStart: 0, FrameEnd: 16},
+				2: {StartByte: 16, EndByte: 32, FrameStart: 16, FrameEnd: 32},
+			}))
+		})
+		DescribeTable("writes each table in its own frame, and the frames read back as one stream",
+			func(extension string, newWriter func(io.WriteCloser) (BackupPipeWriterCloser, error)) {
+				pipeWriter, err := newWriter(dataFile)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _ = pipeWriter.Write([]byte("data of table 1\n"))
+				frameEnd, err := addSegmentDataEntry(tocfile, pipeWriter, 1, 0, 16, 0)
+				Expect(err).ToNot(HaveOccurred())
+				_, _ = pipeWriter.Write([]byte("data of table 2\n"))
+				frameEnd, err = addSegmentDataEntry(tocfile, pipeWriter, 2, 16, 32, frameEnd)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pipeWriter.Close()).To(Succeed())
+
+				Expect(tocfile.Framed).To(BeTrue())
+				Expect(tocfile.DataEntries[1].FrameStart).To(Equal(uint64(0)))
+				Expect(tocfile.DataEntries[1].FrameEnd).To(BeNumerically(">", 0))
+				Expect(tocfile.DataEntries[2].FrameStart).To(Equal(tocfile.DataEntries[1].FrameEnd))
+				Expect(tocfile.DataEntries[2].FrameEnd).To(Equal(frameEnd))
+
+				readHandle, err := os.Open(dataFile.Name())
+				Expect(err).ToNot(HaveOccurred())
+				defer readHandle.Close()
+				decompressor, err := getDecompressingReader(dataFile.Name()+extension, readHandle)
+				Expect(err).ToNot(HaveOccurred())
+				data, err := io.ReadAll(decompressor)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(data)).To(Equal("data of table 1\ndata of table 2\n"))
+			},
+			Entry("gzip", ".gz", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewGZipBackupPipeWriterCloser(writeHandle, 1, 1)
+			}),
+			Entry("zstd", ".zst", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewZSTDBackupPipeWriterCloser(writeHandle, 1, 1)
+			}),
+			Entry("lz4", ".lz4", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewLZ4BackupPipeWriterCloser(writeHandle, 1, 1)
+			}),
+			Entry("lz4 with parallel compression", ".lz4", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewLZ4BackupPipeWriterCloser(writeHandle, 1, 2)
+			}),
+		)
+	})
+})
@@ -38,25 +38,28 @@ var (
  * Command-line flags
  */
 var (
-	backupAgent      *bool
-	compressionLevel *int
-	compressionType  *string
-	content          *int
-	dataFile         *string
-	oidFile          *string
-	onErrorContinue  *bool
-	pipeFile         *string
-	pluginConfigFile *string
-	printVersion     *bool
-	restoreAgent     *bool
-	tocFile          *string
-	isFiltered       *bool
-	copyQueue        *int
-	singleDataFile   *bool
-	isResizeRestore  *bool
-	origSize         *int
-	destSize         *int
-	replicationFile  *string
+	backupAgent       *bool
+	compressionLevel  *int
+	compressionType   *string
+	compressWorkers   *int
+	content           *int
+	dataFile          *string
+	oidFile           *string
+	onErrorContinue   *bool
+	pipeFile          *string
+	pluginConfigFile  *string
+	printVersion      *bool
+	restoreAgent      *bool
+	tocFile           *string
+	isFiltered        *bool
+	copyQueue         *int
+	singleDataFile    *bool
+	isResizeRestore   *bool
+	origSize          *int
+	destSize          *int
+	replicationFile   *string
+	withChecksum      *bool
+	encryptionKeyFile *string
 )
 
 func DoHelper() {
@@ -93,7 +96,8 @@ func InitializeGlobals() {
 	backupAgent = flag.Bool("backup-agent", false, "Use gpbackup_helper as an agent for backup")
 	content = flag.Int("content", -2, "Content ID of the corresponding segment")
 	compressionLevel = flag.Int("compression-level", 0, "The level of compression. O indicates no compression. Range of valid values depends on compression type")
-	compressionType = flag.String("compression-type", "gzip", "The type of compression. Valid values are 'gzip' and 'zstd'")
+	compressionType = flag.String("compression-type", "gzip", "The type of compression. Valid values are 'gzip', 'zstd' and 'lz4'")
+	compressWorkers = flag.Int("compression-workers", 1, "The number of goroutines with which to compress or decompress the data file")
 	dataFile = flag.String("data-file", "", "Absolute path to the data file")
 	oidFile = flag.String("oid-file", "", "Absolute path to the file containing a list of oids to restore")
 	onErrorContinue = flag.Bool("on-error-continue", false, "Continue restore even when encountering an error")
@@ -109,6 +113,8 @@ func InitializeGlobals() {
 	origSize = flag.Int("orig-seg-count", 0, "Used with resize restore.  Gives the segment count of the backup.")
 	destSize = flag.Int("dest-seg-count", 0, "Used with resize restore.  Gives the segment count of the current cluster.")
 	replicationFile = flag.String("replication-file", "", "Used with resize restore.  Gives the list of replicated tables.")
+	withChecksum = flag.Bool("with-checksum", false, "Record a SHA-256 checksum of the data file in the TOC file")
+	encryptionKeyFile = flag.String("encryption-key-file", "", "Absolute path to the file containing the key with which to encrypt or decrypt the data file")
 
 	if *onErrorContinue && !*restoreAgent {
 		fmt.Printf("--on-error-continue flag can only be used with --restore-agent flag")
@@ -0,0 +1,22 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestHelper(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "helper tests")
+}
+
+var _ = BeforeSuite(func() {
+	testhelper.SetupTestLogger()
+	content = new(int)
+	compressWorkers = new(int)
+	*compressWorkers = 1
+})
@@ -16,6 +16,8 @@ import (
 	"github.com/cloudberrydb/gpbackup/toc"
 	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
 	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
 )
@@ -29,6 +31,7 @@ const (
 	SEEKABLE    ReaderType = "seekable" // reader which supports seek
 	NONSEEKABLE            = "discard"  // reader which is not seekable
 	SUBSET                 = "subset"   // reader which operates on pre filtered data
+	FRAMED                 = "framed"   // reader which seeks to the compressed frame of each table
 )
 
 var (
@@ -39,13 +42,24 @@ var (
  * readerType identifies how the reader can be used
  * SEEKABLE uses seekReader. Used when restoring from uncompressed data with filters from local filesystem
  * NONSEEKABLE and SUBSET types uses bufReader.
- * SUBSET type applies when restoring using plugin(if compatible) from uncompressed data with filters
+ * SUBSET type applies when restoring using plugin(if compatible) from uncompressed or framed data with filters
+ * FRAMED uses seekReader to find the frame of each table and bufReader to decompress it. Used when restoring
+ * from framed compressed data with filters from local filesystem
  * NONSEEKABLE type applies for every other restore scenario
  */
 type RestoreReader struct {
-	bufReader  *bufio.Reader
-	seekReader io.ReadSeeker
-	readerType ReaderType
+	bufReader    *bufio.Reader
+	seekReader   io.ReadSeeker
+	readerType   ReaderType
+	fileToRead   string
+	segmentTOC   *toc.SegmentTOC
+	decompressor io.Reader
+}
+
+// Implemented by the gzip, zstd and lz4 readers, so one reader can be reused for every frame
+type resettableReader interface {
+	io.Reader
+	Reset(r io.Reader) error
 }
 
 func (r *RestoreReader) positionReader(pos uint64, oid int) error {
@@ -66,17 +80,43 @@ func (r *RestoreReader) positionReader(pos uint64, oid int) error {
 		log(fmt.Sprintf("Oid %d: Data Reader discarded %d bytes", oid, numDiscarded))
 	case SUBSET:
 		// Do nothing as the stream is pre filtered
+	case FRAMED:
+		return r.positionFramedReader(oid)
 	}
 	return nil
 }
 
+func (r *RestoreReader) positionFramedReader(oid int) error {
+	entry := r.segmentTOC.DataEntries[uint(oid)]
+	if entry.FrameEnd == entry.FrameStart {
+		r.bufReader = bufio.NewReader(strings.NewReader(""))
+		return nil
+	}
+	_, err := r.seekReader.Seek(int64(entry.FrameStart), io.SeekStart)
+	if err != nil {
+		return err
+	}
+	frameReader := io.LimitReader(r.seekReader, int64(entry.FrameEnd-entry.FrameStart))
+	if resettable, ok := r.decompressor.(resettableReader); ok {
+		err = resettable.Reset(frameReader)
+	} else {
+		r.decompressor, err = getDecompressingReader(r.fileToRead, frameReader)
+	}
+	if err != nil {
+		return err
+	}
+	r.bufReader = bufio.NewReader(r.decompressor)
+	log(fmt.Sprintf("Oid %d: Data Reader seeked to frame at %d byte offset", oid, entry.FrameStart))
+	return nil
+}
+
 func (r *RestoreReader) copyData(num int64) (int64, error) {
 	var bytesRead int64
 	var err error
 	switch r.readerType {
 	case SEEKABLE:
 		bytesRead, err = io.CopyN(writer, r.seekReader, num)
-	case NONSEEKABLE, SUBSET:
+	case NONSEEKABLE, SUBSET, FRAMED:
 		bytesRead, err = io.CopyN(writer, r.bufReader, num)
 	}
 	return bytesRead, err
@@ -113,6 +153,16 @@ func doRestoreAgent() error {
 		return err
 	}
 
+	if *encryptionKeyFile != "" {
+		// The segment TOCs of an encrypted backup are decrypted with the same key as its data files
+		key, err := utils.ReadEncryptionKeyFile(*encryptionKeyFile)
+		if err != nil {
+			logError(fmt.Sprintf("Error encountered reading encryption key file: %v", err))
+			return err
+		}
+		utils.SetEncryptionKey(key)
+	}
+
 	// During a larger-to-smaller restore, we need to do multiple passes for each oid, so the table
 	// restore goes into another nested for loop below.  In the normal or smaller-to-larger cases,
 	// this is equivalent to doing a single loop per table.
@@ -402,8 +452,12 @@ func getRestoreDataReader(fileToRead string, toc *toc.SegmentTOC, oidList []int)
 			restoreReader.readerType = NONSEEKABLE
 		}
 	} else {
-		if *isFiltered && !strings.HasSuffix(fileToRead, ".gz") && !strings.HasSuffix(fileToRead, ".zst") {
-			// Seekable reader if backup is not compressed and filters are set
+		if *isFiltered && *encryptionKeyFile == "" && toc != nil && toc.Framed {
+			// Seekable reader over the frame of each table if backup is written in frames and filters are set
+			seekHandle, err = os.Open(fileToRead)
+			restoreReader.readerType = FRAMED
+		} else if *isFiltered && *encryptionKeyFile == "" && !isCompressedFile(fileToRead) {
+			// Seekable reader if backup is not compressed or encrypted and filters are set
 			seekHandle, err = os.Open(fileToRead)
 			restoreReader.readerType = SEEKABLE
 		} else {
@@ -417,25 +471,34 @@ func getRestoreDataReader(fileToRead string, toc *toc.SegmentTOC, oidList []int)
 		return nil, err
 	}
 
-	// Set the underlying stream reader in restoreReader
-	if restoreReader.readerType == SEEKABLE {
-		restoreReader.seekReader = seekHandle
-	} else if strings.HasSuffix(fileToRead, ".gz") {
-		gzipReader, err := gzip.NewReader(readHandle)
+	if *encryptionKeyFile != "" {
+		key, err := utils.ReadEncryptionKeyFile(*encryptionKeyFile)
 		if err != nil {
 			// error logging handled by calling functions
 			return nil, err
 		}
-		restoreReader.bufReader = bufio.NewReader(gzipReader)
-	} else if strings.HasSuffix(fileToRead, ".zst") {
-		zstdReader, err := zstd.NewReader(readHandle)
+		readHandle, err = utils.NewDecryptingReader(readHandle, key)
 		if err != nil {
 			// error logging handled by calling functions
 			return nil, err
 		}
-		restoreReader.bufReader = bufio.NewReader(zstdReader)
+	}
+
+	// Set the underlying stream reader in restoreReader
+	if restoreReader.readerType == SEEKABLE {
+		restoreReader.seekReader = seekHandle
+	} else if restoreReader.readerType == FRAMED {
+		// The decompressing reader is set up for each table as its frame is reached
+		restoreReader.seekReader = seekHandle
+		restoreReader.fileToRead = fileToRead
+		restoreReader.segmentTOC = toc
 	} else {
-		restoreReader.bufReader = bufio.NewReader(readHandle)
+		decompressingReader, err := getDecompressingReader(fileToRead, readHandle)
+		if err != nil {
+			// error logging handled by calling functions
+			return nil, err
+		}
+		restoreReader.bufReader = bufio.NewReader(decompressingReader)
 	}
 
 	// Check that no error has occurred in plugin command
@@ -447,6 +510,84 @@ func getRestoreDataReader(fileToRead string, toc *toc.SegmentTOC, oidList []int)
 	return restoreReader, err
 }
 
+func isCompressedFile(fileToRead string) bool {
+	return strings.HasSuffix(fileToRead, ".gz") || strings.HasSuffix(fileToRead, ".zst") || strings.HasSuffix(fileToRead, ".lz4")
+}
+
+/*
+ * Returns a reader that decompresses readHandle based on the extension of the
+ * file being restored.  The gzip and zstd readers read concatenated frames as
+ * one stream, and lz4 frames are read one after another by lz4FramesReader,
+ * so framed data files can also be read from start to finish, and the frames
+ * of a .raw data file are not compressed at all.
+ */
+func getDecompressingReader(fileToRead string, readHandle io.Reader) (io.Reader, error) {
+	if strings.HasSuffix(fileToRead, ".gz") {
+		if *compressWorkers > 1 {
+			// gzip cannot be decompressed in parallel, but blocks are read ahead and checksummed on other goroutines
+			return pgzip.NewReaderN(readHandle, parallelCompressionBlockSize, *compressWorkers)
+		}
+		return gzip.NewReader(readHandle)
+	} else if strings.HasSuffix(fileToRead, ".zst") {
+		decoderOptions := make([]zstd.DOption, 0)
+		if *compressWorkers > 1 {
+			decoderOptions = append(decoderOptions, zstd.WithDecoderConcurrency(*compressWorkers))
+		}
+		return zstd.NewReader(readHandle, decoderOptions...)
+	} else if strings.HasSuffix(fileToRead, ".lz4") {
+		input := bufio.NewReader(readHandle)
+		lz4Reader := lz4.NewReader(input)
+		if *compressWorkers > 1 {
+			err := lz4Reader.Apply(lz4.ConcurrencyOption(*compressWorkers))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &lz4FramesReader{input: input, lz4Reader: lz4Reader}, nil
+	}
+	return readHandle, nil
+}
+
+/*
+ * The lz4 reader stops at the end of the first frame, so whenever a frame
+ * ends and more input follows, a new frame is started on the rest of it.
+ */
+type lz4FramesReader struct {
+	input      *bufio.Reader
+	lz4Reader  *lz4.Reader
+	frameEnded bool
+}
+
+// The last data of a frame may be returned along with io.EOF
+func (r *lz4FramesReader) Read(p []byte) (int, error) {
+	for {
+		if r.frameEnded {
+			_, err := r.input.Peek(1)
+			if err != nil {
+				return 0, err
+			}
+			r.lz4Reader.Reset(r.input)
+			r.frameEnded = false
+		}
+		n, err := r.lz4Reader.Read(p)
+		if err == io.EOF {
+			r.frameEnded = true
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *lz4FramesReader) Reset(readHandle io.Reader) error {
+	r.input.Reset(readHandle)
+	r.lz4Reader.Reset(r.input)
+	r.frameEnded = false
+	return nil
+}
+
 func getRestorePipeWriter(currentPipe string) (*bufio.Writer, *os.File, error) {
 	fileHandle, err := os.OpenFile(currentPipe, os.O_WRONLY|unix.O_NONBLOCK, os.ModeNamedPipe)
 	if err != nil {
@@ -473,7 +614,7 @@ func startRestorePluginCommand(fileToRead string, toc *toc.SegmentTOC, oidList [
 		return nil, false, err
 	}
 	cmdStr := ""
-	if toc != nil && pluginConfig.CanRestoreSubset() && *isFiltered && !strings.HasSuffix(fileToRead, ".gz") && !strings.HasSuffix(fileToRead, ".zst") {
+	if toc != nil && pluginConfig.CanRestoreSubset() && *isFiltered && *encryptionKeyFile == "" && (!isCompressedFile(fileToRead) || toc.Framed) {
 		offsetsFile, _ := ioutil.TempFile("/tmp", "gprestore_offsets_")
 		defer func() {
 			offsetsFile.Close()
@@ -482,7 +623,13 @@ func startRestorePluginCommand(fileToRead string, toc *toc.SegmentTOC, oidList [
 		w.WriteString(fmt.Sprintf("%v", len(oidList)))
 
 		for _, oid := range oidList {
-			w.WriteString(fmt.Sprintf(" %v %v", toc.DataEntries[uint(oid)].StartByte, toc.DataEntries[uint(oid)].EndByte))
+			entry := toc.DataEntries[uint(oid)]
+			if toc.Framed {
+				// The plugin returns the frames of the requested tables, which are decompressed as one stream
+				w.WriteString(fmt.Sprintf(" %v %v", entry.FrameStart, entry.FrameEnd))
+			} else {
+				w.WriteString(fmt.Sprintf(" %v %v", entry.StartByte, entry.EndByte))
+			}
 		}
 		w.Flush()
 		cmdStr = fmt.Sprintf("%s restore_data_subset %s %s %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath, fileToRead, offsetsFile.Name())
@@ -0,0 +1,99 @@
+package helper
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cloudberrydb/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("helper/restore_helper tests", func() {
+	tableData := func(oid int) string {
+		return strings.Repeat(fmt.Sprintf("data of table %d\n", oid), 100)
+	}
+	writeFramedDataFile := func(filename string, newWriter func(io.WriteCloser) (BackupPipeWriterCloser, error)) *toc.SegmentTOC {
+		tocfile := &toc.SegmentTOC{DataEntries: make(map[uint]toc.SegmentDataEntry)}
+		dataFile, err := os.Create(filename)
+		Expect(err).ToNot(HaveOccurred())
+		pipeWriter, err := newWriter(dataFile)
+		Expect(err).ToNot(HaveOccurred())
+		var lastRead, lastFrameEnd uint64
+		for _, oid := range []int{1, 2, 3} {
+			numBytes, err := pipeWriter.Write([]byte(tableData(oid)))
+			Expect(err).ToNot(HaveOccurred())
+			lastFrameEnd, err = addSegmentDataEntry(tocfile, pipeWriter, oid, lastRead, lastRead+uint64(numBytes), lastFrameEnd)
+			Expect(err).ToNot(HaveOccurred())
+			lastRead += uint64(numBytes)
+		}
+		Expect(pipeWriter.Close()).To(Succeed())
+		return tocfile
+	}
+	readTable := func(reader *RestoreReader, oid int) string {
+		Expect(reader.positionReader(0, oid)).To(Succeed())
+		entry := reader.segmentTOC.DataEntries[uint(oid)]
+		data, err := io.ReadAll(io.LimitReader(reader.bufReader, int64(entry.EndByte-entry.StartByte)))
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	Describe("FRAMED RestoreReader", func() {
+		DescribeTable("seeks to the frame of a table in the middle of the data file and decompresses only that frame",
+			func(extension string, newWriter func(io.WriteCloser) (BackupPipeWriterCloser, error)) {
+				filename := path.Join(GinkgoT().TempDir(), "gpbackup_0_20170101010101"+extension)
+				tocfile := writeFramedDataFile(filename, newWriter)
+				Expect(tocfile.Framed).To(BeTrue())
+				middleEntry := tocfile.DataEntries[2]
+				Expect(middleEntry.FrameStart).To(Equal(tocfile.DataEntries[1].FrameEnd))
+				Expect(middleEntry.FrameEnd).To(Equal(tocfile.DataEntries[3].FrameStart))
+
+				// The reader may only read the frame of each requested table, so the other frames are corrupted
+				dataFile, err := os.OpenFile(filename, os.O_RDWR, 0)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = dataFile.WriteAt([]byte(strings.Repeat("x", int(tocfile.DataEntries[1].FrameEnd))), 0)
+				Expect(err).ToNot(HaveOccurred())
+				defer dataFile.Close()
+				reader := &RestoreReader{readerType: FRAMED, seekReader: dataFile, fileToRead: filename, segmentTOC: tocfile}
+
+				Expect(readTable(reader, 2)).To(Equal(tableData(2)))
+				Expect(readTable(reader, 3)).To(Equal(tableData(3)))
+			},
+			Entry("gzip", ".gz", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewGZipBackupPipeWriterCloser(writeHandle, 1, 1)
+			}),
+			Entry("gzip with parallel compression", ".gz", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewGZipBackupPipeWriterCloser(writeHandle, 1, 2)
+			}),
+			Entry("zstd", ".zst", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewZSTDBackupPipeWriterCloser(writeHandle, 1, 1)
+			}),
+			Entry("lz4", ".lz4", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewLZ4BackupPipeWriterCloser(writeHandle, 1, 1)
+			}),
+			Entry("lz4 high compression", ".lz4", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewLZ4BackupPipeWriterCloser(writeHandle, 6, 1)
+			}),
+			Entry("none", ".raw", func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewRawBackupPipeWriterCloser(writeHandle), nil
+			}),
+		)
+		It("reads no data for a table whose frame is empty", func() {
+			filename := path.Join(GinkgoT().TempDir(), "gpbackup_0_20170101010101.zst")
+			tocfile := writeFramedDataFile(filename, func(writeHandle io.WriteCloser) (BackupPipeWriterCloser, error) {
+				return NewZSTDBackupPipeWriterCloser(writeHandle, 1, 1)
+			})
+			tocfile.DataEntries[4] = toc.SegmentDataEntry{}
+			dataFile, err := os.Open(filename)
+			Expect(err).ToNot(HaveOccurred())
+			defer dataFile.Close()
+			reader := &RestoreReader{readerType: FRAMED, seekReader: dataFile, fileToRead: filename, segmentTOC: tocfile}
+
+			Expect(readTable(reader, 4)).To(BeEmpty())
+		})
+	})
+})
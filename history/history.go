@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"io"
 	"io/ioutil"
+	"maps"
 	"os"
 	"path/filepath"
 	"sort"
@@ -13,6 +14,7 @@ import (
 	"github.com/cloudberrydb/gp-common-go-libs/operating"
 	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/nightlyone/lockfile"
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
@@ -45,6 +47,7 @@ type BackupConfig struct {
 	IncludeSchemas        []string
 	IncludeTableFiltered  bool
 	Incremental           bool
+	Differential          bool `yaml:",omitempty"`
 	LeafPartitionData     bool
 	MetadataOnly          bool
 	Plugin                string
@@ -55,6 +58,13 @@ type BackupConfig struct {
 	EndTime               string
 	WithoutGlobals        bool
 	WithStatistics        bool
+	WithChecksums         bool
+	EncryptionFingerprint string
+	RowFilters            map[string]string                       `yaml:",omitempty"`
+	MaskingRules          map[string]map[string]utils.MaskingRule `yaml:",omitempty"`
+	SamplePercent         float64                                 `yaml:",omitempty"`
+	SampleForeignKeys     bool                                    `yaml:",omitempty"`
+	Resumed               bool
 	Status                string
 }
 
@@ -62,9 +72,38 @@ func (backup *BackupConfig) Failed() bool {
 	return backup.Status == BackupStatusFailed
 }
 
+func (backup *BackupConfig) Deleted() bool {
+	return backup.DateDeleted != ""
+}
+
+// A full backup has all of the metadata and data that an incremental backup can be based on
+func (backup *BackupConfig) IsFull() bool {
+	return !backup.Incremental && !backup.MetadataOnly && !backup.DataOnly
+}
+
+func (backup *BackupConfig) Sampled() bool {
+	return backup.SamplePercent > 0
+}
+
+/*
+ * Two backups select the same data if they were taken with the same table and
+ * schema filters, row filters, masking rules and sample, so that the data of a
+ * table in one can stand in for that in the other.
+ */
+func (backup *BackupConfig) SelectsSameData(other *BackupConfig) bool {
+	columnRulesEqual := func(rules1, rules2 map[string]utils.MaskingRule) bool { return maps.Equal(rules1, rules2) }
+	return utils.NewIncludeSet(backup.IncludeRelations).Equals(utils.NewIncludeSet(other.IncludeRelations)) &&
+		utils.NewIncludeSet(backup.IncludeSchemas).Equals(utils.NewIncludeSet(other.IncludeSchemas)) &&
+		utils.NewIncludeSet(backup.ExcludeRelations).Equals(utils.NewIncludeSet(other.ExcludeRelations)) &&
+		utils.NewIncludeSet(backup.ExcludeSchemas).Equals(utils.NewIncludeSet(other.ExcludeSchemas)) &&
+		maps.Equal(backup.RowFilters, other.RowFilters) &&
+		maps.EqualFunc(backup.MaskingRules, other.MaskingRules, columnRulesEqual) &&
+		backup.SamplePercent == other.SamplePercent
+}
+
 func ReadConfigFile(filename string) *BackupConfig {
 	config := &BackupConfig{}
-	contents, err := ioutil.ReadFile(filename)
+	contents, err := utils.ReadBackupFile(filename)
 	gplog.FatalOnError(err)
 	err = yaml.Unmarshal(contents, config)
 	gplog.FatalOnError(err)
@@ -218,3 +257,83 @@ func (history *History) FindBackupConfig(timestamp string) *BackupConfig {
 	}
 	return nil
 }
+
+/*
+ * Returns the timestamps of all live backups, other than the given one, whose
+ * restore plan references the given timestamp.  Deleting a backup with
+ * dependents would leave those incremental backups unrestorable.
+ */
+func (history *History) FindDependentBackups(timestamp string) []string {
+	dependents := make([]string, 0)
+	for _, backupConfig := range history.BackupConfigs {
+		if backupConfig.Timestamp == timestamp || backupConfig.Failed() || backupConfig.Deleted() {
+			continue
+		}
+		for _, entry := range backupConfig.RestorePlan {
+			if entry.Timestamp == timestamp {
+				dependents = append(dependents, backupConfig.Timestamp)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+func (history *History) MarkBackupDeleted(timestamp string) error {
+	for i := range history.BackupConfigs {
+		if history.BackupConfigs[i].Timestamp == timestamp {
+			history.BackupConfigs[i].DateDeleted = CurrentTimestamp()
+			return nil
+		}
+	}
+	return errors.Errorf("Backup %s not found in history file", timestamp)
+}
+
+func (history *History) RemoveBackupConfig(timestamp string) {
+	backupConfigs := make([]BackupConfig, 0, len(history.BackupConfigs))
+	for _, backupConfig := range history.BackupConfigs {
+		if backupConfig.Timestamp != timestamp {
+			backupConfigs = append(backupConfigs, backupConfig)
+		}
+	}
+	history.BackupConfigs = backupConfigs
+}
+
+/*
+ * A resumed backup keeps the timestamp of the backup it finishes, so the
+ * entry for the failed attempt is removed before the new one is written.
+ */
+func RemoveBackupFromHistory(historyFilePath string, timestamp string) error {
+	lock := LockHistoryFile()
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	history, _, err := NewHistory(historyFilePath)
+	if err != nil {
+		return err
+	}
+	history.RemoveBackupConfig(timestamp)
+	return history.WriteToFileAndMakeReadOnly(historyFilePath)
+}
+
+/*
+ * The history file is re-read under the lock so that entries written by a
+ * concurrent gpbackup run while the files were being deleted are preserved.
+ */
+func MarkBackupDeletedInHistory(historyFilePath string, timestamp string) error {
+	lock := LockHistoryFile()
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	history, _, err := NewHistory(historyFilePath)
+	if err != nil {
+		return err
+	}
+	err = history.MarkBackupDeleted(timestamp)
+	if err != nil {
+		return err
+	}
+	return history.WriteToFileAndMakeReadOnly(historyFilePath)
+}
@@ -152,7 +152,6 @@ var _ = Describe("backup/history tests", func() {
 			fileHash, err := utils.GetFileHash(historyFilePath)
 			Expect(err).ToNot(HaveOccurred())
 
-
 			resultHistory, historyHash, err := history.NewHistory(historyFilePath)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(historyWithEntries).To(structmatcher.MatchStruct(resultHistory))
@@ -320,4 +319,165 @@ var _ = Describe("backup/history tests", func() {
 			Expect(foundConfig).To(BeNil())
 		})
 	})
+	Describe("FindDependentBackups", func() {
+		var testHistory history.History
+		BeforeEach(func() {
+			testConfig2.RestorePlan = []history.RestorePlanEntry{{Timestamp: "timestamp1"}, {Timestamp: "timestamp2"}}
+			testConfig3.RestorePlan = []history.RestorePlanEntry{{Timestamp: "timestamp1"}, {Timestamp: "timestamp2"}, {Timestamp: "timestamp3"}}
+			testHistory = history.History{
+				BackupConfigs: []history.BackupConfig{testConfig3, testConfig2, testConfig1},
+			}
+		})
+		It("returns every backup whose restore plan references the timestamp", func() {
+			Expect(testHistory.FindDependentBackups("timestamp1")).To(Equal([]string{"timestamp3", "timestamp2"}))
+			Expect(testHistory.FindDependentBackups("timestamp2")).To(Equal([]string{"timestamp3"}))
+		})
+		It("returns no dependents for the latest backup in a chain", func() {
+			Expect(testHistory.FindDependentBackups("timestamp3")).To(BeEmpty())
+		})
+		It("ignores dependents that are deleted or failed", func() {
+			testHistory.BackupConfigs[0].DateDeleted = "20170101010101"
+			testHistory.BackupConfigs[1].Status = history.BackupStatusFailed
+			Expect(testHistory.FindDependentBackups("timestamp1")).To(BeEmpty())
+		})
+	})
+	Describe("MarkBackupDeleted", func() {
+		It("sets the deletion date of the matching backup", func() {
+			operating.System.Now = func() time.Time { return time.Date(2017, time.January, 1, 1, 1, 1, 1, time.Local) }
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			testHistory := history.History{BackupConfigs: []history.BackupConfig{testConfig2, testConfig1}}
+
+			err := testHistory.MarkBackupDeleted("timestamp1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(testHistory.BackupConfigs[1].DateDeleted).To(Equal("20170101010101"))
+			Expect(testHistory.BackupConfigs[1].Deleted()).To(BeTrue())
+			Expect(testHistory.BackupConfigs[0].Deleted()).To(BeFalse())
+		})
+		It("returns an error when the timestamp is not found", func() {
+			testHistory := history.History{BackupConfigs: []history.BackupConfig{testConfig1}}
+
+			err := testHistory.MarkBackupDeleted("foo")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(Equal("Backup foo not found in history file"))
+		})
+	})
+	Describe("MarkBackupDeletedInHistory", func() {
+		It("records the deletion date in the history file", func() {
+			testHistory := history.History{BackupConfigs: []history.BackupConfig{testConfig2, testConfig1}}
+			err := testHistory.WriteToFileAndMakeReadOnly(historyFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			operating.System.Now = func() time.Time { return time.Date(2017, time.January, 4, 1, 1, 1, 1, time.Local) }
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+
+			err = history.MarkBackupDeletedInHistory(historyFilePath, "timestamp1")
+			Expect(err).ToNot(HaveOccurred())
+
+			resultHistory, _, err := history.NewHistory(historyFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resultHistory.BackupConfigs[1].DateDeleted).To(Equal("20170104010101"))
+			Expect(resultHistory.BackupConfigs[0].Deleted()).To(BeFalse())
+		})
+		It("returns an error when the backup is not in the history file", func() {
+			testHistory := history.History{BackupConfigs: []history.BackupConfig{testConfig1}}
+			err := testHistory.WriteToFileAndMakeReadOnly(historyFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = history.MarkBackupDeletedInHistory(historyFilePath, "timestamp3")
+			Expect(err).To(MatchError("Backup timestamp3 not found in history file"))
+		})
+	})
+	Describe("RemoveBackupFromHistory", func() {
+		It("removes only the entry with the given timestamp from the history file", func() {
+			testHistory := history.History{BackupConfigs: []history.BackupConfig{testConfig2, testConfig1}}
+			err := testHistory.WriteToFileAndMakeReadOnly(historyFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = history.RemoveBackupFromHistory(historyFilePath, "timestamp1")
+			Expect(err).ToNot(HaveOccurred())
+
+			resultHistory, _, err := history.NewHistory(historyFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resultHistory.BackupConfigs).To(Equal([]history.BackupConfig{testConfig2}))
+		})
+	})
+	Describe("FindExpiredBackups", func() {
+		var full1, incr1, full2, incr2, full3, failed, otherDB history.BackupConfig
+		var testHistory history.History
+		now := time.Date(2017, time.January, 10, 0, 0, 0, 0, time.Local)
+		getTimestamps := func(configs []history.BackupConfig) []string {
+			timestamps := make([]string, 0)
+			for _, config := range configs {
+				timestamps = append(timestamps, config.Timestamp)
+			}
+			return timestamps
+		}
+		BeforeEach(func() {
+			full1 = history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170101000000",
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170101000000"}}}
+			incr1 = history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170102000000", Incremental: true,
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170101000000"}, {Timestamp: "20170102000000"}}}
+			full2 = history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170103000000",
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170103000000"}}}
+			incr2 = history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170104000000", Incremental: true,
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170103000000"}, {Timestamp: "20170104000000"}}}
+			failed = history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170105000000", Status: history.BackupStatusFailed}
+			otherDB = history.BackupConfig{DatabaseName: "otherdb", Timestamp: "20170106000000",
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170106000000"}}}
+			full3 = history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170109000000",
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170109000000"}}}
+			testHistory = history.History{BackupConfigs: []history.BackupConfig{full3, otherDB, failed, incr2, full2, incr1, full1}}
+		})
+		It("expires nothing when no retention policy is set", func() {
+			expired := testHistory.FindExpiredBackups(&full3, history.RetentionPolicy{}, now)
+			Expect(expired).To(BeEmpty())
+		})
+		It("keeps the newest full backups and the incremental backups based on them", func() {
+			expired := testHistory.FindExpiredBackups(&full3, history.RetentionPolicy{RetainFull: 2}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170105000000", "20170102000000", "20170101000000"}))
+		})
+		It("keeps every backup taken within the retention period", func() {
+			expired := testHistory.FindExpiredBackups(&full3, history.RetentionPolicy{RetainDays: 7}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170102000000", "20170101000000"}))
+		})
+		It("keeps a backup if either rule retains it", func() {
+			expired := testHistory.FindExpiredBackups(&full3, history.RetentionPolicy{RetainFull: 1, RetainDays: 5}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170104000000", "20170103000000", "20170102000000", "20170101000000"}))
+		})
+		It("never expires a full backup that a retained incremental backup depends on", func() {
+			expired := testHistory.FindExpiredBackups(&full3, history.RetentionPolicy{RetainDays: 6}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170102000000", "20170101000000"}))
+		})
+		It("always keeps the current backup and its chain", func() {
+			expired := testHistory.FindExpiredBackups(&incr2, history.RetentionPolicy{RetainFull: 1}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170105000000", "20170102000000", "20170101000000"}))
+		})
+		It("does not count metadata-only or data-only backups as full backups", func() {
+			full3.MetadataOnly = true
+			metadataOnly := full3
+			dataOnly := history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170108000000", DataOnly: true,
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170108000000"}}}
+			testHistory.BackupConfigs = append([]history.BackupConfig{metadataOnly, dataOnly}, testHistory.BackupConfigs[1:]...)
+			expired := testHistory.FindExpiredBackups(&metadataOnly, history.RetentionPolicy{RetainFull: 1}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170108000000", "20170105000000", "20170102000000", "20170101000000"}))
+		})
+		It("neither expires nor counts backups that select different data", func() {
+			filtered := history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170109120000", IncludeRelations: []string{"public.foo"},
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170109120000"}}}
+			filtered2 := history.BackupConfig{DatabaseName: "testdb", Timestamp: "20170109130000", IncludeRelations: []string{"public.foo"},
+				RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170109130000"}}}
+			testHistory.BackupConfigs = append([]history.BackupConfig{filtered2, filtered}, testHistory.BackupConfigs...)
+
+			expired := testHistory.FindExpiredBackups(&filtered2, history.RetentionPolicy{RetainFull: 1}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170109120000"}))
+
+			expired = testHistory.FindExpiredBackups(&full3, history.RetentionPolicy{RetainFull: 2}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170105000000", "20170102000000", "20170101000000"}))
+		})
+		It("ignores backups that are already deleted", func() {
+			testHistory.BackupConfigs[6].DateDeleted = "20170102000000"
+			testHistory.BackupConfigs[5].DateDeleted = "20170102000000"
+			expired := testHistory.FindExpiredBackups(&full3, history.RetentionPolicy{RetainFull: 1}, now)
+			Expect(getTimestamps(expired)).To(Equal([]string{"20170105000000", "20170104000000", "20170103000000"}))
+		})
+	})
 })
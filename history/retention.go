@@ -0,0 +1,93 @@
+package history
+
+import (
+	"time"
+)
+
+/*
+ * A backup is retained if it is one of the newest RetainFull full backups (or
+ * an incremental backup based on one of them), where metadata-only and
+ * data-only backups do not count as full backups, or if it was taken within the
+ * last RetainDays days.  A value of 0 disables the corresponding rule.  Only
+ * the backups that select the same data as the current backup are subject to
+ * its policy, so that a filtered backup never expires an unfiltered one.
+ */
+type RetentionPolicy struct {
+	RetainFull int
+	RetainDays int
+}
+
+func (policy RetentionPolicy) IsSet() bool {
+	return policy.RetainFull > 0 || policy.RetainDays > 0
+}
+
+/*
+ * Returns the backups of the same database and plugin as currentBackup that
+ * select the same data and fall outside the retention policy, newest first.  The current backup is
+ * always retained, and a backup is never returned while any backup that will
+ * survive still references it in its restore plan, so pruning can never break
+ * an incremental chain.
+ */
+func (history *History) FindExpiredBackups(currentBackup *BackupConfig, policy RetentionPolicy, now time.Time) []BackupConfig {
+	expired := make([]BackupConfig, 0)
+	if !policy.IsSet() {
+		return expired
+	}
+
+	isCandidate := func(backupConfig *BackupConfig) bool {
+		return backupConfig.DatabaseName == currentBackup.DatabaseName && backupConfig.Plugin == currentBackup.Plugin &&
+			backupConfig.SelectsSameData(currentBackup)
+	}
+	candidates := make([]BackupConfig, 0)
+	for _, backupConfig := range history.BackupConfigs {
+		if isCandidate(&backupConfig) && !backupConfig.Deleted() {
+			candidates = append(candidates, backupConfig)
+		}
+	}
+
+	retained := map[string]bool{currentBackup.Timestamp: true}
+	if policy.RetainFull > 0 {
+		numFull := 0
+		for _, backupConfig := range candidates {
+			if backupConfig.IsFull() && !backupConfig.Failed() && numFull < policy.RetainFull {
+				retained[backupConfig.Timestamp] = true
+				numFull++
+			}
+		}
+		for _, backupConfig := range candidates {
+			if backupConfig.Incremental && !backupConfig.Failed() && len(backupConfig.RestorePlan) > 0 && retained[backupConfig.RestorePlan[0].Timestamp] {
+				retained[backupConfig.Timestamp] = true
+			}
+		}
+	}
+	if policy.RetainDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.RetainDays).Format("20060102150405")
+		for _, backupConfig := range candidates {
+			if backupConfig.Timestamp >= cutoff {
+				retained[backupConfig.Timestamp] = true
+			}
+		}
+	}
+
+	/*
+	 * Every restore plan lists the whole chain back to its full backup, so
+	 * protecting the direct entries of each surviving plan is sufficient.
+	 */
+	for _, backupConfig := range history.BackupConfigs {
+		if backupConfig.Deleted() || backupConfig.Failed() {
+			continue
+		}
+		if retained[backupConfig.Timestamp] || !isCandidate(&backupConfig) {
+			for _, entry := range backupConfig.RestorePlan {
+				retained[entry.Timestamp] = true
+			}
+		}
+	}
+
+	for _, backupConfig := range candidates {
+		if !retained[backupConfig.Timestamp] {
+			expired = append(expired, backupConfig)
+		}
+	}
+	return expired
+}
@@ -279,4 +279,29 @@ var _ = Describe("backup integration tests", func() {
 			})
 		})
 	})
+	Describe("GetHeapIncrementalMetadata", func() {
+		var heapTableFQN = "public.heap_foo"
+		BeforeEach(func() {
+			testhelper.AssertQueryRuns(connectionPool, fmt.Sprintf("CREATE TABLE %s (i int) DISTRIBUTED BY (i)", heapTableFQN))
+		})
+		AfterEach(func() {
+			testhelper.AssertQueryRuns(connectionPool, fmt.Sprintf(dropTableSQL, heapTableFQN))
+		})
+		It("retrieves heap metadata only for heap tables", func() {
+			heapIncrementalMetadata, statsTimestamp := backup.GetHeapIncrementalMetadata(connectionPool)
+
+			Expect(statsTimestamp).To(Not(BeEmpty()))
+			Expect(heapIncrementalMetadata).To(HaveKey(heapTableFQN))
+			Expect(heapIncrementalMetadata[heapTableFQN].LastDDLTimestamp).To(Not(BeEmpty()))
+			Expect(heapIncrementalMetadata).To(Not(HaveKey(aoTableFQN)))
+		})
+		It("has a changed relfilenode after the table is truncated", func() {
+			initialHeapIncrementalMetadata, _ := backup.GetHeapIncrementalMetadata(connectionPool)
+			testhelper.AssertQueryRuns(connectionPool, fmt.Sprintf("TRUNCATE %s", heapTableFQN))
+
+			heapIncrementalMetadata, _ := backup.GetHeapIncrementalMetadata(connectionPool)
+			Expect(heapIncrementalMetadata[heapTableFQN].Relfilenode).
+				To(Not(Equal(initialHeapIncrementalMetadata[heapTableFQN].Relfilenode)))
+		})
+	})
 })
@@ -0,0 +1,275 @@
+package manager
+
+/*
+ * This file contains the diff-backups subcommand, which compares the catalog
+ * of two backups using their TOC and metadata files.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	path "path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/history"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+/*
+ * Statistics change between almost any two backups and are not DDL, so only
+ * these sections are compared.
+ */
+var diffSections = []string{"global", "predata", "postdata"}
+
+type ObjectDiff struct {
+	Section         string `json:"section"`
+	Schema          string `json:"schema"`
+	Name            string `json:"name"`
+	ObjectType      string `json:"object_type"`
+	ReferenceObject string `json:"reference_object,omitempty"`
+	OldStatement    string `json:"old_statement,omitempty"`
+	NewStatement    string `json:"new_statement,omitempty"`
+}
+
+type RowCountDiff struct {
+	Schema        string `json:"schema"`
+	Name          string `json:"name"`
+	OldRowsCopied int64  `json:"old_rows_copied"`
+	NewRowsCopied int64  `json:"new_rows_copied"`
+	Delta         int64  `json:"delta"`
+}
+
+type BackupDiff struct {
+	OldTimestamp string         `json:"old_timestamp"`
+	NewTimestamp string         `json:"new_timestamp"`
+	Added        []ObjectDiff   `json:"added"`
+	Dropped      []ObjectDiff   `json:"dropped"`
+	Changed      []ObjectDiff   `json:"changed"`
+	RowCounts    []RowCountDiff `json:"row_counts"`
+}
+
+func diffBackupsCommand() *cobra.Command {
+	diffCmd := &cobra.Command{
+		Use:   "diff-backups <old timestamp> <new timestamp>",
+		Short: "Show the catalog changes and row count changes between two backups",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			defer DoTeardown()
+			setLoggerVerbosity(cmd)
+			format := options.MustGetFlagString(cmd.Flags(), options.FORMAT)
+			if format != "text" && format != "json" {
+				gplog.Fatal(errors.Errorf("Invalid --%s %s; valid values are text and json", options.FORMAT, format), "")
+			}
+			keyFile := options.MustGetFlagString(cmd.Flags(), options.ENCRYPTION_KEY_FILE)
+			if keyFile != "" {
+				key, err := utils.ReadEncryptionKeyFile(keyFile)
+				gplog.FatalOnError(err)
+				utils.SetEncryptionKey(key)
+			}
+			backupHistory := mustReadHistory(cmd)
+			coordinatorDataDir := path.Dir(getHistoryFilePath(cmd))
+			oldTOC, oldMetadata := mustReadCatalog(mustFindBackupConfig(backupHistory, args[0]), coordinatorDataDir)
+			newTOC, newMetadata := mustReadCatalog(mustFindBackupConfig(backupHistory, args[1]), coordinatorDataDir)
+			diff, err := DiffBackups(args[0], oldTOC, oldMetadata, args[1], newTOC, newMetadata)
+			gplog.FatalOnError(err)
+			if format == "json" {
+				err = WriteBackupDiffJSON(operating.System.Stdout, diff)
+			} else {
+				err = WriteBackupDiffText(operating.System.Stdout, diff)
+			}
+			gplog.FatalOnError(err)
+		},
+	}
+	diffCmd.Flags().String(options.FORMAT, "text", "The output format. Valid values are text and json")
+	diffCmd.Flags().String(options.ENCRYPTION_KEY_FILE, "", "The absolute path of the file containing the key with which the backups were encrypted")
+	return diffCmd
+}
+
+func mustReadCatalog(backupConfig *history.BackupConfig, coordinatorDataDir string) (*toc.TOC, []byte) {
+	if backupConfig.DataOnly {
+		gplog.Fatal(errors.Errorf("Backup %s is a data-only backup and has no metadata to compare", backupConfig.Timestamp), "")
+	}
+	fpInfo, err := getCoordinatorFPInfo(backupConfig, coordinatorDataDir)
+	gplog.FatalOnError(err)
+	metadata, err := utils.ReadBackupFile(fpInfo.GetMetadataFilePath())
+	if err != nil {
+		gplog.Fatal(errors.Errorf("Unable to read metadata file for backup %s: %v", backupConfig.Timestamp, err), "")
+	}
+	return toc.NewTOC(fpInfo.GetTOCFilePath()), metadata
+}
+
+type objectKey struct {
+	section         string
+	schema          string
+	name            string
+	objectType      string
+	referenceObject string
+}
+
+/*
+ * An object can have several entries in a section, such as its definition
+ * followed by its comment and privileges, so the statements of all of its
+ * entries are compared together.
+ */
+func getObjectStatements(tocfile *toc.TOC, metadata []byte) (map[objectKey]string, []objectKey, error) {
+	statements := make(map[objectKey]string)
+	keys := make([]objectKey, 0)
+	sectionEntries := map[string][]toc.MetadataEntry{
+		"global":   tocfile.GlobalEntries,
+		"predata":  tocfile.PredataEntries,
+		"postdata": tocfile.PostdataEntries,
+	}
+	for _, section := range diffSections {
+		for _, entry := range sectionEntries[section] {
+			if entry.EndByte > uint64(len(metadata)) || entry.StartByte > entry.EndByte {
+				return nil, nil, errors.Errorf("TOC entry for %s %s is outside of the metadata file", entry.ObjectType, entry.Name)
+			}
+			key := objectKey{section, entry.Schema, entry.Name, entry.ObjectType, entry.ReferenceObject}
+			if _, ok := statements[key]; !ok {
+				keys = append(keys, key)
+			}
+			statements[key] += string(metadata[entry.StartByte:entry.EndByte])
+		}
+	}
+	return statements, keys, nil
+}
+
+func newObjectDiff(key objectKey, oldStatement string, newStatement string) ObjectDiff {
+	return ObjectDiff{
+		Section:         key.section,
+		Schema:          key.schema,
+		Name:            key.name,
+		ObjectType:      key.objectType,
+		ReferenceObject: key.referenceObject,
+		OldStatement:    strings.TrimSpace(oldStatement),
+		NewStatement:    strings.TrimSpace(newStatement),
+	}
+}
+
+/*
+ * Objects are listed in the order they appear in the metadata file of the
+ * backup they are found in.  Row counts are only compared for tables whose
+ * data is in both backups, as an incremental backup does not contain the data
+ * of unmodified tables and tables that were added or dropped are already
+ * listed as such.
+ */
+func DiffBackups(oldTimestamp string, oldTOC *toc.TOC, oldMetadata []byte, newTimestamp string, newTOC *toc.TOC, newMetadata []byte) (*BackupDiff, error) {
+	oldStatements, oldKeys, err := getObjectStatements(oldTOC, oldMetadata)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read metadata of backup %s", oldTimestamp)
+	}
+	newStatements, newKeys, err := getObjectStatements(newTOC, newMetadata)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read metadata of backup %s", newTimestamp)
+	}
+
+	diff := &BackupDiff{
+		OldTimestamp: oldTimestamp,
+		NewTimestamp: newTimestamp,
+		Added:        make([]ObjectDiff, 0),
+		Dropped:      make([]ObjectDiff, 0),
+		Changed:      make([]ObjectDiff, 0),
+		RowCounts:    make([]RowCountDiff, 0),
+	}
+	for _, key := range newKeys {
+		oldStatement, ok := oldStatements[key]
+		if !ok {
+			diff.Added = append(diff.Added, newObjectDiff(key, "", newStatements[key]))
+		} else if strings.TrimSpace(oldStatement) != strings.TrimSpace(newStatements[key]) {
+			diff.Changed = append(diff.Changed, newObjectDiff(key, oldStatement, newStatements[key]))
+		}
+	}
+	for _, key := range oldKeys {
+		if _, ok := newStatements[key]; !ok {
+			diff.Dropped = append(diff.Dropped, newObjectDiff(key, oldStatements[key], ""))
+		}
+	}
+
+	oldRowsCopied := make(map[string]int64, len(oldTOC.DataEntries))
+	for _, entry := range oldTOC.DataEntries {
+		oldRowsCopied[entry.Schema+"."+entry.Name] = entry.RowsCopied
+	}
+	for _, entry := range newTOC.DataEntries {
+		rowsCopied, ok := oldRowsCopied[entry.Schema+"."+entry.Name]
+		if ok && rowsCopied != entry.RowsCopied {
+			diff.RowCounts = append(diff.RowCounts, RowCountDiff{
+				Schema:        entry.Schema,
+				Name:          entry.Name,
+				OldRowsCopied: rowsCopied,
+				NewRowsCopied: entry.RowsCopied,
+				Delta:         entry.RowsCopied - rowsCopied,
+			})
+		}
+	}
+	sort.Slice(diff.RowCounts, func(i, j int) bool {
+		if diff.RowCounts[i].Schema != diff.RowCounts[j].Schema {
+			return diff.RowCounts[i].Schema < diff.RowCounts[j].Schema
+		}
+		return diff.RowCounts[i].Name < diff.RowCounts[j].Name
+	})
+	return diff, nil
+}
+
+func (object ObjectDiff) String() string {
+	name := object.Name
+	if object.Schema != "" {
+		name = object.Schema + "." + object.Name
+	}
+	if object.ReferenceObject != "" && object.ReferenceObject != name {
+		return fmt.Sprintf("%s %s on %s", object.ObjectType, name, object.ReferenceObject)
+	}
+	return fmt.Sprintf("%s %s", object.ObjectType, name)
+}
+
+func writeStatementLines(writer io.Writer, prefix string, statement string) {
+	if statement == "" {
+		return
+	}
+	for _, line := range strings.Split(statement, "\n") {
+		_, _ = fmt.Fprintf(writer, "    %s %s\n", prefix, line)
+	}
+}
+
+func writeObjectDiffs(writer io.Writer, heading string, objects []ObjectDiff, withStatements bool) {
+	_, _ = fmt.Fprintf(writer, "\n%s: %d\n", heading, len(objects))
+	for _, object := range objects {
+		_, _ = fmt.Fprintf(writer, "  %s\n", object)
+		if withStatements {
+			writeStatementLines(writer, "-", object.OldStatement)
+			writeStatementLines(writer, "+", object.NewStatement)
+		}
+	}
+}
+
+func WriteBackupDiffText(writer io.Writer, diff *BackupDiff) error {
+	_, err := fmt.Fprintf(writer, "Catalog changes from backup %s to backup %s\n", diff.OldTimestamp, diff.NewTimestamp)
+	if err != nil {
+		return err
+	}
+	writeObjectDiffs(writer, "Added objects", diff.Added, false)
+	writeObjectDiffs(writer, "Dropped objects", diff.Dropped, false)
+	writeObjectDiffs(writer, "Changed objects", diff.Changed, true)
+	_, _ = fmt.Fprintf(writer, "\nRow count changes: %d\n", len(diff.RowCounts))
+	for _, rowCount := range diff.RowCounts {
+		_, _ = fmt.Fprintf(writer, "  %s.%s: %d -> %d (%+d)\n", rowCount.Schema, rowCount.Name,
+			rowCount.OldRowsCopied, rowCount.NewRowsCopied, rowCount.Delta)
+	}
+	return nil
+}
+
+func WriteBackupDiffJSON(writer io.Writer, diff *BackupDiff) error {
+	contents, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(writer, "%s\n", contents)
+	return err
+}
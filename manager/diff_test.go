@@ -0,0 +1,102 @@
+package manager_test
+
+import (
+	"github.com/cloudberrydb/gpbackup/manager"
+	"github.com/cloudberrydb/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("manager/diff tests", func() {
+	var oldTOC, newTOC *toc.TOC
+	var oldMetadata, newMetadata []byte
+	addEntry := func(tocfile *toc.TOC, metadata *[]byte, section string, entry toc.MetadataEntry, statement string) {
+		start := uint64(len(*metadata))
+		*metadata = append(*metadata, []byte(statement)...)
+		tocfile.AddMetadataEntry(section, entry, start, uint64(len(*metadata)))
+	}
+	BeforeEach(func() {
+		oldTOC, newTOC = &toc.TOC{}, &toc.TOC{}
+		oldTOC.InitializeMetadataEntryMap()
+		newTOC.InitializeMetadataEntryMap()
+		oldMetadata, newMetadata = []byte{}, []byte{}
+
+		schema := toc.MetadataEntry{Name: "public", ObjectType: "SCHEMA"}
+		foo := toc.MetadataEntry{Schema: "public", Name: "foo", ObjectType: "TABLE"}
+		addEntry(oldTOC, &oldMetadata, "predata", schema, "\n\nCREATE SCHEMA public;\n")
+		addEntry(newTOC, &newMetadata, "predata", schema, "\n\nCREATE SCHEMA public;\n")
+		addEntry(oldTOC, &oldMetadata, "predata", foo, "\n\nCREATE TABLE public.foo (i int);\n")
+		addEntry(newTOC, &newMetadata, "predata", foo, "\n\nCREATE TABLE public.foo (i int, j text);\n")
+		addEntry(oldTOC, &oldMetadata, "predata", toc.MetadataEntry{Schema: "public", Name: "bar", ObjectType: "TABLE"}, "\n\nCREATE TABLE public.bar (i int);\n")
+		addEntry(newTOC, &newMetadata, "postdata", toc.MetadataEntry{Schema: "public", Name: "foo_idx", ObjectType: "INDEX", ReferenceObject: "public.foo"}, "\n\nCREATE INDEX foo_idx ON public.foo(i);\n")
+		addEntry(oldTOC, &oldMetadata, "statistics", toc.MetadataEntry{Schema: "public", Name: "foo", ObjectType: "STATISTICS"}, "\n\nUPDATE pg_class SET reltuples = 1;\n")
+		addEntry(newTOC, &newMetadata, "statistics", toc.MetadataEntry{Schema: "public", Name: "foo", ObjectType: "STATISTICS"}, "\n\nUPDATE pg_class SET reltuples = 2;\n")
+
+		oldTOC.AddCoordinatorDataEntry("public", "foo", 1, "(i)", 100, "", "")
+		oldTOC.AddCoordinatorDataEntry("public", "bar", 2, "(i)", 10, "", "")
+		oldTOC.AddCoordinatorDataEntry("public", "baz", 3, "(i)", 5, "", "")
+		newTOC.AddCoordinatorDataEntry("public", "foo", 1, "(i,j)", 150, "", "")
+		newTOC.AddCoordinatorDataEntry("public", "baz", 3, "(i)", 5, "", "")
+	})
+	Describe("DiffBackups", func() {
+		It("finds added, dropped and changed objects outside of statistics", func() {
+			diff, err := manager.DiffBackups("20170101010101", oldTOC, oldMetadata, "20170102010101", newTOC, newMetadata)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(diff.Added).To(Equal([]manager.ObjectDiff{{Section: "postdata", Schema: "public", Name: "foo_idx", ObjectType: "INDEX",
+				ReferenceObject: "public.foo", NewStatement: "CREATE INDEX foo_idx ON public.foo(i);"}}))
+			Expect(diff.Dropped).To(Equal([]manager.ObjectDiff{{Section: "predata", Schema: "public", Name: "bar", ObjectType: "TABLE",
+				OldStatement: "CREATE TABLE public.bar (i int);"}}))
+			Expect(diff.Changed).To(Equal([]manager.ObjectDiff{{Section: "predata", Schema: "public", Name: "foo", ObjectType: "TABLE",
+				OldStatement: "CREATE TABLE public.foo (i int);", NewStatement: "CREATE TABLE public.foo (i int, j text);"}}))
+		})
+		It("compares all of the entries of an object together", func() {
+			foo := toc.MetadataEntry{Schema: "public", Name: "foo", ObjectType: "TABLE"}
+			addEntry(oldTOC, &oldMetadata, "predata", foo, "\n\nCOMMENT ON TABLE public.foo IS 'foo';\n")
+			addEntry(newTOC, &newMetadata, "predata", foo, "\n\nCOMMENT ON TABLE public.foo IS 'foo';\n")
+
+			diff, err := manager.DiffBackups("20170101010101", oldTOC, oldMetadata, "20170102010101", newTOC, newMetadata)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diff.Changed).To(HaveLen(1))
+			Expect(diff.Changed[0].NewStatement).To(Equal("CREATE TABLE public.foo (i int, j text);\n\n\nCOMMENT ON TABLE public.foo IS 'foo';"))
+		})
+		It("reports row count changes for tables whose data is in both backups", func() {
+			diff, err := manager.DiffBackups("20170101010101", oldTOC, oldMetadata, "20170102010101", newTOC, newMetadata)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(diff.RowCounts).To(Equal([]manager.RowCountDiff{{Schema: "public", Name: "foo", OldRowsCopied: 100, NewRowsCopied: 150, Delta: 50}}))
+		})
+		It("returns an error if a TOC entry is outside of the metadata file", func() {
+			_, err := manager.DiffBackups("20170101010101", oldTOC, oldMetadata[:10], "20170102010101", newTOC, newMetadata)
+			Expect(err).To(MatchError(ContainSubstring("Unable to read metadata of backup 20170101010101")))
+		})
+	})
+	Describe("WriteBackupDiffText", func() {
+		It("lists each change along with the old and new statements of changed objects", func() {
+			diff, err := manager.DiffBackups("20170101010101", oldTOC, oldMetadata, "20170102010101", newTOC, newMetadata)
+			Expect(err).ToNot(HaveOccurred())
+			buffer := NewBuffer()
+			Expect(manager.WriteBackupDiffText(buffer, diff)).To(Succeed())
+
+			Expect(buffer).To(Say(`Catalog changes from backup 20170101010101 to backup 20170102010101\n`))
+			Expect(buffer).To(Say(`Added objects: 1\n  INDEX public.foo_idx on public.foo\n`))
+			Expect(buffer).To(Say(`Dropped objects: 1\n  TABLE public.bar\n`))
+			Expect(buffer).To(Say(`Changed objects: 1\n  TABLE public.foo\n    - CREATE TABLE public.foo \(i int\);\n    \+ CREATE TABLE public.foo \(i int, j text\);\n`))
+			Expect(buffer).To(Say(`Row count changes: 1\n  public.foo: 100 -> 150 \(\+50\)\n`))
+		})
+	})
+	Describe("WriteBackupDiffJSON", func() {
+		It("writes the diff as a JSON object", func() {
+			diff, err := manager.DiffBackups("20170101010101", oldTOC, oldMetadata, "20170102010101", newTOC, newMetadata)
+			Expect(err).ToNot(HaveOccurred())
+			buffer := NewBuffer()
+			Expect(manager.WriteBackupDiffJSON(buffer, diff)).To(Succeed())
+
+			Expect(buffer).To(Say(`"old_timestamp": "20170101010101"`))
+			Expect(buffer).To(Say(`"new_timestamp": "20170102010101"`))
+			Expect(buffer).To(Say(`"added": \[\n    {\n      "section": "postdata"`))
+			Expect(buffer).To(Say(`"row_counts": \[\n    {\n      "schema": "public",\n      "name": "foo",\n      "old_rows_copied": 100,\n      "new_rows_copied": 150,\n      "delta": 50`))
+		})
+	})
+})
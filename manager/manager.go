@@ -0,0 +1,290 @@
+package manager
+
+/*
+ * This file contains the subcommands of gpbackup_manager, which inspects and
+ * manages the backups recorded in gpbackup_history.yaml.
+ */
+
+import (
+	"fmt"
+	"io"
+	"os"
+	path "path/filepath"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/filepath"
+	"github.com/cloudberrydb/gpbackup/history"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	version string
+)
+
+func GetVersion() string {
+	return version
+}
+
+func SetVersion(v string) {
+	version = v
+}
+
+// This function handles setup that can be done before parsing flags.
+func DoInit(cmd *cobra.Command) {
+	gplog.InitializeLogging("gpbackup_manager", "")
+	cmd.PersistentFlags().String(options.HISTORY_FILE, "", "The history file to use. Defaults to gpbackup_history.yaml in the coordinator data directory")
+	cmd.PersistentFlags().Bool(options.VERBOSE, false, "Print verbose log messages")
+	cmd.PersistentFlags().Bool(options.DEBUG, false, "Print verbose and debug log messages")
+	cmd.PersistentFlags().Bool(options.QUIET, false, "Suppress non-warning, non-error log messages")
+	cmd.AddCommand(listBackupsCommand(), displayReportCommand(), deleteBackupCommand(), diffBackupsCommand())
+}
+
+/*
+ * gplog.Fatal panics, so each subcommand defers this to turn the panic into
+ * an exit code instead of a stack trace.
+ */
+func DoTeardown() {
+	if err := recover(); err != nil {
+		// gplog's Fatal will cause a panic with error code 2
+		if gplog.GetErrorCode() != 2 {
+			gplog.Error(fmt.Sprintf("%v: %s", err, debug.Stack()))
+			gplog.SetErrorCode(2)
+		} else {
+			fmt.Println(err)
+		}
+	}
+	os.Exit(gplog.GetErrorCode())
+}
+
+func listBackupsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list-backups",
+		Short: "List the backups recorded in the history file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			defer DoTeardown()
+			setLoggerVerbosity(cmd)
+			backupHistory := mustReadHistory(cmd)
+			gplog.FatalOnError(WriteBackupList(operating.System.Stdout, backupHistory.BackupConfigs))
+		},
+	}
+}
+
+func displayReportCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "display-report <timestamp>",
+		Short: "Display the report file of a backup",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			defer DoTeardown()
+			setLoggerVerbosity(cmd)
+			backupHistory := mustReadHistory(cmd)
+			backupConfig := mustFindBackupConfig(backupHistory, args[0])
+			reportPath, err := GetReportFilePath(backupConfig, path.Dir(getHistoryFilePath(cmd)))
+			gplog.FatalOnError(err)
+			contents, err := operating.System.ReadFile(reportPath)
+			if err != nil {
+				gplog.Fatal(errors.Errorf("Unable to read report file %s for backup %s", reportPath, backupConfig.Timestamp), "")
+			}
+			_, _ = operating.System.Stdout.Write(contents)
+		},
+	}
+}
+
+func deleteBackupCommand() *cobra.Command {
+	deleteCmd := &cobra.Command{
+		Use:   "delete-backup <timestamp>",
+		Short: "Delete the files of a backup and mark it as deleted in the history file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			defer DoTeardown()
+			setLoggerVerbosity(cmd)
+			DeleteBackup(getHistoryFilePath(cmd), args[0],
+				options.MustGetFlagString(cmd.Flags(), options.PLUGIN_CONFIG),
+				options.MustGetFlagBool(cmd.Flags(), options.FORCE))
+		},
+	}
+	deleteCmd.Flags().String(options.PLUGIN_CONFIG, "", "The configuration file to use for a backup taken with a plugin")
+	deleteCmd.Flags().Bool(options.FORCE, false, "Delete the backup even if later incremental backups depend on it")
+	return deleteCmd
+}
+
+func setLoggerVerbosity(cmd *cobra.Command) {
+	if options.MustGetFlagBool(cmd.Flags(), options.QUIET) {
+		gplog.SetVerbosity(gplog.LOGERROR)
+	} else if options.MustGetFlagBool(cmd.Flags(), options.DEBUG) {
+		gplog.SetVerbosity(gplog.LOGDEBUG)
+	} else if options.MustGetFlagBool(cmd.Flags(), options.VERBOSE) {
+		gplog.SetVerbosity(gplog.LOGVERBOSE)
+	}
+}
+
+func getHistoryFilePath(cmd *cobra.Command) string {
+	historyFilePath := options.MustGetFlagString(cmd.Flags(), options.HISTORY_FILE)
+	if historyFilePath != "" {
+		return historyFilePath
+	}
+	coordinatorDataDir := operating.System.Getenv("COORDINATOR_DATA_DIRECTORY")
+	if coordinatorDataDir == "" {
+		coordinatorDataDir = operating.System.Getenv("MASTER_DATA_DIRECTORY")
+	}
+	if coordinatorDataDir == "" {
+		gplog.Fatal(errors.Errorf("COORDINATOR_DATA_DIRECTORY is not set; please set it or specify --%s", options.HISTORY_FILE), "")
+	}
+	return path.Join(coordinatorDataDir, "gpbackup_history.yaml")
+}
+
+func mustReadHistory(cmd *cobra.Command) *history.History {
+	historyFilePath := getHistoryFilePath(cmd)
+	backupHistory, _, err := history.NewHistory(historyFilePath)
+	if err != nil {
+		gplog.Fatal(errors.Errorf("Unable to read history file %s: %s", historyFilePath, err.Error()), "")
+	}
+	return backupHistory
+}
+
+/*
+ * Unlike History.FindBackupConfig, this also returns failed backups, since
+ * those can still be inspected and deleted.
+ */
+func mustFindBackupConfig(backupHistory *history.History, timestamp string) *history.BackupConfig {
+	if !filepath.IsValidTimestamp(timestamp) {
+		gplog.Fatal(errors.Errorf("Timestamp %s is invalid. Timestamps must be in the format YYYYMMDDHHMMSS.", timestamp), "")
+	}
+	for i := range backupHistory.BackupConfigs {
+		if backupHistory.BackupConfigs[i].Timestamp == timestamp {
+			return &backupHistory.BackupConfigs[i]
+		}
+	}
+	gplog.Fatal(errors.Errorf("Backup %s not found in history file", timestamp), "")
+	return nil
+}
+
+func GetBackupType(backupConfig *history.BackupConfig) string {
+	switch {
+	case backupConfig.MetadataOnly:
+		return "metadata-only"
+	case backupConfig.DataOnly:
+		return "data-only"
+	case backupConfig.Differential:
+		return "differential"
+	case backupConfig.Incremental:
+		return "incremental"
+	default:
+		return "full"
+	}
+}
+
+func GetObjectFilter(backupConfig *history.BackupConfig) string {
+	filters := make([]string, 0)
+	if backupConfig.IncludeSchemaFiltered {
+		filters = append(filters, "include-schema")
+	}
+	if backupConfig.ExcludeSchemaFiltered {
+		filters = append(filters, "exclude-schema")
+	}
+	if backupConfig.IncludeTableFiltered {
+		filters = append(filters, "include-table")
+	}
+	if backupConfig.ExcludeTableFiltered {
+		filters = append(filters, "exclude-table")
+	}
+	return strings.Join(filters, ",")
+}
+
+func WriteBackupList(writer io.Writer, backupConfigs []history.BackupConfig) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tabWriter, "TIMESTAMP\tSTATUS\tDATABASE\tTYPE\tOBJECT FILTERING\tPLUGIN\tDATE DELETED")
+	for i := range backupConfigs {
+		backupConfig := &backupConfigs[i]
+		status := backupConfig.Status
+		if status == "" {
+			// Backups taken by versions predating the status field
+			status = history.BackupStatusSucceed
+		}
+		_, _ = fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", backupConfig.Timestamp, status,
+			backupConfig.DatabaseName, GetBackupType(backupConfig), GetObjectFilter(backupConfig),
+			backupConfig.Plugin, backupConfig.DateDeleted)
+	}
+	return tabWriter.Flush()
+}
+
+/*
+ * The report, TOC and metadata files always live in the coordinator backup
+ * directory, so they can be located without connecting to the database.
+ */
+func GetReportFilePath(backupConfig *history.BackupConfig, coordinatorDataDir string) (string, error) {
+	fpInfo, err := getCoordinatorFPInfo(backupConfig, coordinatorDataDir)
+	if err != nil {
+		return "", err
+	}
+	return fpInfo.GetBackupReportFilePath(), nil
+}
+
+func getCoordinatorFPInfo(backupConfig *history.BackupConfig, coordinatorDataDir string) (filepath.FilePathInfo, error) {
+	segPrefix, err := filepath.ParseSegPrefix(backupConfig.BackupDir)
+	if err != nil {
+		return filepath.FilePathInfo{}, err
+	}
+	return filepath.FilePathInfo{
+		SegDirMap:              map[int]string{-1: coordinatorDataDir},
+		Timestamp:              backupConfig.Timestamp,
+		UserSpecifiedBackupDir: backupConfig.BackupDir,
+		UserSpecifiedSegPrefix: segPrefix,
+	}, nil
+}
+
+func DeleteBackup(historyFilePath string, timestamp string, pluginConfigFile string, force bool) {
+	backupHistory, _, err := history.NewHistory(historyFilePath)
+	gplog.FatalOnError(err)
+	backupConfig := mustFindBackupConfig(backupHistory, timestamp)
+	if backupConfig.Deleted() {
+		gplog.Fatal(errors.Errorf("Backup %s was already deleted on %s", timestamp, backupConfig.DateDeleted), "")
+	}
+	dependents := backupHistory.FindDependentBackups(timestamp)
+	if len(dependents) > 0 {
+		if !force {
+			gplog.Fatal(errors.Errorf("Backup %s is required to restore the following incremental backup(s): %s. Use --%s to delete it anyway.",
+				timestamp, strings.Join(dependents, ", "), options.FORCE), "")
+		}
+		gplog.Warn("Deleting backup %s; the following incremental backup(s) will no longer be restorable: %s", timestamp, strings.Join(dependents, ", "))
+	}
+	if backupConfig.Plugin != "" && pluginConfigFile == "" {
+		gplog.Fatal(errors.Errorf("Backup %s was taken with plugin %s; --%s is required to delete it", timestamp, backupConfig.Plugin, options.PLUGIN_CONFIG), "")
+	}
+
+	gplog.Info("Deleting backup %s", timestamp)
+	if pluginConfigFile != "" {
+		pluginConfig, err := utils.ReadPluginConfig(pluginConfigFile)
+		gplog.FatalOnError(err)
+		// delete_backup is only invoked on the coordinator, so there is no need to
+		// distribute the config to /tmp on every host as gpbackup does.
+		pluginConfig.ConfigPath, err = path.Abs(pluginConfigFile)
+		gplog.FatalOnError(err)
+		err = pluginConfig.DeleteBackup(timestamp)
+		gplog.FatalOnError(err)
+	}
+
+	conn := dbconn.NewDBConnFromEnvironment("postgres")
+	conn.MustConnect(1)
+	globalCluster := cluster.NewCluster(cluster.MustGetSegmentConfiguration(conn))
+	segPrefix := filepath.GetSegPrefix(conn)
+	conn.Close()
+	fpInfo := filepath.NewFilePathInfo(globalCluster, backupConfig.BackupDir, timestamp, segPrefix)
+	if !utils.DeleteBackupDirectoriesOnAllHosts(globalCluster, fpInfo) {
+		gplog.Fatal(errors.Errorf("Unable to remove all files for backup %s; the history file has not been updated", timestamp), "")
+	}
+
+	err = history.MarkBackupDeletedInHistory(historyFilePath, timestamp)
+	gplog.FatalOnError(err)
+	gplog.Info("Backup %s successfully deleted", timestamp)
+}
@@ -0,0 +1,104 @@
+package manager_test
+
+import (
+	"testing"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gp-common-go-libs/testhelper"
+	"github.com/cloudberrydb/gpbackup/history"
+	"github.com/cloudberrydb/gpbackup/manager"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+func TestManager(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Manager Suite")
+}
+
+var _ = BeforeSuite(func() {
+	_, _, _ = testhelper.SetupTestLogger()
+})
+
+var _ = Describe("manager tests", func() {
+	var fullConfig, incrementalConfig history.BackupConfig
+	BeforeEach(func() {
+		fullConfig = history.BackupConfig{
+			DatabaseName:          "testdb",
+			IncludeSchemaFiltered: true,
+			RestorePlan:           []history.RestorePlanEntry{},
+			Timestamp:             "20170101010101",
+			Status:                history.BackupStatusSucceed,
+		}
+		incrementalConfig = history.BackupConfig{
+			DatabaseName:         "testdb",
+			ExcludeTableFiltered: true,
+			Incremental:          true,
+			Plugin:               "/tmp/plugin.sh",
+			RestorePlan:          []history.RestorePlanEntry{{Timestamp: "20170101010101"}, {Timestamp: "20170102010101"}},
+			Timestamp:            "20170102010101",
+			Status:               history.BackupStatusFailed,
+			DateDeleted:          "20170103010101",
+		}
+	})
+	Describe("GetBackupType", func() {
+		It("identifies each type of backup", func() {
+			Expect(manager.GetBackupType(&fullConfig)).To(Equal("full"))
+			Expect(manager.GetBackupType(&incrementalConfig)).To(Equal("incremental"))
+			incrementalConfig.Differential = true
+			Expect(manager.GetBackupType(&incrementalConfig)).To(Equal("differential"))
+			fullConfig.MetadataOnly = true
+			Expect(manager.GetBackupType(&fullConfig)).To(Equal("metadata-only"))
+			fullConfig.MetadataOnly = false
+			fullConfig.DataOnly = true
+			Expect(manager.GetBackupType(&fullConfig)).To(Equal("data-only"))
+		})
+	})
+	Describe("GetObjectFilter", func() {
+		It("lists every filter used by the backup", func() {
+			fullConfig.IncludeTableFiltered = true
+			Expect(manager.GetObjectFilter(&fullConfig)).To(Equal("include-schema,include-table"))
+		})
+		It("returns an empty string for an unfiltered backup", func() {
+			Expect(manager.GetObjectFilter(&history.BackupConfig{})).To(Equal(""))
+		})
+	})
+	Describe("WriteBackupList", func() {
+		It("writes one aligned row per backup", func() {
+			buffer := NewBuffer()
+			err := manager.WriteBackupList(buffer, []history.BackupConfig{incrementalConfig, fullConfig})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(buffer).To(Say(`TIMESTAMP       STATUS   DATABASE  TYPE         OBJECT FILTERING  PLUGIN          DATE DELETED\n`))
+			Expect(buffer).To(Say(`20170102010101  Failure  testdb    incremental  exclude-table     /tmp/plugin.sh  20170103010101\n`))
+			Expect(buffer).To(Say(`20170101010101  Success  testdb    full         include-schema`))
+		})
+		It("reports backups without a status as successful", func() {
+			buffer := NewBuffer()
+			fullConfig.Status = ""
+			err := manager.WriteBackupList(buffer, []history.BackupConfig{fullConfig})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(buffer).To(Say("20170101010101  Success"))
+		})
+	})
+	Describe("GetReportFilePath", func() {
+		It("uses the coordinator data directory when no backup directory was specified", func() {
+			reportPath, err := manager.GetReportFilePath(&fullConfig, "/data/gpseg-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reportPath).To(Equal("/data/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_report"))
+		})
+		It("uses the backup directory and its segment prefix when one was specified", func() {
+			operating.System.Glob = func(pattern string) ([]string, error) {
+				return []string{"/backup_dir/gpseg-1/backups"}, nil
+			}
+			defer func() { operating.System = operating.InitializeSystemFunctions() }()
+			fullConfig.BackupDir = "/backup_dir"
+
+			reportPath, err := manager.GetReportFilePath(&fullConfig, "/data/gpseg-1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reportPath).To(Equal("/backup_dir/gpseg-1/backups/20170101/20170101010101/gpbackup_20170101010101_report"))
+		})
+	})
+})
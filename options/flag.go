@@ -50,11 +50,40 @@ const (
 	TRUNCATE_TABLE        = "truncate-table"
 	WITHOUT_GLOBALS       = "without-globals"
 	RESIZE_CLUSTER        = "resize-cluster"
+	HISTORY_FILE          = "history-file"
+	FORCE                 = "force"
+	RETAIN_FULL           = "retain-full"
+	RETAIN_DAYS           = "retain-days"
+	VERIFY                = "verify"
+	VERIFY_ONLY           = "verify-only"
+	ENCRYPTION_KEY_FILE   = "encryption-key-file"
+	RESUME                = "resume"
+	COMPRESSION_WORKERS   = "compression-workers"
+	FORMAT                = "format"
+	REPORT_JSON_FILE      = "report-json-file"
+	METRICS_FILE          = "metrics-file"
+	NOTIFICATION_CONFIG   = "notification-config"
+	HOOK_CONFIG           = "hook-config"
+	ROW_FILTER_FILE       = "row-filter-file"
+	MASKING_RULES_FILE    = "masking-rules-file"
+	SAMPLE_PERCENT        = "sample-percent"
+	SAMPLE_FOREIGN_KEYS   = "sample-follow-foreign-keys"
+	DIFFERENTIAL          = "differential"
+	INCREMENTAL_HEAP      = "incremental-heap"
+	REMAP_FILE            = "remap-file"
+	ROLE_MAP_FILE         = "role-map"
+	TABLESPACE_MAP_FILE   = "tablespace-map"
+	NO_OWNER              = "no-owner"
+	NO_PRIVILEGES         = "no-privileges"
+	LIST                  = "list"
+	USE_LIST              = "use-list"
+	ON_CONFLICT           = "on-conflict"
+	SWAP                  = "swap"
 )
 
 func SetBackupFlagDefaults(flagSet *pflag.FlagSet) {
 	flagSet.String(BACKUP_DIR, "", "The absolute path of the directory to which all backup files will be written")
-	flagSet.String(COMPRESSION_TYPE, "gzip", "Type of compression to use during data backup. Valid values are 'gzip', 'zstd'")
+	flagSet.String(COMPRESSION_TYPE, "gzip", "Type of compression to use during data backup. Valid values are 'gzip', 'zstd', 'lz4', 'none'")
 	flagSet.Int(COMPRESSION_LEVEL, 1, "Level of compression to use during data backup. Range of valid values depends on compression type")
 	flagSet.Bool(DATA_ONLY, false, "Only back up data, do not back up metadata")
 	flagSet.String(DBNAME, "", "The database to be backed up")
@@ -82,6 +111,22 @@ func SetBackupFlagDefaults(flagSet *pflag.FlagSet) {
 	flagSet.Bool(VERBOSE, false, "Print verbose log messages")
 	flagSet.Bool(WITH_STATS, false, "Back up query plan statistics")
 	flagSet.Bool(WITHOUT_GLOBALS, false, "Skip backup of global metadata")
+	flagSet.Int(RETAIN_FULL, 0, "After a successful backup, delete older backups of the database taken with the same table filters, row filters, masking rules and sample, except the specified number of most recent full backups and their incremental backups")
+	flagSet.Int(RETAIN_DAYS, 0, "After a successful backup, delete older backups of the database taken with the same table filters, row filters, masking rules and sample, except those taken within the specified number of days")
+	flagSet.Bool(VERIFY, false, "Record a SHA-256 checksum of every data file, so the backup can be checked with gprestore --verify-only")
+	flagSet.String(ENCRYPTION_KEY_FILE, "", "The absolute path of a file containing a 256-bit key, as 64 hexadecimal characters, with which to encrypt the backup when using the --single-data-file or --metadata-only option. The file must exist on every host")
+	flagSet.Int(COMPRESSION_WORKERS, 1, "Number of threads gpbackup_helper uses to compress each segment's data file when using the --single-data-file option")
+	flagSet.String(RESUME, "", "Resume the failed backup with the given timestamp, copying only the table data that was not completely backed up. The backup must be resumed with the same flags it was started with")
+	flagSet.String(REPORT_JSON_FILE, "", "The absolute path of a file to which to also write the JSON backup report")
+	flagSet.String(METRICS_FILE, "", "The absolute path of a file in which to record the metrics of the backup in the OpenMetrics format, for the node_exporter textfile collector")
+	flagSet.String(NOTIFICATION_CONFIG, "", "The absolute path of a YAML file listing the webhooks to notify and commands to run when the backup finishes")
+	flagSet.String(HOOK_CONFIG, "", "The absolute path of a YAML file listing the commands to run on the coordinator before and after each phase of the backup and when it fails")
+	flagSet.String(ROW_FILTER_FILE, "", "The absolute path of a YAML file mapping fully-qualified tables to the WHERE clause predicates with which to filter the rows of their data that are backed up")
+	flagSet.String(MASKING_RULES_FILE, "", "The absolute path of a YAML file listing the rules with which to mask the data of table columns as it is backed up, for non-production copies of the database")
+	flagSet.Float64(SAMPLE_PERCENT, 0, "Back up only the given percentage of the rows of each table, for developer sandboxes")
+	flagSet.Bool(SAMPLE_FOREIGN_KEYS, false, "When sampling with --sample-percent, back up the rows of a table referencing another table with a foreign key only if the referenced row is also backed up")
+	flagSet.Bool(DIFFERENTIAL, false, "With --incremental, base the backup off the last full backup instead of the last backup, so it can be restored from the full backup and itself alone")
+	flagSet.Bool(INCREMENTAL_HEAP, false, "With --incremental, also skip the heap tables whose tuple statistics are unchanged since the last backup. Changes that the statistics collector does not count are not backed up")
 }
 
 func SetRestoreFlagDefaults(flagSet *pflag.FlagSet) {
@@ -116,6 +161,23 @@ func SetRestoreFlagDefaults(flagSet *pflag.FlagSet) {
 	flagSet.Bool(LEAF_PARTITION_DATA, false, "For partition tables, create one data file per leaf partition instead of one data file for the whole table")
 	flagSet.Bool(RUN_ANALYZE, false, "Run ANALYZE on restored tables")
 	flagSet.Bool(RESIZE_CLUSTER, false, "Restore a backup taken on a cluster with more or fewer segments than the cluster to which it will be restored")
+	flagSet.Bool(VERIFY_ONLY, false, "Check every data file in the backup against its recorded checksum without restoring anything")
+	flagSet.String(ENCRYPTION_KEY_FILE, "", "The absolute path of the file containing the key with which the backup was encrypted. The file must exist on every host")
+	flagSet.Int(COMPRESSION_WORKERS, 1, "Number of threads gpbackup_helper uses to decompress each segment's data file when restoring a backup taken using the --single-data-file option")
+	flagSet.String(RESUME, "", "Resume the failed restore with the given restore timestamp, skipping the metadata and table data that were already restored")
+	flagSet.String(REPORT_JSON_FILE, "", "The absolute path of a file to which to also write the JSON restore report")
+	flagSet.String(METRICS_FILE, "", "The absolute path of a file in which to record the metrics of the restore in the OpenMetrics format, for the node_exporter textfile collector")
+	flagSet.String(NOTIFICATION_CONFIG, "", "The absolute path of a YAML file listing the webhooks to notify and commands to run when the restore finishes")
+	flagSet.String(HOOK_CONFIG, "", "The absolute path of a YAML file listing the commands to run on the coordinator before and after each phase of the restore and when it fails")
+	flagSet.String(REMAP_FILE, "", "The absolute path of a YAML file mapping schemas and fully-qualified tables in the backup to the schemas and tables to restore them to")
+	flagSet.String(ROLE_MAP_FILE, "", "The absolute path of a YAML file mapping roles in the backup to existing roles to use in their place when restoring metadata")
+	flagSet.String(TABLESPACE_MAP_FILE, "", "The absolute path of a YAML file mapping tablespaces in the backup to existing tablespaces to use in their place when restoring metadata")
+	flagSet.Bool(NO_OWNER, false, "Do not restore the ownership of objects")
+	flagSet.Bool(NO_PRIVILEGES, false, "Do not restore the privileges granted on objects")
+	flagSet.Bool(LIST, false, "Print a numbered listing of the entries in the backup without restoring anything")
+	flagSet.String(USE_LIST, "", "The absolute path of a listing printed by --list, restoring only the entries that are not commented out")
+	flagSet.String(ON_CONFLICT, "", "Merge the data of each table into its existing rows, matching rows on the primary key and either updating them or skipping them. Valid values are 'update' and 'skip'")
+	flagSet.Bool(SWAP, false, "Restore each included table under another name and then swap it with the live table in one transaction, so that readers never see the table empty or partially restored")
 	_ = flagSet.MarkHidden(LEAF_PARTITION_DATA)
 }
 
@@ -166,6 +228,12 @@ func MustGetFlagInt(cmdFlags *pflag.FlagSet, flagName string) int {
 	return value
 }
 
+func MustGetFlagFloat64(cmdFlags *pflag.FlagSet, flagName string) float64 {
+	value, err := cmdFlags.GetFloat64(flagName)
+	gplog.FatalOnError(err)
+	return value
+}
+
 func MustGetFlagBool(cmdFlags *pflag.FlagSet, flagName string) bool {
 	value, err := cmdFlags.GetBool(flagName)
 	gplog.FatalOnError(err)
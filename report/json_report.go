@@ -0,0 +1,243 @@
+package report
+
+/*
+ * This file contains the JSON companion of the text report, which holds the
+ * same information in a form that monitoring tools can parse, along with the
+ * timing of each section and table.
+ */
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/iohelper"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+)
+
+type SectionTiming struct {
+	Section         string  `json:"section"`
+	StartTime       string  `json:"start_time"`
+	EndTime         string  `json:"end_time"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+/*
+ * EstimatedBytes is the size of the table in the database when it was backed
+ * up, as the size of the data written to or read from the segments is not
+ * known to the coordinator.
+ */
+type TableTiming struct {
+	Oid             uint32       `json:"oid"`
+	Schema          string       `json:"schema"`
+	Name            string       `json:"name"`
+	RowsCopied      int64        `json:"rows_copied"`
+	EstimatedBytes  int64        `json:"estimated_bytes"`
+	DurationSeconds float64      `json:"duration_seconds"`
+	Merge           *MergeCounts `json:"merge,omitempty"`
+}
+
+/*
+ * MergeCounts records what became of the rows copied into a table restored
+ * with --on-conflict, which are merged into the existing rows of the table.
+ */
+type MergeCounts struct {
+	RowsInserted int64 `json:"rows_inserted"`
+	RowsUpdated  int64 `json:"rows_updated"`
+	RowsSkipped  int64 `json:"rows_skipped"`
+}
+
+/*
+ * Timings records how long each section of a backup or restore took and how
+ * long the data of each table took to copy.  Tables are recorded by parallel
+ * workers, so all access is synchronized.  The methods of a nil Timings do
+ * nothing, so that code run outside of gpbackup or gprestore need not check.
+ */
+type Timings struct {
+	mutex    sync.Mutex
+	sections []SectionTiming
+	tables   []TableTiming
+}
+
+func NewTimings() *Timings {
+	return &Timings{sections: make([]SectionTiming, 0), tables: make([]TableTiming, 0)}
+}
+
+/*
+ * Returns a function to be called when the section is done, so that a
+ * section can be timed with a single deferred call.
+ */
+func (timings *Timings) StartSection(section string) func() {
+	if timings == nil {
+		return func() {}
+	}
+	start := operating.System.Now()
+	return func() {
+		end := operating.System.Now()
+		timings.mutex.Lock()
+		defer timings.mutex.Unlock()
+		timings.sections = append(timings.sections, SectionTiming{
+			Section:         section,
+			StartTime:       start.Format(time.RFC3339),
+			EndTime:         end.Format(time.RFC3339),
+			DurationSeconds: end.Sub(start).Seconds(),
+		})
+	}
+}
+
+func (timings *Timings) AddTable(oid uint32, schema string, name string, rowsCopied int64, start time.Time) {
+	if timings == nil {
+		return
+	}
+	duration := operating.System.Now().Sub(start).Seconds()
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+	timings.tables = append(timings.tables, TableTiming{
+		Oid:             oid,
+		Schema:          schema,
+		Name:            name,
+		RowsCopied:      rowsCopied,
+		DurationSeconds: duration,
+	})
+}
+
+func (timings *Timings) SetMergeCounts(oid uint32, counts MergeCounts) {
+	if timings == nil {
+		return
+	}
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+	for i := range timings.tables {
+		if timings.tables[i].Oid == oid {
+			timings.tables[i].Merge = &counts
+		}
+	}
+}
+
+func (timings *Timings) SetEstimatedBytes(tableSizes map[uint32]int64) {
+	if timings == nil {
+		return
+	}
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+	for i := range timings.tables {
+		timings.tables[i].EstimatedBytes = tableSizes[timings.tables[i].Oid]
+	}
+}
+
+type JSONReport struct {
+	Utility              string            `json:"utility"`
+	Report               map[string]string `json:"report"`
+	IncrementalBackupSet []string          `json:"incremental_backup_set,omitempty"`
+	ObjectCounts         map[string]int    `json:"object_counts,omitempty"`
+	Sections             []SectionTiming   `json:"sections"`
+	Tables               []TableTiming     `json:"tables"`
+	ErrorTablesMetadata  []string          `json:"error_tables_metadata"`
+	ErrorTablesData      []string          `json:"error_tables_data"`
+	ExitStatus           string            `json:"exit_status"`
+	ExitCode             int               `json:"exit_code"`
+}
+
+/*
+ * Report keys are derived from the keys of the text report, so that
+ * "timestamp key:" becomes "timestamp_key".  Lines without a key of their own,
+ * such as the timestamps of an incremental backup set, are reported separately.
+ */
+func newJSONReport(utility string, reportInfo []LineInfo, timings *Timings) *JSONReport {
+	jsonReport := &JSONReport{
+		Utility:             utility,
+		Report:              make(map[string]string),
+		Sections:            make([]SectionTiming, 0),
+		Tables:              make([]TableTiming, 0),
+		ErrorTablesMetadata: make([]string, 0),
+		ErrorTablesData:     make([]string, 0),
+		ExitStatus:          getExitStatus(),
+		ExitCode:            gplog.GetErrorCode(),
+	}
+	for _, lineInfo := range reportInfo {
+		if !strings.HasSuffix(lineInfo.Key, ":") {
+			continue
+		}
+		key := strings.ReplaceAll(strings.TrimSuffix(lineInfo.Key, ":"), " ", "_")
+		jsonReport.Report[key] = strings.TrimSpace(lineInfo.Value)
+	}
+	if timings != nil {
+		timings.mutex.Lock()
+		defer timings.mutex.Unlock()
+		jsonReport.Sections = append(jsonReport.Sections, timings.sections...)
+		jsonReport.Tables = append(jsonReport.Tables, timings.tables...)
+		sort.Slice(jsonReport.Tables, func(i, j int) bool {
+			if jsonReport.Tables[i].Schema != jsonReport.Tables[j].Schema {
+				return jsonReport.Tables[i].Schema < jsonReport.Tables[j].Schema
+			}
+			return jsonReport.Tables[i].Name < jsonReport.Tables[j].Name
+		})
+	}
+	return jsonReport
+}
+
+func (report *Report) NewBackupJSONReport(timestamp string, endtime time.Time, objectCounts map[string]int, errMsg string, timings *Timings) *JSONReport {
+	jsonReport := newJSONReport("gpbackup", report.constructBackupReportInfo(timestamp, endtime, errMsg), timings)
+	if report.Incremental {
+		for _, restorePlanEntry := range report.RestorePlan {
+			jsonReport.IncrementalBackupSet = append(jsonReport.IncrementalBackupSet, restorePlanEntry.Timestamp)
+		}
+	}
+	jsonReport.ObjectCounts = make(map[string]int, len(objectCounts))
+	for object, count := range objectCounts {
+		jsonReport.ObjectCounts[getObjectCountKey(object)] = count
+	}
+	return jsonReport
+}
+
+func NewRestoreJSONReport(backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, origSize int, destSize int, errMsg string,
+	partialTables []string, timings *Timings, errorTablesMetadata []string, errorTablesData []string) *JSONReport {
+	reportInfo := constructRestoreReportInfo(backupTimestamp, startTimestamp, connectionPool, restoreVersion, origSize, destSize, errMsg, partialTables)
+	jsonReport := newJSONReport("gprestore", reportInfo, timings)
+	jsonReport.ErrorTablesMetadata = append(jsonReport.ErrorTablesMetadata, errorTablesMetadata...)
+	jsonReport.ErrorTablesData = append(jsonReport.ErrorTablesData, errorTablesData...)
+	sort.Strings(jsonReport.ErrorTablesMetadata)
+	sort.Strings(jsonReport.ErrorTablesData)
+	return jsonReport
+}
+
+func (jsonReport *JSONReport) WriteToFile(filename string) error {
+	contents, err := json.MarshalIndent(jsonReport, "", "  ")
+	if err != nil {
+		return err
+	}
+	reportFile, err := iohelper.OpenFileForWriting(filename)
+	if err != nil {
+		return err
+	}
+	_, err = reportFile.Write(append(contents, '\n'))
+	if err != nil {
+		_ = reportFile.Close()
+		return err
+	}
+	return reportFile.Close()
+}
+
+/*
+ * The report written next to the text report is made read-only like the text
+ * report, while the user-specified copy is left writable so that the same path
+ * can be used by every run.
+ */
+func WriteJSONReportFiles(jsonReport *JSONReport, reportFilename string, userFilename string) {
+	err := jsonReport.WriteToFile(reportFilename)
+	if err != nil {
+		gplog.Error("Unable to write JSON report file %s: %v", reportFilename, err)
+	} else {
+		_ = operating.System.Chmod(reportFilename, 0444)
+	}
+	if userFilename != "" {
+		err = jsonReport.WriteToFile(userFilename)
+		if err != nil {
+			gplog.Error("Unable to write JSON report file %s: %v", userFilename, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package report_test
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/history"
+	"github.com/cloudberrydb/gpbackup/report"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("report/json_report tests", func() {
+	var now time.Time
+	BeforeEach(func() {
+		now = time.Date(2017, 1, 1, 1, 1, 1, 0, time.UTC)
+		operating.System.Now = func() time.Time {
+			now = now.Add(2 * time.Second)
+			return now
+		}
+		operating.System.Chmod = func(name string, mode os.FileMode) error {
+			return nil
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		gplog.SetErrorCode(0)
+	})
+	Describe("Timings", func() {
+		It("records the start, end and duration of a section", func() {
+			timings := report.NewTimings()
+			timings.StartSection("predata")()
+
+			jsonReport := (&report.Report{}).NewBackupJSONReport("20170101010101", now, nil, "", timings)
+			Expect(jsonReport.Sections).To(Equal([]report.SectionTiming{{Section: "predata",
+				StartTime: "2017-01-01T01:01:03Z", EndTime: "2017-01-01T01:01:05Z", DurationSeconds: 2}}))
+		})
+		It("records the rows, size and duration of tables sorted by name", func() {
+			timings := report.NewTimings()
+			timings.AddTable(2, "public", "foo", 10, now)
+			timings.AddTable(1, "public", "bar", 20, now)
+			timings.SetEstimatedBytes(map[uint32]int64{1: 8192, 2: 16384})
+
+			jsonReport := (&report.Report{}).NewBackupJSONReport("20170101010101", now, nil, "", timings)
+			Expect(jsonReport.Tables).To(Equal([]report.TableTiming{
+				{Oid: 1, Schema: "public", Name: "bar", RowsCopied: 20, EstimatedBytes: 8192, DurationSeconds: 2},
+				{Oid: 2, Schema: "public", Name: "foo", RowsCopied: 10, EstimatedBytes: 16384, DurationSeconds: 2},
+			}))
+		})
+		It("records the merge counts of tables", func() {
+			timings := report.NewTimings()
+			timings.AddTable(1, "public", "foo", 10, now)
+			timings.AddTable(2, "public", "bar", 20, now)
+			timings.SetMergeCounts(1, report.MergeCounts{RowsInserted: 2, RowsUpdated: 3, RowsSkipped: 5})
+
+			jsonReport := (&report.Report{}).NewBackupJSONReport("20170101010101", now, nil, "", timings)
+			Expect(jsonReport.Tables).To(Equal([]report.TableTiming{
+				{Oid: 2, Schema: "public", Name: "bar", RowsCopied: 20, DurationSeconds: 2},
+				{Oid: 1, Schema: "public", Name: "foo", RowsCopied: 10, DurationSeconds: 2, Merge: &report.MergeCounts{RowsInserted: 2, RowsUpdated: 3, RowsSkipped: 5}},
+			}))
+		})
+		It("does nothing when nil", func() {
+			var timings *report.Timings
+			timings.StartSection("predata")()
+			timings.AddTable(1, "public", "foo", 10, now)
+			timings.SetMergeCounts(1, report.MergeCounts{RowsInserted: 10})
+			timings.SetEstimatedBytes(map[uint32]int64{1: 8192})
+
+			jsonReport := (&report.Report{}).NewBackupJSONReport("20170101010101", now, nil, "", timings)
+			Expect(jsonReport.Sections).To(BeEmpty())
+			Expect(jsonReport.Tables).To(BeEmpty())
+		})
+	})
+	Describe("NewBackupJSONReport", func() {
+		backupReport := &report.Report{}
+		BeforeEach(func() {
+			backupReport = &report.Report{
+				BackupParamsString: `compression: gzip
+backup section: All Sections
+incremental: True
+incremental backup set:
+20170101010101`,
+				DatabaseSize: "42 MB",
+				BackupConfig: history.BackupConfig{
+					BackupVersion:   "0.1.0",
+					DatabaseName:    "testdb",
+					DatabaseVersion: "5.0.0 build test",
+					SegmentCount:    3,
+					Incremental:     true,
+					RestorePlan:     []history.RestorePlanEntry{{Timestamp: "20170101010101"}},
+				},
+			}
+		})
+		It("contains every field of the text report for a successful backup", func() {
+			endtime := time.Date(2017, 1, 1, 5, 4, 3, 2, time.Local)
+			objectCounts := map[string]int{"Tables": 42, "Database GUC's": 1}
+			jsonReport := backupReport.NewBackupJSONReport("20170101010101", endtime, objectCounts, "", report.NewTimings())
+
+			Expect(jsonReport.Utility).To(Equal("gpbackup"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("timestamp_key", "20170101010101"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("gpbackup_version", "0.1.0"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("database_name", "testdb"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("compression", "gzip"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("incremental", "True"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("duration", "4:03:02"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("backup_status", history.BackupStatusSucceed))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("database_size", "42 MB"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("segment_count", "3"))
+			Expect(jsonReport.Report).ToNot(HaveKey("20170101010101"))
+			Expect(jsonReport.IncrementalBackupSet).To(Equal([]string{"20170101010101"}))
+			Expect(jsonReport.ObjectCounts).To(Equal(map[string]int{"tables": 42, "database GUC's": 1}))
+			Expect(jsonReport.ExitStatus).To(Equal("success"))
+			Expect(jsonReport.ExitCode).To(Equal(0))
+		})
+		It("contains the error of a failed backup", func() {
+			gplog.SetErrorCode(2)
+			jsonReport := backupReport.NewBackupJSONReport("20170101010101", now, nil, "Cannot access /tmp/backups: Permission denied", nil)
+
+			Expect(jsonReport.Report).To(HaveKeyWithValue("backup_status", history.BackupStatusFailed))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("backup_error", "Cannot access /tmp/backups: Permission denied"))
+			Expect(jsonReport.ExitStatus).To(Equal("failure"))
+			Expect(jsonReport.ExitCode).To(Equal(2))
+		})
+	})
+	Describe("NewRestoreJSONReport", func() {
+		connectionPool := &dbconn.DBConn{
+			DBName: "testdb",
+			Version: dbconn.GPDBVersion{
+				VersionString: "5.0.0 build test",
+			},
+		}
+		It("contains the error tables of a restore with errors", func() {
+			gplog.SetErrorCode(1)
+			jsonReport := report.NewRestoreJSONReport("20170101010101", "20170101010102", connectionPool, "0.1.0", 3, 4, "",
+				[]string{"public.baz"}, report.NewTimings(), []string{"public.foo"}, []string{"public.qux", "public.bar"})
+
+			Expect(jsonReport.Utility).To(Equal("gprestore"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("gprestore_version", "0.1.0"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("backup_segment_count", "3"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("restore_segment_count", "4"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("partial_tables", "public.baz"))
+			Expect(jsonReport.Report).To(HaveKeyWithValue("restore_status", ContainSubstring("Success but non-fatal errors occurred")))
+			Expect(jsonReport.ErrorTablesMetadata).To(Equal([]string{"public.foo"}))
+			Expect(jsonReport.ErrorTablesData).To(Equal([]string{"public.bar", "public.qux"}))
+			Expect(jsonReport.ExitStatus).To(Equal("success_with_errors"))
+			Expect(jsonReport.ObjectCounts).To(BeNil())
+		})
+	})
+	Describe("WriteJSONReportFiles", func() {
+		var openedFiles, chmodFiles []string
+		var reportBuffer *Buffer
+		BeforeEach(func() {
+			openedFiles, chmodFiles = []string{}, []string{}
+			reportBuffer = NewBuffer()
+			operating.System.OpenFileWrite = func(name string, flag int, perm os.FileMode) (io.WriteCloser, error) {
+				openedFiles = append(openedFiles, name)
+				return reportBuffer, nil
+			}
+			operating.System.Chmod = func(name string, mode os.FileMode) error {
+				chmodFiles = append(chmodFiles, name)
+				return nil
+			}
+		})
+		It("writes the report next to the text report and makes it read-only", func() {
+			jsonReport := (&report.Report{}).NewBackupJSONReport("20170101010101", now, map[string]int{"Tables": 1}, "", nil)
+			report.WriteJSONReportFiles(jsonReport, "/tmp/gpbackup_20170101010101_report.json", "")
+
+			Expect(openedFiles).To(Equal([]string{"/tmp/gpbackup_20170101010101_report.json"}))
+			Expect(chmodFiles).To(Equal([]string{"/tmp/gpbackup_20170101010101_report.json"}))
+			written := &report.JSONReport{}
+			Expect(json.Unmarshal(reportBuffer.Contents(), written)).To(Succeed())
+			Expect(written).To(Equal(jsonReport))
+		})
+		It("also writes the report to a user-specified file", func() {
+			jsonReport := (&report.Report{}).NewBackupJSONReport("20170101010101", now, nil, "", nil)
+			report.WriteJSONReportFiles(jsonReport, "/tmp/gpbackup_20170101010101_report.json", "/tmp/latest_backup.json")
+
+			Expect(openedFiles).To(Equal([]string{"/tmp/gpbackup_20170101010101_report.json", "/tmp/latest_backup.json"}))
+			Expect(chmodFiles).To(Equal([]string{"/tmp/gpbackup_20170101010101_report.json"}))
+		})
+	})
+})
@@ -0,0 +1,174 @@
+package report
+
+/*
+ * This file contains functions for writing the metrics of a backup or restore
+ * to a file in the OpenMetrics text format, to be read by the textfile
+ * collector of the Prometheus node_exporter.
+ */
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+type metricFamily struct {
+	name string
+	help string
+}
+
+func getMetricFamilies(utility string) []metricFamily {
+	return []metricFamily{
+		{utility + "_success", fmt.Sprintf("Whether the last %s of the database succeeded", utility)},
+		{utility + "_last_run_timestamp_seconds", fmt.Sprintf("When the last %s of the database finished", utility)},
+		{utility + "_last_success_timestamp_seconds", fmt.Sprintf("When the last successful %s of the database finished", utility)},
+		{utility + "_duration_seconds", fmt.Sprintf("Duration of the last %s of the database", utility)},
+		{utility + "_phase_duration_seconds", fmt.Sprintf("Duration of each phase of the last %s of the database", utility)},
+		{utility + "_rows", fmt.Sprintf("Rows of table data copied by the last %s of the database", utility)},
+		{utility + "_estimated_bytes", fmt.Sprintf("Estimated size of the table data copied by the last %s of the database", utility)},
+		{utility + "_tables", fmt.Sprintf("Tables whose data was copied by the last %s of the database", utility)},
+		{utility + "_errors", fmt.Sprintf("Tables that failed in the last %s of the database, or 1 if it failed outright", utility)},
+		{utility + "_objects", fmt.Sprintf("Database objects in the last %s of the database by type", utility)},
+	}
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func getMetricFamilyName(sample string) string {
+	end := strings.IndexAny(sample, "{ ")
+	if end == -1 {
+		return sample
+	}
+	return sample[:end]
+}
+
+/*
+ * Reads the samples in an existing metrics file, so that the metrics of other
+ * databases are kept and the last success of this database is remembered when
+ * this run fails.
+ */
+func readMetricSamples(filename string) (map[string][]string, error) {
+	samples := make(map[string][]string)
+	contents, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return samples, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		family := getMetricFamilyName(line)
+		samples[family] = append(samples[family], line)
+	}
+	return samples, nil
+}
+
+func getRunMetricSamples(utility string, databaseLabel string, startTime time.Time, endTime time.Time, jsonReport *JSONReport) map[string][]string {
+	samples := make(map[string][]string)
+	addSample := func(family string, labels string, value interface{}) {
+		name := utility + "_" + family
+		samples[name] = append(samples[name], fmt.Sprintf("%s{%s%s} %v", name, databaseLabel, labels, value))
+	}
+
+	success := 0
+	if jsonReport.ExitCode != 2 {
+		success = 1
+		addSample("last_success_timestamp_seconds", "", endTime.Unix())
+	}
+	addSample("success", "", success)
+	addSample("last_run_timestamp_seconds", "", endTime.Unix())
+	addSample("duration_seconds", "", endTime.Sub(startTime).Seconds())
+	for _, section := range jsonReport.Sections {
+		addSample("phase_duration_seconds", fmt.Sprintf(`,phase="%s"`, section.Section), section.DurationSeconds)
+	}
+
+	var rows, estimatedBytes int64
+	for _, table := range jsonReport.Tables {
+		rows += table.RowsCopied
+		estimatedBytes += table.EstimatedBytes
+	}
+	addSample("rows", "", rows)
+	addSample("estimated_bytes", "", estimatedBytes)
+	addSample("tables", "", len(jsonReport.Tables))
+
+	errorTables := make(map[string]bool)
+	for _, tableName := range jsonReport.ErrorTablesMetadata {
+		errorTables[tableName] = true
+	}
+	for _, tableName := range jsonReport.ErrorTablesData {
+		errorTables[tableName] = true
+	}
+	numErrors := len(errorTables)
+	if numErrors == 0 && success == 0 {
+		numErrors = 1
+	}
+	addSample("errors", "", numErrors)
+
+	objectTypes := make([]string, 0, len(jsonReport.ObjectCounts))
+	for objectType := range jsonReport.ObjectCounts {
+		objectTypes = append(objectTypes, objectType)
+	}
+	sort.Strings(objectTypes)
+	for _, objectType := range objectTypes {
+		addSample("objects", fmt.Sprintf(`,type="%s"`, escapeLabelValue(objectType)), jsonReport.ObjectCounts[objectType])
+	}
+	return samples
+}
+
+/*
+ * The file is written to a temporary file that is then renamed, so that the
+ * collector never reads a partially written file.
+ */
+func WriteMetricsFile(filename string, database string, startTimestamp string, endTime time.Time, jsonReport *JSONReport) error {
+	utility := jsonReport.Utility
+	startTime, _ := time.ParseInLocation("20060102150405", startTimestamp, operating.System.Local)
+	databaseLabel := fmt.Sprintf(`database="%s"`, escapeLabelValue(database))
+
+	previousSamples, err := readMetricSamples(filename)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read metrics file %s", filename)
+	}
+	runSamples := getRunMetricSamples(utility, databaseLabel, startTime, endTime, jsonReport)
+
+	var contents strings.Builder
+	for _, family := range getMetricFamilies(utility) {
+		familySamples := make([]string, 0)
+		for _, sample := range previousSamples[family.name] {
+			isThisDatabase := strings.Contains(sample, "{"+databaseLabel+",") || strings.Contains(sample, "{"+databaseLabel+"}")
+			if !isThisDatabase {
+				familySamples = append(familySamples, sample)
+			} else if family.name == utility+"_last_success_timestamp_seconds" && len(runSamples[family.name]) == 0 {
+				// Remember the last success of this database when this run failed
+				familySamples = append(familySamples, sample)
+			}
+		}
+		familySamples = append(familySamples, runSamples[family.name]...)
+		if len(familySamples) == 0 {
+			continue
+		}
+		contents.WriteString(fmt.Sprintf("# HELP %s %s.\n# TYPE %s gauge\n", family.name, family.help, family.name))
+		for _, sample := range familySamples {
+			contents.WriteString(sample + "\n")
+		}
+	}
+	contents.WriteString("# EOF\n")
+
+	tempFilename := filename + ".tmp"
+	err = os.WriteFile(tempFilename, []byte(contents.String()), 0644)
+	if err == nil {
+		err = os.Rename(tempFilename, filename)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write metrics file %s", filename)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package report_test
+
+import (
+	"os"
+	"path"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/report"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("report/metrics tests", func() {
+	var metricsFilename string
+	var jsonReport *report.JSONReport
+	endTime := time.Date(2017, 1, 1, 1, 2, 1, 0, time.UTC)
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		operating.System.Local = time.UTC
+		metricsFilename = path.Join(GinkgoT().TempDir(), "gpbackup.prom")
+		jsonReport = &report.JSONReport{
+			Utility:      "gpbackup",
+			ObjectCounts: map[string]int{"tables": 2, "database GUC's": 1},
+			Sections: []report.SectionTiming{
+				{Section: "predata", DurationSeconds: 1.5},
+				{Section: "data", DurationSeconds: 50},
+			},
+			Tables: []report.TableTiming{
+				{Oid: 1, Schema: "public", Name: "foo", RowsCopied: 10, EstimatedBytes: 8192},
+				{Oid: 2, Schema: "public", Name: "bar", RowsCopied: 20, EstimatedBytes: 16384},
+			},
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		gplog.SetErrorCode(0)
+	})
+	readMetricsFile := func() string {
+		contents, err := os.ReadFile(metricsFilename)
+		Expect(err).ToNot(HaveOccurred())
+		return string(contents)
+	}
+
+	It("writes the metrics of a successful run", func() {
+		err := report.WriteMetricsFile(metricsFilename, "testdb", "20170101010101", endTime, jsonReport)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readMetricsFile()).To(Equal(`# HELP gpbackup_success Whether the last gpbackup of the database succeeded.
+# TYPE gpbackup_success gauge
+gpbackup_success{database="testdb"} 1
+# HELP gpbackup_last_run_timestamp_seconds When the last gpbackup of the database finished.
+# TYPE gpbackup_last_run_timestamp_seconds gauge
+gpbackup_last_run_timestamp_seconds{database="testdb"} 1483232521
+# HELP gpbackup_last_success_timestamp_seconds When the last successful gpbackup of the database finished.
+# TYPE gpbackup_last_success_timestamp_seconds gauge
+gpbackup_last_success_timestamp_seconds{database="testdb"} 1483232521
+# HELP gpbackup_duration_seconds Duration of the last gpbackup of the database.
+# TYPE gpbackup_duration_seconds gauge
+gpbackup_duration_seconds{database="testdb"} 60
+# HELP gpbackup_phase_duration_seconds Duration of each phase of the last gpbackup of the database.
+# TYPE gpbackup_phase_duration_seconds gauge
+gpbackup_phase_duration_seconds{database="testdb",phase="predata"} 1.5
+gpbackup_phase_duration_seconds{database="testdb",phase="data"} 50
+# HELP gpbackup_rows Rows of table data copied by the last gpbackup of the database.
+# TYPE gpbackup_rows gauge
+gpbackup_rows{database="testdb"} 30
+# HELP gpbackup_estimated_bytes Estimated size of the table data copied by the last gpbackup of the database.
+# TYPE gpbackup_estimated_bytes gauge
+gpbackup_estimated_bytes{database="testdb"} 24576
+# HELP gpbackup_tables Tables whose data was copied by the last gpbackup of the database.
+# TYPE gpbackup_tables gauge
+gpbackup_tables{database="testdb"} 2
+# HELP gpbackup_errors Tables that failed in the last gpbackup of the database, or 1 if it failed outright.
+# TYPE gpbackup_errors gauge
+gpbackup_errors{database="testdb"} 0
+# HELP gpbackup_objects Database objects in the last gpbackup of the database by type.
+# TYPE gpbackup_objects gauge
+gpbackup_objects{database="testdb",type="database GUC's"} 1
+gpbackup_objects{database="testdb",type="tables"} 2
+# EOF
+`))
+	})
+	It("keeps the last success of the database when a run fails", func() {
+		err := report.WriteMetricsFile(metricsFilename, "testdb", "20170101010101", endTime, jsonReport)
+		Expect(err).ToNot(HaveOccurred())
+		jsonReport.ExitCode = 2
+		err = report.WriteMetricsFile(metricsFilename, "testdb", "20170102010101", endTime.Add(24*time.Hour), jsonReport)
+		Expect(err).ToNot(HaveOccurred())
+
+		contents := readMetricsFile()
+		Expect(contents).To(ContainSubstring("gpbackup_success{database=\"testdb\"} 0\n"))
+		Expect(contents).To(ContainSubstring("gpbackup_last_run_timestamp_seconds{database=\"testdb\"} 1483318921\n"))
+		Expect(contents).To(ContainSubstring("gpbackup_last_success_timestamp_seconds{database=\"testdb\"} 1483232521\n"))
+		Expect(contents).To(ContainSubstring("gpbackup_errors{database=\"testdb\"} 1\n"))
+	})
+	It("keeps the metrics of other databases", func() {
+		err := report.WriteMetricsFile(metricsFilename, "otherdb", "20170101010101", endTime, jsonReport)
+		Expect(err).ToNot(HaveOccurred())
+		jsonReport.Sections = jsonReport.Sections[:1]
+		err = report.WriteMetricsFile(metricsFilename, "testdb", "20170101010101", endTime, jsonReport)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readMetricsFile()).To(ContainSubstring(`# TYPE gpbackup_phase_duration_seconds gauge
+gpbackup_phase_duration_seconds{database="otherdb",phase="predata"} 1.5
+gpbackup_phase_duration_seconds{database="otherdb",phase="data"} 50
+gpbackup_phase_duration_seconds{database="testdb",phase="predata"} 1.5
+# HELP gpbackup_rows`))
+	})
+	It("escapes the database name", func() {
+		err := report.WriteMetricsFile(metricsFilename, `test"db`, "20170101010101", endTime, jsonReport)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readMetricsFile()).To(ContainSubstring(`gpbackup_success{database="test\"db"} 1`))
+	})
+	It("counts the error tables of a restore", func() {
+		jsonReport.Utility = "gprestore"
+		jsonReport.ErrorTablesMetadata = []string{"public.foo"}
+		jsonReport.ErrorTablesData = []string{"public.foo", "public.bar"}
+		err := report.WriteMetricsFile(metricsFilename, "testdb", "20170101010101", endTime, jsonReport)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(readMetricsFile()).To(ContainSubstring("gprestore_errors{database=\"testdb\"} 2\n"))
+	})
+})
@@ -0,0 +1,196 @@
+package report
+
+/*
+ * This file contains functions for notifying webhooks and running command
+ * hooks when a backup or restore finishes, configured in a YAML file.
+ */
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultNotificationTimeout       = 30 * time.Second
+	defaultNotificationRetryInterval = 5 * time.Second
+)
+
+/*
+ * Each notification is either a webhook, to which the payload is POSTed, or a
+ * command, which is run with the payload on its standard input.  As with the
+ * email contacts file, a notification is only sent for the exit statuses set
+ * to true in its Status map.
+ */
+type Notification struct {
+	Webhook       string            `yaml:"webhook"`
+	Headers       map[string]string `yaml:"headers"`
+	Command       string            `yaml:"command"`
+	Status        map[string]bool   `yaml:"status"`
+	Retries       int               `yaml:"retries"`
+	RetryInterval time.Duration     `yaml:"retry_interval"`
+	Timeout       time.Duration     `yaml:"timeout"`
+}
+
+type NotificationConfig struct {
+	Notifications map[string][]Notification `yaml:"notifications"`
+}
+
+type NotificationPayload struct {
+	Utility    string      `json:"utility"`
+	Timestamp  string      `json:"timestamp"`
+	Hostname   string      `json:"hostname"`
+	Status     string      `json:"status"`
+	ReportFile string      `json:"report_file"`
+	Report     *JSONReport `json:"report"`
+}
+
+func ReadNotificationConfig(filename string) (*NotificationConfig, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := &NotificationConfig{}
+	err = yaml.UnmarshalStrict(contents, config)
+	if err != nil {
+		return nil, errors.Errorf("Unable to parse notification config file %s: %v", filename, err)
+	}
+	for utility, notifications := range config.Notifications {
+		if utility != "gpbackup" && utility != "gprestore" {
+			return nil, errors.Errorf("Invalid utility %s in notification config file %s; valid utilities are gpbackup and gprestore", utility, filename)
+		}
+		for i, notification := range notifications {
+			if (notification.Webhook == "") == (notification.Command == "") {
+				return nil, errors.Errorf("Notification %d for %s in %s must have either a webhook or a command", i+1, utility, filename)
+			}
+			for status := range notification.Status {
+				if status != "success" && status != "success_with_errors" && status != "failure" {
+					return nil, errors.Errorf("Invalid status %s for notification %d for %s in %s; valid statuses are success, success_with_errors, and failure",
+						status, i+1, utility, filename)
+				}
+			}
+			if notification.Retries < 0 || notification.RetryInterval < 0 || notification.Timeout < 0 {
+				return nil, errors.Errorf("Notification %d for %s in %s cannot have a negative retries, retry_interval, or timeout", i+1, utility, filename)
+			}
+		}
+	}
+	return config, nil
+}
+
+func (notification Notification) String() string {
+	if notification.Webhook != "" {
+		return fmt.Sprintf("webhook %s", notification.Webhook)
+	}
+	return fmt.Sprintf("command %s", notification.Command)
+}
+
+func (notification Notification) getTimeout() time.Duration {
+	if notification.Timeout == 0 {
+		return defaultNotificationTimeout
+	}
+	return notification.Timeout
+}
+
+func (notification Notification) postWebhook(payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notification.getTimeout())
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.Webhook, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for header, value := range notification.Headers {
+		request.Header.Set(header, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.Errorf("received HTTP status %s", response.Status)
+	}
+	return nil
+}
+
+func (notification Notification) runCommand(payload *NotificationPayload, payloadJSON []byte) error {
+	env := []string{
+		fmt.Sprintf("GP_NOTIFICATION_UTILITY=%s", payload.Utility),
+		fmt.Sprintf("GP_NOTIFICATION_TIMESTAMP=%s", payload.Timestamp),
+		fmt.Sprintf("GP_NOTIFICATION_STATUS=%s", payload.Status),
+		fmt.Sprintf("GP_NOTIFICATION_REPORT_FILE=%s", payload.ReportFile),
+	}
+	output, err := utils.RunCommandWithTimeout(notification.Command, env, bytes.NewReader(payloadJSON), notification.getTimeout())
+	if err != nil && len(output) > 0 {
+		return errors.Errorf("%v: %s", err, bytes.TrimSpace(output))
+	}
+	return err
+}
+
+func (notification Notification) send(payload *NotificationPayload, payloadJSON []byte) error {
+	retryInterval := notification.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultNotificationRetryInterval
+	}
+	var err error
+	for attempt := 0; attempt <= notification.Retries; attempt++ {
+		if attempt > 0 {
+			gplog.Verbose("Retrying %s after error: %v", notification, err)
+			time.Sleep(retryInterval)
+		}
+		if notification.Webhook != "" {
+			err = notification.postWebhook(payloadJSON)
+		} else {
+			err = notification.runCommand(payload, payloadJSON)
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+/*
+ * Failing to notify does not fail the backup or restore, so errors are only
+ * logged as warnings, as when the email report cannot be sent.
+ */
+func SendNotifications(configFilename string, utility string, reportFilePath string, jsonReport *JSONReport) {
+	config, err := ReadNotificationConfig(configFilename)
+	if err != nil {
+		gplog.Warn("Unable to send notifications: %v", err)
+		return
+	}
+	hostname, _ := operating.System.Hostname()
+	payload := &NotificationPayload{
+		Utility:    utility,
+		Timestamp:  jsonReport.Report["timestamp_key"],
+		Hostname:   hostname,
+		Status:     jsonReport.ExitStatus,
+		ReportFile: reportFilePath,
+		Report:     jsonReport,
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		gplog.Warn("Unable to send notifications: %v", err)
+		return
+	}
+	for _, notification := range config.Notifications[utility] {
+		if !notification.Status[payload.Status] {
+			continue
+		}
+		gplog.Verbose("Sending %s notification to %s", payload.Status, notification)
+		err = notification.send(payload, payloadJSON)
+		if err != nil {
+			gplog.Warn("Unable to send notification to %s: %v", notification, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package report_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync/atomic"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/report"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("report/notification tests", func() {
+	var tempDir, configFilename string
+	var jsonReport *report.JSONReport
+	writeConfig := func(contents string) {
+		Expect(os.WriteFile(configFilename, []byte(contents), 0644)).To(Succeed())
+	}
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		configFilename = path.Join(tempDir, "notifications.yaml")
+		jsonReport = &report.JSONReport{
+			Utility:    "gpbackup",
+			Report:     map[string]string{"timestamp_key": "20170101010101"},
+			ExitStatus: "success",
+		}
+	})
+	AfterEach(func() {
+		gplog.SetErrorCode(0)
+	})
+	Describe("ReadNotificationConfig", func() {
+		It("reads webhooks and commands for each utility", func() {
+			writeConfig(`notifications:
+  gpbackup:
+  - webhook: http://localhost:8080/hook
+    headers:
+      Authorization: Bearer token
+    status:
+      failure: true
+    retries: 2
+    retry_interval: 1s
+    timeout: 10s
+  gprestore:
+  - command: echo done
+    status:
+      success: true`)
+			config, err := report.ReadNotificationConfig(configFilename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Notifications["gpbackup"]).To(HaveLen(1))
+			Expect(config.Notifications["gpbackup"][0].Headers).To(Equal(map[string]string{"Authorization": "Bearer token"}))
+			Expect(config.Notifications["gpbackup"][0].Retries).To(Equal(2))
+			Expect(config.Notifications["gpbackup"][0].Timeout.Seconds()).To(Equal(10.0))
+			Expect(config.Notifications["gprestore"][0].Command).To(Equal("echo done"))
+		})
+		It("returns an error for a notification with both a webhook and a command", func() {
+			writeConfig(`notifications:
+  gpbackup:
+  - webhook: http://localhost:8080/hook
+    command: echo done`)
+			_, err := report.ReadNotificationConfig(configFilename)
+			Expect(err).To(MatchError(ContainSubstring("Notification 1 for gpbackup in " + configFilename + " must have either a webhook or a command")))
+		})
+		It("returns an error for an invalid status", func() {
+			writeConfig(`notifications:
+  gpbackup:
+  - command: echo done
+    status:
+      failed: true`)
+			_, err := report.ReadNotificationConfig(configFilename)
+			Expect(err).To(MatchError(ContainSubstring("Invalid status failed for notification 1 for gpbackup")))
+		})
+		It("returns an error for an invalid utility", func() {
+			writeConfig(`notifications:
+  gpbackup_manager:
+  - command: echo done`)
+			_, err := report.ReadNotificationConfig(configFilename)
+			Expect(err).To(MatchError(ContainSubstring("Invalid utility gpbackup_manager")))
+		})
+		It("returns an error for an unknown field", func() {
+			writeConfig(`notifications:
+  gpbackup:
+  - command: echo done
+    retry: 3`)
+			_, err := report.ReadNotificationConfig(configFilename)
+			Expect(err).To(MatchError(ContainSubstring("Unable to parse notification config file")))
+		})
+	})
+	Describe("SendNotifications", func() {
+		var server *httptest.Server
+		var requests int32
+		var failRequests int32
+		var receivedPayload report.NotificationPayload
+		var receivedHeader http.Header
+		BeforeEach(func() {
+			requests, failRequests = 0, 0
+			receivedPayload = report.NotificationPayload{}
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= atomic.LoadInt32(&failRequests) {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				receivedHeader = r.Header
+				body, _ := io.ReadAll(r.Body)
+				_ = json.Unmarshal(body, &receivedPayload)
+			}))
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+		It("posts the report to a webhook for a matching status", func() {
+			writeConfig(fmt.Sprintf(`notifications:
+  gpbackup:
+  - webhook: %s
+    headers:
+      Authorization: Bearer token
+    status:
+      success: true`, server.URL))
+			report.SendNotifications(configFilename, "gpbackup", "/tmp/gpbackup_20170101010101_report", jsonReport)
+
+			Expect(requests).To(Equal(int32(1)))
+			Expect(receivedHeader.Get("Content-Type")).To(Equal("application/json"))
+			Expect(receivedHeader.Get("Authorization")).To(Equal("Bearer token"))
+			Expect(receivedPayload.Utility).To(Equal("gpbackup"))
+			Expect(receivedPayload.Timestamp).To(Equal("20170101010101"))
+			Expect(receivedPayload.Status).To(Equal("success"))
+			Expect(receivedPayload.ReportFile).To(Equal("/tmp/gpbackup_20170101010101_report"))
+			Expect(receivedPayload.Report.Report).To(HaveKeyWithValue("timestamp_key", "20170101010101"))
+		})
+		It("does not notify for a status that is not set", func() {
+			writeConfig(fmt.Sprintf(`notifications:
+  gpbackup:
+  - webhook: %s
+    status:
+      failure: true
+  gprestore:
+  - webhook: %s
+    status:
+      success: true`, server.URL, server.URL))
+			report.SendNotifications(configFilename, "gpbackup", "/tmp/gpbackup_20170101010101_report", jsonReport)
+
+			Expect(requests).To(Equal(int32(0)))
+		})
+		It("retries a webhook that fails", func() {
+			failRequests = 2
+			writeConfig(fmt.Sprintf(`notifications:
+  gpbackup:
+  - webhook: %s
+    status:
+      success: true
+    retries: 2
+    retry_interval: 1ms`, server.URL))
+			report.SendNotifications(configFilename, "gpbackup", "/tmp/gpbackup_20170101010101_report", jsonReport)
+
+			Expect(requests).To(Equal(int32(3)))
+			Expect(receivedPayload.Status).To(Equal("success"))
+			Expect(logfile).ToNot(Say("Unable to send notification"))
+		})
+		It("warns when a webhook fails after all retries", func() {
+			failRequests = 5
+			writeConfig(fmt.Sprintf(`notifications:
+  gpbackup:
+  - webhook: %s
+    status:
+      success: true
+    retries: 1
+    retry_interval: 1ms`, server.URL))
+			report.SendNotifications(configFilename, "gpbackup", "/tmp/gpbackup_20170101010101_report", jsonReport)
+
+			Expect(requests).To(Equal(int32(2)))
+			Expect(logfile).To(Say(`Unable to send notification to webhook %s: received HTTP status 503 Service Unavailable`, server.URL))
+		})
+		It("runs a command with the report on its standard input", func() {
+			outputFilename := path.Join(tempDir, "output")
+			jsonReport.ExitStatus = "failure"
+			writeConfig(fmt.Sprintf(`notifications:
+  gpbackup:
+  - command: 'echo "$GP_NOTIFICATION_STATUS $GP_NOTIFICATION_TIMESTAMP" > %s; cat >> %s'
+    status:
+      failure: true`, outputFilename, outputFilename))
+			report.SendNotifications(configFilename, "gpbackup", "/tmp/gpbackup_20170101010101_report", jsonReport)
+
+			output, err := os.ReadFile(outputFilename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(output)).To(HavePrefix("failure 20170101010101\n{"))
+			Expect(string(output)).To(ContainSubstring(`"status":"failure"`))
+		})
+		It("warns when a command times out", func() {
+			writeConfig(`notifications:
+  gpbackup:
+  - command: sleep 5
+    status:
+      success: true
+    timeout: 10ms`)
+			report.SendNotifications(configFilename, "gpbackup", "/tmp/gpbackup_20170101010101_report", jsonReport)
+
+			Expect(logfile).To(Say(`Unable to send notification to command sleep 5: timed out after 10ms`))
+		})
+	})
+})
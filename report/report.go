@@ -90,15 +90,31 @@ func (report *Report) ConstructBackupParamsString() {
 	if report.WithStatistics {
 		statsStr = "Yes"
 	}
+	// A masked backup must never be mistaken for a copy of the real data
+	maskingStr := ""
+	if len(report.MaskingRules) > 0 {
+		numMaskedColumns := 0
+		for _, columnRules := range report.MaskingRules {
+			numMaskedColumns += len(columnRules)
+		}
+		maskingStr = fmt.Sprintf("masked columns: %d\n", numMaskedColumns)
+	}
+	sampleStr := ""
+	if report.Sampled() {
+		sampleStr = fmt.Sprintf("sampled rows: %g%%\n", report.SamplePercent)
+		if report.SampleForeignKeys {
+			sampleStr = fmt.Sprintf("sampled rows: %g%% following foreign keys\n", report.SamplePercent)
+		}
+	}
 	backupParamsTemplate := `compression: %s
 plugin executable: %s
 backup section: %s
 object filtering: %s
 includes statistics: %s
 data file format: %s
-%s`
+%s%s%s`
 	report.BackupParamsString = fmt.Sprintf(backupParamsTemplate, compressStr, pluginStr, sectionStr, filterStr,
-		statsStr, filesStr, report.constructIncrementalSection())
+		statsStr, filesStr, maskingStr, sampleStr, report.constructIncrementalSection())
 }
 
 func (report *Report) constructIncrementalSection() string {
@@ -109,18 +125,16 @@ func (report *Report) constructIncrementalSection() string {
 	for _, restorePlanEntry := range report.RestorePlan {
 		backupTimestamps = append(backupTimestamps, restorePlanEntry.Timestamp)
 	}
+	differentialStr := ""
+	if report.Differential {
+		differentialStr = "differential: True\n"
+	}
 	return fmt.Sprintf(`incremental: True
-incremental backup set:
-%s`, strings.Join(backupTimestamps, "\n"))
+%sincremental backup set:
+%s`, differentialStr, strings.Join(backupTimestamps, "\n"))
 }
 
-func (report *Report) WriteBackupReportFile(reportFilename string, timestamp string, endtime time.Time, objectCounts map[string]int, errMsg string) {
-	reportFile, err := iohelper.OpenFileForWriting(reportFilename)
-	if err != nil {
-		gplog.Error("Unable to open backup report file %s", reportFilename)
-		return
-	}
-
+func (report *Report) constructBackupReportInfo(timestamp string, endtime time.Time, errMsg string) []LineInfo {
 	gpbackupCommandLine := strings.Join(os.Args, " ")
 	start, end, duration := GetDurationInfo(timestamp, endtime)
 
@@ -158,6 +172,17 @@ func (report *Report) WriteBackupReportFile(reportFilename string, timestamp str
 	}
 	reportInfo = append(reportInfo,
 		LineInfo{Key: "segment count:", Value: fmt.Sprintf("%d", report.SegmentCount)})
+	return reportInfo
+}
+
+func (report *Report) WriteBackupReportFile(reportFilename string, timestamp string, endtime time.Time, objectCounts map[string]int, errMsg string) {
+	reportFile, err := iohelper.OpenFileForWriting(reportFilename)
+	if err != nil {
+		gplog.Error("Unable to open backup report file %s", reportFilename)
+		return
+	}
+
+	reportInfo := report.constructBackupReportInfo(timestamp, endtime, errMsg)
 
 	_, err = fmt.Fprint(reportFile, "Cloudberry Database Backup Report\n\n")
 	if err != nil {
@@ -174,18 +199,10 @@ func (report *Report) WriteBackupReportFile(reportFilename string, timestamp str
 	_ = operating.System.Chmod(reportFilename, 0444)
 }
 
-func WriteRestoreReportFile(reportFilename string, backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, origSize int, destSize int, errMsg string) {
-	reportFile, err := iohelper.OpenFileForWriting(reportFilename)
-	if err != nil {
-		gplog.Error("Unable to open restore report file %s", reportFilename)
-		return
-	}
-
+func constructRestoreReportInfo(backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, origSize int, destSize int, errMsg string, partialTables []string) []LineInfo {
 	gprestoreCommandLine := strings.Join(os.Args, " ")
 	start, end, duration := GetDurationInfo(startTimestamp, operating.System.Now())
 
-	utils.MustPrintf(reportFile, "Cloudberry Database Restore Report\n\n")
-
 	reportInfo := make([]LineInfo, 0)
 	reportInfo = append(reportInfo,
 		LineInfo{Key: "timestamp key:", Value: backupTimestamp},
@@ -199,6 +216,11 @@ func WriteRestoreReportFile(reportFilename string, backupTimestamp string, start
 		LineInfo{Key: "end time:", Value: end},
 		LineInfo{Key: "duration:", Value: duration},
 	)
+	// Tables backed up with a row filter only hold the rows that matched it
+	if len(partialTables) > 0 {
+		reportInfo = append(reportInfo,
+			LineInfo{Key: "partial tables:", Value: strings.Join(partialTables, ", ")})
+	}
 
 	var restoreStatus string
 	errorCode := gplog.GetErrorCode()
@@ -217,6 +239,19 @@ func WriteRestoreReportFile(reportFilename string, backupTimestamp string, start
 			LineInfo{},
 			LineInfo{Key: "restore status:", Value: "Success"})
 	}
+	return reportInfo
+}
+
+func WriteRestoreReportFile(reportFilename string, backupTimestamp string, startTimestamp string, connectionPool *dbconn.DBConn, restoreVersion string, origSize int, destSize int, errMsg string, partialTables []string) {
+	reportFile, err := iohelper.OpenFileForWriting(reportFilename)
+	if err != nil {
+		gplog.Error("Unable to open restore report file %s", reportFilename)
+		return
+	}
+
+	utils.MustPrintf(reportFile, "Cloudberry Database Restore Report\n\n")
+
+	reportInfo := constructRestoreReportInfo(backupTimestamp, startTimestamp, connectionPool, restoreVersion, origSize, destSize, errMsg, partialTables)
 
 	logOutputReport(reportFile, reportInfo)
 
@@ -273,15 +308,18 @@ func PrintObjectCounts(reportFile io.WriteCloser, objectCounts map[string]int) {
 	}
 	sort.Strings(objectSlice)
 	for _, object := range objectSlice {
-		if object == "Database GUC's" {
-			objectStr += fmt.Sprintf("%-*s%d\n", maxSize+3, "database GUC's", objectCounts[object])
-		} else {
-			objectStr += fmt.Sprintf("%-*s%d\n", maxSize+3, strings.ToLower(object), objectCounts[object])
-		}
+		objectStr += fmt.Sprintf("%-*s%d\n", maxSize+3, getObjectCountKey(object), objectCounts[object])
 	}
 	utils.MustPrintf(reportFile, objectStr)
 }
 
+func getObjectCountKey(object string) string {
+	if object == "Database GUC's" {
+		return "database GUC's"
+	}
+	return strings.ToLower(object)
+}
+
 /*
  * This function will not error out if the user has gprestore X.Y.Z
  * and gpbackup X.Y.Z+dev, when technically the uncommitted code changes
@@ -333,14 +371,7 @@ func GetContacts(filename string, utility string) string {
 		return ""
 	}
 
-	errorCode := gplog.GetErrorCode()
-	exitStatus := "success"
-	if errorCode == 1 {
-		exitStatus = "success_with_errors"
-	} else if errorCode == 2 {
-		exitStatus = "failure"
-	}
-
+	exitStatus := getExitStatus()
 	contactList := make([]string, 0)
 	for _, contact := range contactFile.Contacts[utility] {
 		if contact.Status[exitStatus] {
@@ -350,6 +381,16 @@ func GetContacts(filename string, utility string) string {
 	return strings.Join(contactList, " ")
 }
 
+func getExitStatus() string {
+	errorCode := gplog.GetErrorCode()
+	if errorCode == 1 {
+		return "success_with_errors"
+	} else if errorCode == 2 {
+		return "failure"
+	}
+	return "success"
+}
+
 func ConstructEmailMessage(timestamp string, contactList string, reportFilePath string, utility string, status bool) string {
 	hostname, _ := operating.System.Hostname()
 	statusString := history.BackupStatusSucceed
@@ -244,7 +244,7 @@ incremental backup set:
 
 		It("writes a report for a failed restore", func() {
 			gplog.SetErrorCode(2)
-			report.WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, 3, 4, "Cannot access /tmp/backups: Permission denied")
+			report.WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, 3, 4, "Cannot access /tmp/backups: Permission denied", nil)
 			Expect(buffer).To(Say(`Cloudberry Database Restore Report
 
 timestamp key:           20170101010101
@@ -265,7 +265,7 @@ restore error:           Cannot access /tmp/backups: Permission denied`))
 		})
 		It("writes a report for a successful restore", func() {
 			gplog.SetErrorCode(0)
-			report.WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, 3, 3, "")
+			report.WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, 3, 3, "", nil)
 			Expect(buffer).To(Say(`Cloudberry Database Restore Report
 
 timestamp key:           20170101010101
@@ -285,7 +285,7 @@ restore status:          Success`))
 		})
 		It("writes a report for a successful restore with errors", func() {
 			gplog.SetErrorCode(1)
-			report.WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, 3, 3, "")
+			report.WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, 3, 3, "", nil)
 			Expect(buffer).To(Say(`Cloudberry Database Restore Report
 
 timestamp key:           20170101010101
@@ -303,6 +303,14 @@ duration:                4:03:01
 
 restore status:          Success but non-fatal errors occurred. See log file .+ for details.`))
 		})
+		It("writes a report for a restore of tables that were backed up with row filters", func() {
+			gplog.SetErrorCode(0)
+			report.WriteRestoreReportFile("filename", timestamp, restoreStartTime, connectionPool, restoreVersion, 3, 3, "", []string{"public.bar", "public.foo"})
+			Expect(buffer).To(Say(`duration:                4:03:01
+partial tables:          public.bar, public.foo
+
+restore status:          Success`))
+		})
 	})
 	Describe("SetBackupParamFromFlags", func() {
 		AfterEach(func() {
@@ -340,6 +348,34 @@ restore status:          Success but non-fatal errors occurred. See log file .+
 			}, backupConfig)
 		})
 	})
+	Describe("ConstructBackupParamsString", func() {
+		It("records the number of masked columns of a masked backup", func() {
+			backupReport := &report.Report{BackupConfig: history.BackupConfig{
+				MaskingRules: map[string]map[string]utils.MaskingRule{"public.foo": {"a": {Rule: "hash"}, "b": {Rule: "nullify"}}},
+			}}
+			backupReport.ConstructBackupParamsString()
+			Expect(backupReport.BackupParamsString).To(HaveSuffix("data file format: Multiple Data Files Per Segment\nmasked columns: 2\nincremental: False"))
+		})
+		It("records the sample percentage of a sampled backup", func() {
+			backupReport := &report.Report{BackupConfig: history.BackupConfig{SamplePercent: 2.5, SampleForeignKeys: true}}
+			backupReport.ConstructBackupParamsString()
+			Expect(backupReport.BackupParamsString).To(HaveSuffix("data file format: Multiple Data Files Per Segment\nsampled rows: 2.5% following foreign keys\nincremental: False"))
+		})
+		It("records the backup set of a differential backup", func() {
+			backupReport := &report.Report{BackupConfig: history.BackupConfig{
+				Incremental:  true,
+				Differential: true,
+				RestorePlan:  []history.RestorePlanEntry{{Timestamp: "20170101010101"}, {Timestamp: "20170103010101"}},
+			}}
+			backupReport.ConstructBackupParamsString()
+			Expect(backupReport.BackupParamsString).To(HaveSuffix("incremental: True\ndifferential: True\nincremental backup set:\n20170101010101\n20170103010101"))
+		})
+		It("does not record masked columns for a backup without masking rules", func() {
+			backupReport := &report.Report{}
+			backupReport.ConstructBackupParamsString()
+			Expect(backupReport.BackupParamsString).ToNot(ContainSubstring("masked columns"))
+		})
+	})
 	Describe("GetDurationInfo", func() {
 		timestamp := "20170101010101"
 		AfterEach(func() {
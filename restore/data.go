@@ -6,12 +6,14 @@ package restore
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 
 	"github.com/cloudberrydb/gp-common-go-libs/cluster"
 	"github.com/cloudberrydb/gp-common-go-libs/dbconn"
 	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
 	"github.com/cloudberrydb/gpbackup/filepath"
 	"github.com/cloudberrydb/gpbackup/options"
 	"github.com/cloudberrydb/gpbackup/toc"
@@ -84,10 +86,12 @@ func restoreSingleTableData(fpInfo *filepath.FilePathInfo, entry toc.Coordinator
 		destinationToRead = fpInfo.GetTableBackupFilePathForCopyCommand(entry.Oid, utils.GetPipeThroughProgram().Extension, backupConfig.SingleDataFile)
 	}
 	gplog.Debug("Reading from %s", destinationToRead)
+	start := operating.System.Now()
 	numRowsRestored, err := CopyTableIn(connectionPool, tableName, entry.AttributeString, destinationToRead, backupConfig.SingleDataFile, whichConn)
 	if err != nil {
 		return err
 	}
+	rowsCopied := numRowsRestored
 	numRowsBackedUp := entry.RowsCopied
 
 	// For replicated tables, we don't restore second and subsequent batches of data in the larger-to-smaller case,
@@ -113,7 +117,17 @@ func restoreSingleTableData(fpInfo *filepath.FilePathInfo, entry toc.Coordinator
 		if err != nil {
 			return err
 		}
+	} else if backupConfig.SampleForeignKeys && !entry.IsReplicated {
+		err = RedistributeTableData(tableName, whichConn)
+		if err != nil {
+			return err
+		}
 	}
+	schema, name := remapper.TableName(entry.Schema, entry.Name)
+	if opts.RedirectSchema != "" {
+		schema = opts.RedirectSchema
+	}
+	reportTimings.AddTable(entry.Oid, schema, name, rowsCopied, start)
 	return err
 }
 
@@ -153,8 +167,28 @@ func RedistributeTableData(tableName string, whichConn int) error {
 	return err
 }
 
+/*
+ * Loading the largest tables first keeps one large table that happens to be
+ * loaded last from leaving the other connections idle while it finishes.
+ * Backups taken before sizes were recorded keep their original order.
+ */
+func SortDataEntriesBySize(dataEntries []toc.CoordinatorDataEntry) []toc.CoordinatorDataEntry {
+	sortedEntries := make([]toc.CoordinatorDataEntry, len(dataEntries))
+	copy(sortedEntries, dataEntries)
+	sort.SliceStable(sortedEntries, func(i, j int) bool {
+		return sortedEntries[i].EstimatedSize > sortedEntries[j].EstimatedSize
+	})
+	return sortedEntries
+}
+
 func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.CoordinatorDataEntry,
 	gucStatements []toc.StatementWithType, dataProgressBar utils.ProgressBar) int32 {
+	remainingEntries := restoreJournal.FilterRestoredTables(fpInfo.Timestamp, dataEntries)
+	for i := len(remainingEntries); i < len(dataEntries); i++ {
+		dataProgressBar.Increment()
+	}
+	isResumed := len(remainingEntries) < len(dataEntries)
+	dataEntries = remainingEntries
 	totalTables := len(dataEntries)
 	if totalTables == 0 {
 		gplog.Verbose("No data to restore for timestamp = %s", fpInfo.Timestamp)
@@ -162,6 +196,10 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Co
 	}
 
 	origSize, destSize, resizeCluster := GetResizeClusterInfo()
+	if !backupConfig.SingleDataFile && !resizeCluster {
+		// gpbackup_helper reads the data of a single data file or resize restore in the order it was given
+		dataEntries = SortDataEntriesBySize(dataEntries)
+	}
 	if backupConfig.SingleDataFile || resizeCluster {
 		msg := ""
 		if backupConfig.SingleDataFile {
@@ -189,15 +227,19 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Co
 		if wasTerminated {
 			return 0
 		}
-		isFilter := false
+		// Tables restored before a resumed restore failed are skipped like filtered tables
+		isFilter := isResumed
 		if len(opts.IncludedRelations) > 0 || len(opts.ExcludedRelations) > 0 || len(opts.IncludedSchemas) > 0 || len(opts.ExcludedSchemas) > 0 {
 			isFilter = true
 		}
 		compressStr := ""
 		if backupConfig.Compressed {
 			compressStr = fmt.Sprintf(" --compression-type %s ", utils.GetPipeThroughProgram().Name)
+			if MustGetFlagInt(options.COMPRESSION_WORKERS) > 1 {
+				compressStr += fmt.Sprintf("--compression-workers %d ", MustGetFlagInt(options.COMPRESSION_WORKERS))
+			}
 		}
-		utils.StartGpbackupHelpers(globalCluster, fpInfo, "--restore-agent", MustGetFlagString(options.PLUGIN_CONFIG), compressStr, MustGetFlagBool(options.ON_ERROR_CONTINUE), isFilter, &wasTerminated, initialPipes, backupConfig.SingleDataFile, resizeCluster, origSize, destSize)
+		utils.StartGpbackupHelpers(globalCluster, fpInfo, "--restore-agent", MustGetFlagString(options.PLUGIN_CONFIG), compressStr, MustGetFlagBool(options.ON_ERROR_CONTINUE), isFilter, &wasTerminated, initialPipes, backupConfig.SingleDataFile, false, MustGetFlagString(options.ENCRYPTION_KEY_FILE), resizeCluster, origSize, destSize)
 	}
 	/*
 	 * We break when an interrupt is received and rely on
@@ -221,7 +263,7 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Co
 					dataProgressBar.(*pb.ProgressBar).NotPrint = true
 					return
 				}
-				tableName := utils.MakeFQN(entry.Schema, entry.Name)
+				tableName := utils.MakeFQN(remapper.TableName(entry.Schema, entry.Name))
 				if opts.RedirectSchema != "" {
 					tableName = utils.MakeFQN(opts.RedirectSchema, entry.Name)
 				}
@@ -231,7 +273,11 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Co
 					err = TruncateTable(tableName, whichConn)
 				}
 				if err == nil {
-					err = restoreSingleTableData(&fpInfo, entry, tableName, whichConn, origSize, destSize)
+					if MustGetFlagString(options.ON_CONFLICT) != "" {
+						err = restoreSingleTableDataWithMerge(&fpInfo, entry, tableName, whichConn, origSize, destSize)
+					} else {
+						err = restoreSingleTableData(&fpInfo, entry, tableName, whichConn, origSize, destSize)
+					}
 
 					if gplog.GetVerbosity() > gplog.LOGINFO {
 						// No progress bar at this log level, so we note table count here
@@ -254,6 +300,8 @@ func restoreDataFromTimestamp(fpInfo filepath.FilePathInfo, dataEntries []toc.Co
 					mutex.Lock()
 					errorTablesData[tableName] = Empty{}
 					mutex.Unlock()
+				} else {
+					restoreJournal.RecordTable(fpInfo.Timestamp, entry.Oid)
 				}
 
 				if backupConfig.SingleDataFile {
@@ -295,3 +343,33 @@ func CreateInitialSegmentPipes(oidList []string, c *cluster.Cluster, connectionP
 	}
 	return maxPipes
 }
+
+/*
+ * A table that was backed up with a row filter only holds the rows that
+ * matched the filter, so it is reported as partially restored.
+ */
+func GetPartialTables(dataEntries map[string][]toc.CoordinatorDataEntry, redirectSchema string) []string {
+	partialTableSet := make(map[string]bool)
+	for _, entries := range dataEntries {
+		for _, entry := range entries {
+			if entry.RowFilter == "" {
+				continue
+			}
+			schema, name := remapper.TableName(entry.Schema, entry.Name)
+			if redirectSchema != "" {
+				schema = redirectSchema
+			}
+			tableName := utils.MakeFQN(schema, name)
+			if !partialTableSet[tableName] {
+				gplog.Warn("Table %s was backed up with the row filter %s, so only the rows matching it will be restored", tableName, entry.RowFilter)
+			}
+			partialTableSet[tableName] = true
+		}
+	}
+	partialTables := make([]string, 0, len(partialTableSet))
+	for tableName := range partialTableSet {
+		partialTables = append(partialTables, tableName)
+	}
+	sort.Strings(partialTables)
+	return partialTables
+}
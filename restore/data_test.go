@@ -12,11 +12,13 @@ import (
 	"github.com/cloudberrydb/gpbackup/history"
 	"github.com/cloudberrydb/gpbackup/options"
 	"github.com/cloudberrydb/gpbackup/restore"
+	"github.com/cloudberrydb/gpbackup/toc"
 	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/jackc/pgconn"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("restore/data tests", func() {
@@ -133,6 +135,46 @@ var _ = Describe("restore/data tests", func() {
 			Expect(err.Error()).To(Equal("Expected to restore 10 rows to table public.foo, but restored 5 instead"))
 		})
 	})
+	Describe("SortDataEntriesBySize", func() {
+		It("orders entries from largest to smallest, keeping entries of the same size in their original order", func() {
+			dataEntries := []toc.CoordinatorDataEntry{
+				{Name: "small", Oid: 1, EstimatedSize: 8192},
+				{Name: "empty1", Oid: 2},
+				{Name: "large", Oid: 3, EstimatedSize: 819200},
+				{Name: "empty2", Oid: 4},
+			}
+			sortedEntries := restore.SortDataEntriesBySize(dataEntries)
+
+			Expect(sortedEntries).To(Equal([]toc.CoordinatorDataEntry{dataEntries[2], dataEntries[0], dataEntries[1], dataEntries[3]}))
+			Expect(dataEntries[0].Name).To(Equal("small"))
+		})
+		It("keeps the original order of entries from backups without sizes", func() {
+			dataEntries := []toc.CoordinatorDataEntry{{Name: "foo", Oid: 2}, {Name: "bar", Oid: 1}}
+			Expect(restore.SortDataEntriesBySize(dataEntries)).To(Equal(dataEntries))
+		})
+	})
+	Describe("GetPartialTables", func() {
+		dataEntries := map[string][]toc.CoordinatorDataEntry{
+			"20170101010101": {
+				{Schema: "public", Name: "foo", Oid: 1, RowFilter: "region = 'EU'"},
+				{Schema: "public", Name: "bar", Oid: 2},
+				{Schema: "public", Name: "baz", Oid: 3, RowFilter: "a > 1"},
+			},
+		}
+		It("returns the tables that were backed up with a row filter and warns about each", func() {
+			partialTables := restore.GetPartialTables(dataEntries, "")
+
+			Expect(partialTables).To(Equal([]string{"public.baz", "public.foo"}))
+			Expect(logfile).To(Say(`Table public.foo was backed up with the row filter region = 'EU', so only the rows matching it will be restored`))
+		})
+		It("returns the tables in the redirect schema", func() {
+			Expect(restore.GetPartialTables(dataEntries, "other")).To(Equal([]string{"other.baz", "other.foo"}))
+		})
+		It("returns no tables for a backup without row filters", func() {
+			unfilteredEntries := map[string][]toc.CoordinatorDataEntry{"20170101010101": {{Schema: "public", Name: "bar", Oid: 2}}}
+			Expect(restore.GetPartialTables(unfilteredEntries, "")).To(BeEmpty())
+		})
+	})
 })
 
 func batchMapToString(m map[int]map[int]int) string {
@@ -8,6 +8,7 @@ import (
 	"github.com/cloudberrydb/gpbackup/filepath"
 	"github.com/cloudberrydb/gpbackup/history"
 	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/report"
 	"github.com/cloudberrydb/gpbackup/toc"
 	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/spf13/pflag"
@@ -40,6 +41,15 @@ var (
 	errorTablesMetadata map[string]Empty
 	errorTablesData     map[string]Empty
 	opts                *options.Options
+	restoreJournal      *RestoreJournal
+	reportTimings       *report.Timings
+	hookConfig          *utils.HookConfig
+	partialTables       []string
+	remapper            *Remapper
+	metadataEditor      *MetadataEditor
+	listedTableOrder    map[string]int
+	primaryKeys         map[string][]string
+	swapper             *Swapper
 	/*
 	 * Used for synchronizing DoCleanup.  In DoInit() we increment the group
 	 * and then wait for at least one DoCleanup to finish, either in DoTeardown
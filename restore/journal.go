@@ -0,0 +1,195 @@
+package restore
+
+/*
+ * This file contains the progress journal used to resume a failed restore,
+ * given by --resume.
+ *
+ * Each metadata statement that executes successfully and each table whose
+ * data is loaded is appended to the journal, so a resumed restore can skip
+ * them and continue with the rest.
+ */
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/pkg/errors"
+)
+
+type RestoreJournal struct {
+	file       *os.File
+	statements map[string]bool
+	tables     map[string]bool
+	mutex      sync.Mutex
+}
+
+func NewRestoreJournal(filename string, resume bool) (*RestoreJournal, error) {
+	journal := &RestoreJournal{statements: make(map[string]bool), tables: make(map[string]bool)}
+	if resume {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		journal.parse(string(contents))
+	}
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	journal.file = file
+	return journal, nil
+}
+
+/*
+ * A line that was only partially written when the restore failed is ignored,
+ * so that statement or table is restored again.
+ */
+func (journal *RestoreJournal) parse(contents string) {
+	for _, line := range strings.Split(contents, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "statement" && len(fields[1]) == sha256.Size*2 {
+			journal.statements[fields[1]] = true
+		} else if len(fields) == 3 && fields[0] == "table" {
+			journal.tables[fields[1]+" "+fields[2]] = true
+		}
+	}
+}
+
+func statementKey(statement toc.StatementWithType) string {
+	sum := sha256.Sum256([]byte(statement.Statement))
+	return hex.EncodeToString(sum[:])
+}
+
+func tableKey(timestamp string, oid uint32) string {
+	return fmt.Sprintf("%s %d", timestamp, oid)
+}
+
+// Session GUCs must be set on every connection, so they are never skipped
+func isJournaledStatement(statement toc.StatementWithType) bool {
+	return statement.ObjectType != "SESSION GUCS"
+}
+
+func (journal *RestoreJournal) IsStatementComplete(statement toc.StatementWithType) bool {
+	if journal == nil || !isJournaledStatement(statement) {
+		return false
+	}
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	return journal.statements[statementKey(statement)]
+}
+
+func (journal *RestoreJournal) RecordStatement(statement toc.StatementWithType) {
+	if journal == nil || !isJournaledStatement(statement) {
+		return
+	}
+	key := statementKey(statement)
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	journal.statements[key] = true
+	journal.write(fmt.Sprintf("statement %s\n", key))
+}
+
+func (journal *RestoreJournal) IsTableComplete(timestamp string, oid uint32) bool {
+	if journal == nil {
+		return false
+	}
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	return journal.tables[tableKey(timestamp, oid)]
+}
+
+func (journal *RestoreJournal) RecordTable(timestamp string, oid uint32) {
+	if journal == nil {
+		return
+	}
+	key := tableKey(timestamp, oid)
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	journal.tables[key] = true
+	journal.write(fmt.Sprintf("table %s\n", key))
+}
+
+/*
+ * Failing to record progress only means that the work is repeated if the
+ * restore is resumed, so errors are logged rather than failing the restore.
+ */
+func (journal *RestoreJournal) write(line string) {
+	if journal.file == nil {
+		return
+	}
+	_, err := journal.file.WriteString(line)
+	if err == nil {
+		err = journal.file.Sync()
+	}
+	if err != nil {
+		gplog.Warn("Unable to record restore progress in %s: %v", journal.file.Name(), err)
+	}
+}
+
+func (journal *RestoreJournal) Close() {
+	if journal == nil || journal.file == nil {
+		return
+	}
+	err := journal.file.Close()
+	if err != nil {
+		gplog.Warn("Unable to close restore progress file: %v", err)
+	}
+	journal.file = nil
+}
+
+/*
+ * Returns the data entries that have not already been restored, so that
+ * gpbackup_helper is only asked for the data that is still needed.
+ */
+func (journal *RestoreJournal) FilterRestoredTables(timestamp string, dataEntries []toc.CoordinatorDataEntry) []toc.CoordinatorDataEntry {
+	if journal == nil {
+		return dataEntries
+	}
+	remainingEntries := make([]toc.CoordinatorDataEntry, 0, len(dataEntries))
+	for _, entry := range dataEntries {
+		if !journal.IsTableComplete(timestamp, entry.Oid) {
+			remainingEntries = append(remainingEntries, entry)
+		}
+	}
+	return remainingEntries
+}
+
+func initializeRestoreJournal() {
+	resumeTimestamp := MustGetFlagString(options.RESUME)
+	journalFilename := globalFPInfo.GetRestoreProgressFilePath(restoreStartTime)
+	if resumeTimestamp != "" {
+		_, err := os.Stat(journalFilename)
+		if err != nil {
+			gplog.Fatal(errors.Errorf("Restore %s of backup %s cannot be resumed, as its progress file %s was not found",
+				resumeTimestamp, globalFPInfo.Timestamp, journalFilename), "")
+		}
+		gplog.Info("Resuming restore %s", resumeTimestamp)
+		// These files are written again when the resumed restore finishes
+		for _, filename := range []string{globalFPInfo.GetRestoreReportFilePath(restoreStartTime), globalFPInfo.GetRestoreJSONReportFilePath(restoreStartTime),
+			globalFPInfo.GetErrorTablesMetadataFilePath(restoreStartTime), globalFPInfo.GetErrorTablesDataFilePath(restoreStartTime)} {
+			err = os.Remove(filename)
+			if err != nil && !os.IsNotExist(err) {
+				gplog.Fatal(errors.Errorf("Unable to remove %s from the failed restore: %v", filename, err), "")
+			}
+		}
+	}
+	var err error
+	restoreJournal, err = NewRestoreJournal(journalFilename, resumeTimestamp != "")
+	gplog.FatalOnError(err)
+}
+
+func removeRestoreJournal() {
+	restoreJournal.Close()
+	err := os.Remove(globalFPInfo.GetRestoreProgressFilePath(restoreStartTime))
+	if err != nil && !os.IsNotExist(err) {
+		gplog.Warn("Unable to remove restore progress file: %v", err)
+	}
+}
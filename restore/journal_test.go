@@ -0,0 +1,82 @@
+package restore_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/cloudberrydb/gpbackup/restore"
+	"github.com/cloudberrydb/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/journal tests", func() {
+	var (
+		tempDir         string
+		journalFilename string
+	)
+	table := toc.StatementWithType{ObjectType: "TABLE", Schema: "public", Name: "foo", Statement: "CREATE TABLE public.foo (i integer);"}
+	guc := toc.StatementWithType{ObjectType: "SESSION GUCS", Statement: "SET client_encoding = 'UTF8';"}
+	BeforeEach(func() {
+		tempDir, _ = ioutil.TempDir("", "journal_test")
+		journalFilename = tempDir + "/gprestore_20170101010101_20170102010101_progress"
+	})
+	AfterEach(func() {
+		_ = os.RemoveAll(tempDir)
+	})
+	It("remembers completed statements and tables when the restore is resumed", func() {
+		journal, err := restore.NewRestoreJournal(journalFilename, false)
+		Expect(err).ToNot(HaveOccurred())
+		journal.RecordStatement(table)
+		journal.RecordTable("20170101010101", 16384)
+		journal.Close()
+
+		resumedJournal, err := restore.NewRestoreJournal(journalFilename, true)
+		Expect(err).ToNot(HaveOccurred())
+		defer resumedJournal.Close()
+		Expect(resumedJournal.IsStatementComplete(table)).To(BeTrue())
+		Expect(resumedJournal.IsTableComplete("20170101010101", 16384)).To(BeTrue())
+		Expect(resumedJournal.IsTableComplete("20170101010102", 16384)).To(BeFalse())
+	})
+	It("starts empty when the restore is not resumed", func() {
+		journal, _ := restore.NewRestoreJournal(journalFilename, false)
+		journal.RecordStatement(table)
+		journal.Close()
+
+		newJournal, err := restore.NewRestoreJournal(journalFilename, false)
+		Expect(err).ToNot(HaveOccurred())
+		defer newJournal.Close()
+		Expect(newJournal.IsStatementComplete(table)).To(BeFalse())
+	})
+	It("never skips session GUCs", func() {
+		journal, _ := restore.NewRestoreJournal(journalFilename, false)
+		defer journal.Close()
+		journal.RecordStatement(guc)
+		Expect(journal.IsStatementComplete(guc)).To(BeFalse())
+	})
+	It("ignores a line that was only partially written", func() {
+		_ = ioutil.WriteFile(journalFilename, []byte("table 20170101010101 16384\ntable 2017010101"), 0644)
+		journal, err := restore.NewRestoreJournal(journalFilename, true)
+		Expect(err).ToNot(HaveOccurred())
+		defer journal.Close()
+		Expect(journal.IsTableComplete("20170101010101", 16384)).To(BeTrue())
+		Expect(journal.IsTableComplete("2017010101", 0)).To(BeFalse())
+	})
+	It("filters out data entries that were already restored", func() {
+		journal, _ := restore.NewRestoreJournal(journalFilename, false)
+		defer journal.Close()
+		journal.RecordTable("20170101010101", 1)
+		entry1 := toc.CoordinatorDataEntry{Schema: "public", Name: "foo", Oid: 1}
+		entry2 := toc.CoordinatorDataEntry{Schema: "public", Name: "bar", Oid: 2}
+
+		remaining := journal.FilterRestoredTables("20170101010101", []toc.CoordinatorDataEntry{entry1, entry2})
+		Expect(remaining).To(Equal([]toc.CoordinatorDataEntry{entry2}))
+	})
+	It("does nothing without a journal", func() {
+		var journal *restore.RestoreJournal
+		journal.RecordStatement(table)
+		Expect(journal.IsStatementComplete(table)).To(BeFalse())
+		Expect(journal.FilterRestoredTables("20170101010101", []toc.CoordinatorDataEntry{{Oid: 1}})).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,214 @@
+package restore
+
+/*
+ * This file contains structs and functions related to listing the entries of
+ * a backup with --list, and to restoring only the entries left uncommented in
+ * such a listing with --use-list.
+ *
+ * Entries are numbered from 1 in the order in which they are restored: the
+ * global, predata, data, postdata and statistics entries of the TOC, with the
+ * data of each table listed once, in the order of the restore plan, as the
+ * data of an incremental backup comes from more than one TOC.  The numbering
+ * only depends on the backup, so a listing stays valid for as long as the
+ * backup exists.
+ */
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/history"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+var listSections = []string{"global", "predata", "data", "postdata", "statistics"}
+
+type ListEntry struct {
+	Section    string
+	ObjectType string
+	Schema     string
+	Name       string
+}
+
+func (entry ListEntry) String() string {
+	name := entry.Name
+	if entry.Schema != "" {
+		name = utils.MakeFQN(entry.Schema, entry.Name)
+	}
+	if name == "" {
+		name = "-"
+	}
+	return fmt.Sprintf("%s %s %s", entry.Section, entry.ObjectType, name)
+}
+
+func metadataEntriesForSection(tocfile *toc.TOC, section string) *[]toc.MetadataEntry {
+	switch section {
+	case "global":
+		return &tocfile.GlobalEntries
+	case "predata":
+		return &tocfile.PredataEntries
+	case "postdata":
+		return &tocfile.PostdataEntries
+	case "statistics":
+		return &tocfile.StatisticsEntries
+	}
+	return nil
+}
+
+func GetListEntries(tocfile *toc.TOC, restorePlan []history.RestorePlanEntry) []ListEntry {
+	listEntries := make([]ListEntry, 0)
+	for _, section := range listSections {
+		if section == "data" {
+			for _, planEntry := range restorePlan {
+				for _, fqn := range planEntry.TableFQNs {
+					listEntries = append(listEntries, ListEntry{Section: section, ObjectType: "TABLE DATA", Name: fqn})
+				}
+			}
+			continue
+		}
+		for _, entry := range *metadataEntriesForSection(tocfile, section) {
+			listEntries = append(listEntries, ListEntry{Section: section, ObjectType: entry.ObjectType, Schema: entry.Schema, Name: entry.Name})
+		}
+	}
+	return listEntries
+}
+
+func PrintListing() {
+	listEntries := GetListEntries(globalTOC, backupConfig.RestorePlan)
+	fmt.Println(";")
+	fmt.Printf("; Backup timestamp: %s\n", globalFPInfo.Timestamp)
+	fmt.Printf("; Database: %s\n", backupConfig.DatabaseName)
+	fmt.Printf("; Entries: %d\n", len(listEntries))
+	fmt.Println(";")
+	fmt.Printf("; Comment out an entry with a leading ';' to leave it out of a restore with --%s\n", options.USE_LIST)
+	fmt.Println(";")
+	for i, entry := range listEntries {
+		fmt.Printf("%d; %s\n", i+1, entry)
+	}
+}
+
+/*
+ * Returns the numbers of the entries listed in the file in the order they are
+ * listed, once each, ignoring blank lines and lines commented out with a
+ * leading semicolon.  Only the number before the first semicolon of a line is
+ * read, so the rest of the line can be left as --list printed it.
+ */
+func ReadListFile(filename string, numEntries int) ([]int, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	listed := make([]int, 0)
+	seen := make(map[int]bool)
+	for i, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		numberStr := line
+		if end := strings.IndexByte(line, ';'); end != -1 {
+			numberStr = line[:end]
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(numberStr))
+		if err != nil {
+			return nil, errors.Errorf("Line %d of list file %s does not start with an entry number: %s", i+1, filename, line)
+		}
+		if number < 1 || number > numEntries {
+			return nil, errors.Errorf("Line %d of list file %s refers to entry %d, but the backup has entries 1 to %d", i+1, filename, number, numEntries)
+		}
+		if !seen[number] {
+			seen[number] = true
+			listed = append(listed, number)
+		}
+	}
+	return listed, nil
+}
+
+/*
+ * Removes the entries that are not listed from the TOC, and returns the
+ * position in the listing of each table whose data is listed.  The TOCs of
+ * the other backups in the restore plan are read separately during the data
+ * restore, so their data entries are filtered and ordered on the returned
+ * positions instead.
+ *
+ * The sections are always restored in the order of the backup, but within
+ * the data, postdata and statistics sections the kept entries are restored in
+ * the order of the listing.  Global and predata entries create the objects
+ * that the entries after them depend on, so a listing that reorders them is
+ * rejected rather than restored in an order other than the one it asks for.
+ */
+func KeepListedEntries(tocfile *toc.TOC, restorePlan []history.RestorePlanEntry, listedNumbers []int) (map[string]int, error) {
+	number := 0
+	listedTableOrder := make(map[string]int)
+	for _, section := range listSections {
+		firstNumber := number + 1
+		var entries *[]toc.MetadataEntry
+		tableFQNs := make([]string, 0)
+		if section == "data" {
+			for _, planEntry := range restorePlan {
+				tableFQNs = append(tableFQNs, planEntry.TableFQNs...)
+			}
+			number += len(tableFQNs)
+		} else {
+			entries = metadataEntriesForSection(tocfile, section)
+			number += len(*entries)
+		}
+		keptNumbers := make([]int, 0)
+		for _, listedNumber := range listedNumbers {
+			if listedNumber >= firstNumber && listedNumber <= number {
+				keptNumbers = append(keptNumbers, listedNumber)
+			}
+		}
+		if !slices.IsSorted(keptNumbers) && (section == "global" || section == "predata") {
+			return nil, errors.Errorf("%s entries are not listed in the order printed by --%s, but only data, postdata and statistics entries can be reordered, as %s entries must be restored after the objects they depend on", section, options.LIST, section)
+		}
+		if section == "data" {
+			for _, keptNumber := range keptNumbers {
+				listedTableOrder[tableFQNs[keptNumber-firstNumber]] = len(listedTableOrder)
+			}
+			continue
+		}
+		keptEntries := make([]toc.MetadataEntry, 0)
+		for _, keptNumber := range keptNumbers {
+			keptEntries = append(keptEntries, (*entries)[keptNumber-firstNumber])
+		}
+		*entries = keptEntries
+	}
+	tocfile.DataEntries = filterListedDataEntries(tocfile.DataEntries, listedTableOrder)
+	return listedTableOrder, nil
+}
+
+func filterListedDataEntries(entries []toc.CoordinatorDataEntry, listedTableOrder map[string]int) []toc.CoordinatorDataEntry {
+	if listedTableOrder == nil {
+		return entries
+	}
+	listedEntries := make([]toc.CoordinatorDataEntry, 0)
+	for _, entry := range entries {
+		if _, ok := listedTableOrder[utils.MakeFQN(entry.Schema, entry.Name)]; ok {
+			listedEntries = append(listedEntries, entry)
+		}
+	}
+	slices.SortStableFunc(listedEntries, func(entry1 toc.CoordinatorDataEntry, entry2 toc.CoordinatorDataEntry) int {
+		return listedTableOrder[utils.MakeFQN(entry1.Schema, entry1.Name)] - listedTableOrder[utils.MakeFQN(entry2.Schema, entry2.Name)]
+	})
+	return listedEntries
+}
+
+func initializeUseList() {
+	listFile := MustGetFlagString(options.USE_LIST)
+	numEntries := len(GetListEntries(globalTOC, backupConfig.RestorePlan))
+	listed, err := ReadListFile(listFile, numEntries)
+	gplog.FatalOnError(err)
+	listedTableOrder, err = KeepListedEntries(globalTOC, backupConfig.RestorePlan, listed)
+	if err != nil {
+		gplog.Fatal(errors.Wrapf(err, "Cannot restore the entries listed in %s", listFile), "")
+	}
+	gplog.Info("Restoring %d of the %d entries in the backup, as listed in %s", len(listed), numEntries, listFile)
+}
@@ -0,0 +1,124 @@
+package restore_test
+
+import (
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/history"
+	"github.com/cloudberrydb/gpbackup/restore"
+	"github.com/cloudberrydb/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/list tests", func() {
+	var tocfile *toc.TOC
+	var restorePlan []history.RestorePlanEntry
+	BeforeEach(func() {
+		tocfile = &toc.TOC{
+			GlobalEntries: []toc.MetadataEntry{{Name: "testrole", ObjectType: "ROLE"}},
+			PredataEntries: []toc.MetadataEntry{
+				{Schema: "public", Name: "public", ObjectType: "SCHEMA"},
+				{Schema: "public", Name: "foo", ObjectType: "TABLE"},
+				{Schema: "public", Name: "myview", ObjectType: "VIEW"},
+			},
+			PostdataEntries: []toc.MetadataEntry{{Schema: "public", Name: "foo_idx", ObjectType: "INDEX", ReferenceObject: "public.foo"}},
+			DataEntries: []toc.CoordinatorDataEntry{
+				{Schema: "public", Name: "foo", Oid: 1},
+				{Schema: "public", Name: "bar", Oid: 2},
+			},
+		}
+		restorePlan = []history.RestorePlanEntry{
+			{Timestamp: "20170101010101", TableFQNs: []string{"public.baz"}},
+			{Timestamp: "20170102010101", TableFQNs: []string{"public.foo", "public.bar"}},
+		}
+	})
+	AfterEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+	})
+	Describe("GetListEntries", func() {
+		It("lists the entries of each section in restore order", func() {
+			listEntries := restore.GetListEntries(tocfile, restorePlan)
+
+			listing := make([]string, 0)
+			for _, entry := range listEntries {
+				listing = append(listing, entry.String())
+			}
+			Expect(listing).To(Equal([]string{
+				"global ROLE testrole",
+				"predata SCHEMA public.public",
+				"predata TABLE public.foo",
+				"predata VIEW public.myview",
+				"data TABLE DATA public.baz",
+				"data TABLE DATA public.foo",
+				"data TABLE DATA public.bar",
+				"postdata INDEX public.foo_idx",
+			}))
+		})
+	})
+	Describe("ReadListFile", func() {
+		It("reads the entry numbers of the lines that are not commented out", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte(";\n; Entries: 8\n;\n1; global ROLE testrole\n;2; predata SCHEMA public.public\n\n  4; predata VIEW public.myview\n6\n"), nil
+			}
+			listed, err := restore.ReadListFile("/tmp/list", 8)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(listed).To(Equal([]int{1, 4, 6}))
+		})
+		It("keeps the order of the listing and lists each entry once", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte("6\n1; global ROLE testrole\n6\n4; predata VIEW public.myview\n"), nil
+			}
+			listed, err := restore.ReadListFile("/tmp/list", 8)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(listed).To(Equal([]int{6, 1, 4}))
+		})
+		It("returns an error for a line without an entry number", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte("1; global ROLE testrole\npredata VIEW public.myview\n"), nil
+			}
+			_, err := restore.ReadListFile("/tmp/list", 8)
+			Expect(err).To(MatchError("Line 2 of list file /tmp/list does not start with an entry number: predata VIEW public.myview"))
+		})
+		It("returns an error for an entry number that is not in the backup", func() {
+			operating.System.ReadFile = func(string) ([]byte, error) {
+				return []byte("9; postdata INDEX public.foo_idx\n"), nil
+			}
+			_, err := restore.ReadListFile("/tmp/list", 8)
+			Expect(err).To(MatchError("Line 1 of list file /tmp/list refers to entry 9, but the backup has entries 1 to 8"))
+		})
+	})
+	Describe("KeepListedEntries", func() {
+		It("keeps only the listed metadata entries and table data", func() {
+			listedTableOrder, err := restore.KeepListedEntries(tocfile, restorePlan, []int{8, 3, 6})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tocfile.GlobalEntries).To(BeEmpty())
+			Expect(tocfile.PredataEntries).To(Equal([]toc.MetadataEntry{{Schema: "public", Name: "foo", ObjectType: "TABLE"}}))
+			Expect(tocfile.PostdataEntries).To(Equal([]toc.MetadataEntry{{Schema: "public", Name: "foo_idx", ObjectType: "INDEX", ReferenceObject: "public.foo"}}))
+			Expect(tocfile.DataEntries).To(Equal([]toc.CoordinatorDataEntry{{Schema: "public", Name: "foo", Oid: 1}}))
+			Expect(listedTableOrder).To(Equal(map[string]int{"public.foo": 0}))
+		})
+		It("keeps the data and postdata entries in the order of the listing", func() {
+			tocfile.PostdataEntries = append(tocfile.PostdataEntries, toc.MetadataEntry{Schema: "public", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "public.bar"})
+
+			listedTableOrder, err := restore.KeepListedEntries(tocfile, restorePlan, []int{9, 2, 7, 8, 6})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tocfile.PredataEntries).To(Equal([]toc.MetadataEntry{{Schema: "public", Name: "public", ObjectType: "SCHEMA"}}))
+			Expect(tocfile.PostdataEntries).To(Equal([]toc.MetadataEntry{
+				{Schema: "public", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "public.bar"},
+				{Schema: "public", Name: "foo_idx", ObjectType: "INDEX", ReferenceObject: "public.foo"},
+			}))
+			Expect(tocfile.DataEntries).To(Equal([]toc.CoordinatorDataEntry{
+				{Schema: "public", Name: "bar", Oid: 2},
+				{Schema: "public", Name: "foo", Oid: 1},
+			}))
+			Expect(listedTableOrder).To(Equal(map[string]int{"public.bar": 0, "public.foo": 1}))
+		})
+		It("returns an error when predata entries are listed out of order", func() {
+			_, err := restore.KeepListedEntries(tocfile, restorePlan, []int{4, 2})
+
+			Expect(err).To(MatchError("predata entries are not listed in the order printed by --list, but only data, postdata and statistics entries can be reordered, as predata entries must be restored after the objects they depend on"))
+		})
+	})
+})
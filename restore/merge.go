@@ -0,0 +1,189 @@
+package restore
+
+/*
+ * This file contains functions related to restoring data into existing
+ * tables with --on-conflict, which merges the rows of the backup into the
+ * rows of the table rather than appending them.
+ *
+ * The data of each table is copied into a temporary staging table, which is
+ * then merged into the table in a single transaction, matching rows on the
+ * primary key of the table in the backup: with "update", matching rows whose
+ * other columns differ are updated, and with "skip", matching rows are left
+ * as they are.  Rows without a match are inserted in either case.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gpbackup/filepath"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/report"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	ON_CONFLICT_UPDATE = "update"
+	ON_CONFLICT_SKIP   = "skip"
+)
+
+/*
+ * Returns the columns of the primary key of each table, keyed by the
+ * fully-qualified name of the table, from its constraint statements.
+ */
+func GetPrimaryKeys(constraintStatements []toc.StatementWithType) map[string][]string {
+	primaryKeys := make(map[string][]string)
+	for _, statement := range constraintStatements {
+		if statement.ObjectType != "CONSTRAINT" {
+			continue
+		}
+		command := newSQLCommand(tokenizeSQL(statement.Statement))
+		primary := command.findKeyword(0, "PRIMARY")
+		if primary == -1 || !command.isKeyword(primary+1, "KEY") {
+			continue
+		}
+		if columns := command.parenthesizedNames(primary + 2); len(columns) > 0 {
+			primaryKeys[statement.ReferenceObject] = columns
+		}
+	}
+	return primaryKeys
+}
+
+// Returns the comma-separated names in the parentheses starting at the word at index start
+func (command sqlCommand) parenthesizedNames(start int) []string {
+	if start >= len(command.words) || command.tokens[command.words[start]].text != "(" {
+		return nil
+	}
+	names := make([]string, 0)
+	for i := start + 1; i < len(command.words); i += 2 {
+		token := command.tokens[command.words[i]]
+		if token.kind != tokenWord {
+			return nil
+		}
+		names = append(names, token.text)
+		if i+1 < len(command.words) && command.tokens[command.words[i+1]].text == ")" {
+			return names
+		}
+		if i+1 >= len(command.words) || command.tokens[command.words[i+1]].text != "," {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Returns the columns in an attribute string such as "(i,j)"
+func columnsFromAttributeString(attributeString string) []string {
+	return newSQLCommand(tokenizeSQL(attributeString)).parenthesizedNames(0)
+}
+
+func initializePrimaryKeys(metadataFilename string) {
+	statements := GetRestoreMetadataStatements("predata", metadataFilename, []string{"CONSTRAINT"}, []string{})
+	primaryKeys = GetPrimaryKeys(statements)
+}
+
+func restoreSingleTableDataWithMerge(fpInfo *filepath.FilePathInfo, entry toc.CoordinatorDataEntry, tableName string, whichConn int, origSize int, destSize int) error {
+	keyColumns, ok := primaryKeys[utils.MakeFQN(entry.Schema, entry.Name)]
+	if !ok && entry.PartitionRoot != "" {
+		// Leaf partitions share the primary key of their root
+		keyColumns, ok = primaryKeys[utils.MakeFQN(entry.Schema, entry.PartitionRoot)]
+	}
+	if !ok {
+		return errors.Errorf("Table %s has no primary key in the backup, so its data cannot be restored with --%s", tableName, options.ON_CONFLICT)
+	}
+	columns := columnsFromAttributeString(entry.AttributeString)
+	if len(columns) == 0 {
+		return errors.Errorf("Unable to determine the columns of table %s to restore with --%s", tableName, options.ON_CONFLICT)
+	}
+
+	stagingName := fmt.Sprintf("gprestore_staging_%d", entry.Oid)
+	_, err := connectionPool.Exec(fmt.Sprintf("CREATE TEMPORARY TABLE %s (LIKE %s)", stagingName, tableName), whichConn)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to create staging table for table %s", tableName)
+	}
+	defer func() {
+		_, _ = connectionPool.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", stagingName), whichConn)
+	}()
+	err = restoreSingleTableData(fpInfo, entry, stagingName, whichConn, origSize, destSize)
+	if err != nil {
+		return err
+	}
+	counts, err := MergeStagingTable(stagingName, tableName, columns, keyColumns, MustGetFlagString(options.ON_CONFLICT), whichConn)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to merge data into table %s", tableName)
+	}
+	reportTimings.SetMergeCounts(entry.Oid, counts)
+	gplog.Info("Merged data into table %s: %d rows inserted, %d rows updated, %d rows skipped", tableName, counts.RowsInserted, counts.RowsUpdated, counts.RowsSkipped)
+	return nil
+}
+
+/*
+ * Merges the rows of the staging table into the table in one transaction, so
+ * that readers see either none or all of the changes.  The table is locked in
+ * SHARE ROW EXCLUSIVE mode before the transaction takes its snapshot, so the
+ * applications using the table can still read it, but cannot change rows
+ * that the merge would then fail to update or would insert a second time.
+ */
+func MergeStagingTable(stagingName string, tableName string, columns []string, keyColumns []string, onConflict string, whichConn int) (report.MergeCounts, error) {
+	counts := report.MergeCounts{}
+	keySet := utils.NewSet(keyColumns)
+	keyConditions := make([]string, len(keyColumns))
+	for i, column := range keyColumns {
+		keyConditions[i] = fmt.Sprintf("t.%s = s.%s", column, column)
+	}
+	matchStr := strings.Join(keyConditions, " AND ")
+	setList := make([]string, 0)
+	targetList := make([]string, 0)
+	sourceList := make([]string, 0)
+	insertList := make([]string, len(columns))
+	for i, column := range columns {
+		insertList[i] = fmt.Sprintf("s.%s", column)
+		if !keySet.MatchesFilter(column) {
+			setList = append(setList, fmt.Sprintf("%s = s.%s", column, column))
+			targetList = append(targetList, fmt.Sprintf("t.%s", column))
+			sourceList = append(sourceList, fmt.Sprintf("s.%s", column))
+		}
+	}
+
+	var numStaged int64
+	err := connectionPool.Get(&numStaged, fmt.Sprintf("SELECT count(*) FROM %s", stagingName), whichConn)
+	if err != nil {
+		return counts, err
+	}
+	err = connectionPool.Begin(whichConn)
+	if err != nil {
+		return counts, err
+	}
+	_, err = connectionPool.Exec(fmt.Sprintf("LOCK TABLE %s IN SHARE ROW EXCLUSIVE MODE", tableName), whichConn)
+	if err == nil && onConflict == ON_CONFLICT_UPDATE && len(setList) > 0 {
+		query := fmt.Sprintf("UPDATE %s t SET %s FROM %s s WHERE %s AND (%s) IS DISTINCT FROM (%s)",
+			tableName, strings.Join(setList, ", "), stagingName, matchStr, strings.Join(targetList, ", "), strings.Join(sourceList, ", "))
+		counts.RowsUpdated, err = execAndCountRows(query, whichConn)
+	}
+	if err == nil {
+		query := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s s WHERE NOT EXISTS (SELECT 1 FROM %s t WHERE %s)",
+			tableName, strings.Join(columns, ", "), strings.Join(insertList, ", "), stagingName, tableName, matchStr)
+		counts.RowsInserted, err = execAndCountRows(query, whichConn)
+	}
+	if err != nil {
+		_ = connectionPool.Rollback(whichConn)
+		return report.MergeCounts{}, err
+	}
+	err = connectionPool.Commit(whichConn)
+	if err != nil {
+		return report.MergeCounts{}, err
+	}
+	counts.RowsSkipped = numStaged - counts.RowsInserted - counts.RowsUpdated
+	return counts, nil
+}
+
+func execAndCountRows(query string, whichConn int) (int64, error) {
+	gplog.Verbose(`Executing "%s" on coordinator`, query)
+	result, err := connectionPool.Exec(query, whichConn)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
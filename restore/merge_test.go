@@ -0,0 +1,76 @@
+package restore_test
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gpbackup/report"
+	"github.com/cloudberrydb/gpbackup/restore"
+	"github.com/cloudberrydb/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/merge tests", func() {
+	Describe("GetPrimaryKeys", func() {
+		It("returns the primary key columns of each table", func() {
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "foo_pkey", ObjectType: "CONSTRAINT", ReferenceObject: "public.foo", Statement: "\n\nALTER TABLE ONLY public.foo ADD CONSTRAINT foo_pkey PRIMARY KEY (i, \"Key, 2\");\n"},
+				{Schema: "public", Name: "foo_j_check", ObjectType: "CONSTRAINT", ReferenceObject: "public.foo", Statement: "\n\nALTER TABLE public.foo ADD CONSTRAINT foo_j_check CHECK (j > 0);\n"},
+				{Schema: "public", Name: "bar_j_key", ObjectType: "CONSTRAINT", ReferenceObject: "public.bar", Statement: "\n\nALTER TABLE ONLY public.bar ADD CONSTRAINT bar_j_key UNIQUE (j);\n"},
+				{Schema: "public", Name: "baz_pkey", ObjectType: "CONSTRAINT", ReferenceObject: "public.baz", Statement: "\n\nALTER TABLE ONLY public.baz ADD CONSTRAINT baz_pkey PRIMARY KEY (id);\n"},
+			}
+
+			primaryKeys := restore.GetPrimaryKeys(statements)
+
+			Expect(primaryKeys).To(Equal(map[string][]string{
+				"public.foo": {"i", `"Key, 2"`},
+				"public.baz": {"id"},
+			}))
+		})
+	})
+	Describe("MergeStagingTable", func() {
+		BeforeEach(func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM gprestore_staging_1")).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+			mock.ExpectBegin()
+			mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL SERIALIZABLE").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("LOCK TABLE public.foo IN SHARE ROW EXCLUSIVE MODE")).WillReturnResult(sqlmock.NewResult(0, 0))
+		})
+		It("updates the rows that changed and inserts the new rows", func() {
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE public.foo t SET j = s.j, k = s.k FROM gprestore_staging_1 s WHERE t.i = s.i AND (t.j, t.k) IS DISTINCT FROM (s.j, s.k)")).
+				WillReturnResult(sqlmock.NewResult(0, 3))
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO public.foo (i, j, k) SELECT s.i, s.j, s.k FROM gprestore_staging_1 s WHERE NOT EXISTS (SELECT 1 FROM public.foo t WHERE t.i = s.i)")).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			mock.ExpectCommit()
+
+			counts, err := restore.MergeStagingTable("gprestore_staging_1", "public.foo", []string{"i", "j", "k"}, []string{"i"}, restore.ON_CONFLICT_UPDATE, 0)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(counts).To(Equal(report.MergeCounts{RowsInserted: 2, RowsUpdated: 3, RowsSkipped: 5}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("only inserts the new rows when skipping conflicts", func() {
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO public.foo (i, j, k) SELECT s.i, s.j, s.k FROM gprestore_staging_1 s WHERE NOT EXISTS (SELECT 1 FROM public.foo t WHERE t.i = s.i AND t.j = s.j)")).
+				WillReturnResult(sqlmock.NewResult(0, 4))
+			mock.ExpectCommit()
+
+			counts, err := restore.MergeStagingTable("gprestore_staging_1", "public.foo", []string{"i", "j", "k"}, []string{"i", "j"}, restore.ON_CONFLICT_SKIP, 0)
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(counts).To(Equal(report.MergeCounts{RowsInserted: 4, RowsUpdated: 0, RowsSkipped: 6}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back the merge when it fails", func() {
+			mock.ExpectExec("UPDATE public.foo").WillReturnError(errors.New("could not update"))
+			mock.ExpectRollback()
+
+			_, err := restore.MergeStagingTable("gprestore_staging_1", "public.foo", []string{"i", "j"}, []string{"i"}, restore.ON_CONFLICT_UPDATE, 0)
+
+			Expect(err).To(MatchError("could not update"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,337 @@
+package restore
+
+/*
+ * This file contains structs and functions related to restoring metadata into
+ * a cluster that does not have the roles and tablespaces of the backed-up
+ * cluster, given by --role-map and --tablespace-map, and to leaving out the
+ * ownership and privileges of restored objects, given by --no-owner and
+ * --no-privileges.
+ *
+ * Each statement in the TOC is split into its SQL commands, using the same
+ * scanning as remap.go so that literals, dollar-quoted bodies and comments
+ * are skipped.  Roles are only mapped where the syntax of a command calls for
+ * a role, such as after OWNER TO or in the grantee list of a GRANT, and
+ * tablespaces only after the TABLESPACE keyword, so that an object with the
+ * same name as a role or tablespace is left alone.
+ */
+
+import (
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
+)
+
+type MetadataEditor struct {
+	roles        map[string]string
+	tablespaces  map[string]string
+	noOwner      bool
+	noPrivileges bool
+}
+
+func NewMetadataEditor(roles map[string]string, tablespaces map[string]string, noOwner bool, noPrivileges bool) *MetadataEditor {
+	return &MetadataEditor{
+		roles:        roles,
+		tablespaces:  tablespaces,
+		noOwner:      noOwner,
+		noPrivileges: noPrivileges,
+	}
+}
+
+func initializeMetadataEditor() {
+	var roles, tablespaces map[string]string
+	var err error
+	if roleMapFile := MustGetFlagString(options.ROLE_MAP_FILE); roleMapFile != "" {
+		roles, err = utils.ReadRoleMapFile(roleMapFile)
+		gplog.FatalOnError(err)
+	}
+	if tablespaceMapFile := MustGetFlagString(options.TABLESPACE_MAP_FILE); tablespaceMapFile != "" {
+		tablespaces, err = utils.ReadTablespaceMapFile(tablespaceMapFile)
+		gplog.FatalOnError(err)
+	}
+	metadataEditor = NewMetadataEditor(roles, tablespaces, MustGetFlagBool(options.NO_OWNER), MustGetFlagBool(options.NO_PRIVILEGES))
+}
+
+/*
+ * Returns the statements with their roles and tablespaces mapped and their
+ * ownership and privilege commands removed, as requested.  Statements that
+ * create or alter a mapped role or tablespace are left out entirely, as the
+ * role or tablespace it is mapped to is expected to exist already, and so are
+ * statements that have no commands left.  A nil MetadataEditor edits nothing.
+ */
+func (editor *MetadataEditor) EditStatements(statements []toc.StatementWithType) []toc.StatementWithType {
+	if editor == nil {
+		return statements
+	}
+	edited := make([]toc.StatementWithType, 0, len(statements))
+	for _, statement := range statements {
+		if editor.isMappedObject(statement) {
+			gplog.Verbose("Skipping %s %s, which is mapped to an existing %s", strings.ToLower(statement.ObjectType), statement.Name, strings.ToLower(statement.ObjectType))
+			continue
+		}
+		statement.Statement = editor.editCommands(statement.Statement)
+		if strings.TrimSpace(statement.Statement) == "" {
+			continue
+		}
+		edited = append(edited, statement)
+	}
+	return edited
+}
+
+func (editor *MetadataEditor) isMappedObject(statement toc.StatementWithType) bool {
+	switch statement.ObjectType {
+	case "ROLE", "ROLE GUCS":
+		_, ok := editor.roles[statement.Name]
+		return ok
+	case "TABLESPACE":
+		_, ok := editor.tablespaces[statement.Name]
+		return ok
+	}
+	return false
+}
+
+const (
+	tokenSpace = iota // whitespace and comments
+	tokenWord         // quoted and unquoted identifiers, including keywords
+	tokenOther        // literals, numbers and punctuation
+)
+
+type sqlToken struct {
+	text string
+	kind int
+}
+
+func tokenizeSQL(sql string) []sqlToken {
+	tokens := make([]sqlToken, 0)
+	for i := 0; i < len(sql); {
+		end := i + 1
+		kind := tokenOther
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			end = strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				end = len(sql)
+			} else {
+				end += i
+			}
+			kind = tokenSpace
+		case strings.HasPrefix(sql[i:], "/*"):
+			end = scanBlockComment(sql, i)
+			kind = tokenSpace
+		case sql[i] == ' ' || sql[i] == '\t' || sql[i] == '\n' || sql[i] == '\r':
+			for end < len(sql) && strings.IndexByte(" \t\n\r", sql[end]) != -1 {
+				end++
+			}
+			kind = tokenSpace
+		case sql[i] == '\'':
+			end = scanLiteral(sql, i, false)
+		case sql[i] == '$':
+			if tag := dollarQuoteRegex.FindString(sql[i:]); tag != "" {
+				closing := strings.Index(sql[i+len(tag):], tag)
+				if closing == -1 {
+					end = len(sql)
+				} else {
+					end = i + len(tag) + closing + len(tag)
+				}
+			}
+		case sql[i] == '"' || isIdentifierStart(sql[i]):
+			end = scanIdentifier(sql, i)
+			kind = tokenWord
+			if (sql[i:end] == "E" || sql[i:end] == "e") && end < len(sql) && sql[end] == '\'' {
+				end = scanLiteral(sql, end, true)
+				kind = tokenOther
+			}
+		}
+		tokens = append(tokens, sqlToken{text: sql[i:end], kind: kind})
+		i = end
+	}
+	return tokens
+}
+
+/*
+ * Splits the tokens into SQL commands at the semicolons outside parentheses.
+ * Each command starts with the whitespace that precedes it, so that leaving a
+ * command out leaves no blank lines behind.
+ */
+func splitCommands(tokens []sqlToken) [][]sqlToken {
+	commands := make([][]sqlToken, 0)
+	start := 0
+	depth := 0
+	for i, token := range tokens {
+		switch {
+		case token.kind != tokenOther:
+		case token.text == "(":
+			depth++
+		case token.text == ")":
+			depth--
+		case token.text == ";" && depth <= 0:
+			commands = append(commands, tokens[start:i+1])
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		commands = append(commands, tokens[start:])
+	}
+	return commands
+}
+
+/*
+ * A command is kept as its tokens, along with the indexes of the tokens that
+ * are not whitespace, so that keywords can be matched regardless of spacing.
+ */
+type sqlCommand struct {
+	tokens []sqlToken
+	words  []int
+}
+
+func newSQLCommand(tokens []sqlToken) sqlCommand {
+	command := sqlCommand{tokens: tokens, words: make([]int, 0)}
+	for i, token := range tokens {
+		if token.kind != tokenSpace {
+			command.words = append(command.words, i)
+		}
+	}
+	return command
+}
+
+// Returns whether the word at index i of the command is the given unquoted keyword
+func (command sqlCommand) isKeyword(i int, keywords ...string) bool {
+	if i < 0 || i >= len(command.words) {
+		return false
+	}
+	token := command.tokens[command.words[i]]
+	if token.kind != tokenWord {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(token.text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the index of the first word at or after start that is the given keyword, or -1
+func (command sqlCommand) findKeyword(start int, keywords ...string) int {
+	for i := start; i < len(command.words); i++ {
+		if command.isKeyword(i, keywords...) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the index of the GRANT or REVOKE keyword of a privilege command, or -1
+func (command sqlCommand) grantIndex() int {
+	if command.isKeyword(0, "GRANT", "REVOKE") {
+		return 0
+	}
+	if command.isKeyword(0, "ALTER") && command.isKeyword(1, "DEFAULT") && command.isKeyword(2, "PRIVILEGES") {
+		return command.findKeyword(3, "GRANT", "REVOKE")
+	}
+	return -1
+}
+
+func (command sqlCommand) isOwnerCommand() bool {
+	if !command.isKeyword(0, "ALTER") {
+		return false
+	}
+	owner := command.findKeyword(1, "OWNER")
+	return owner != -1 && command.isKeyword(owner+1, "TO")
+}
+
+/*
+ * Privileges on objects are granted with GRANT ... ON, while a GRANT without
+ * ON grants membership in a role, which is kept with --no-privileges.
+ */
+func (command sqlCommand) isPrivilegeCommand() bool {
+	grant := command.grantIndex()
+	return grant > 0 || (grant == 0 && command.findKeyword(1, "ON") != -1)
+}
+
+func (editor *MetadataEditor) editCommands(sql string) string {
+	var edited strings.Builder
+	for _, tokens := range splitCommands(tokenizeSQL(sql)) {
+		command := newSQLCommand(tokens)
+		if (editor.noOwner && command.isOwnerCommand()) || (editor.noPrivileges && command.isPrivilegeCommand()) {
+			continue
+		}
+		editor.mapTablespaces(command)
+		editor.mapRoles(command)
+		for _, token := range command.tokens {
+			edited.WriteString(token.text)
+		}
+	}
+	return edited.String()
+}
+
+// Replaces the word at index i of the command if it is a name in nameMap
+func (command sqlCommand) mapName(i int, nameMap map[string]string) {
+	if i < 0 || i >= len(command.words) {
+		return
+	}
+	token := &command.tokens[command.words[i]]
+	if newName, ok := nameMap[token.text]; ok && token.kind == tokenWord {
+		token.text = newName
+	}
+}
+
+// Replaces the names in the comma-separated list of names starting at index start
+func (command sqlCommand) mapNameList(start int, nameMap map[string]string) {
+	for i := start; i < len(command.words); i += 2 {
+		command.mapName(i, nameMap)
+		if i+1 >= len(command.words) || command.tokens[command.words[i+1]].text != "," {
+			return
+		}
+	}
+}
+
+func (editor *MetadataEditor) mapTablespaces(command sqlCommand) {
+	if len(editor.tablespaces) == 0 {
+		return
+	}
+	for i := range command.words {
+		if command.isKeyword(i, "TABLESPACE") {
+			command.mapName(i+1, editor.tablespaces)
+		}
+	}
+}
+
+func (editor *MetadataEditor) mapRoles(command sqlCommand) {
+	if len(editor.roles) == 0 {
+		return
+	}
+	for i := range command.words {
+		switch {
+		case command.isKeyword(i, "OWNER") && command.isKeyword(i+1, "TO"):
+			command.mapName(i+2, editor.roles)
+		case command.isKeyword(i, "FOR") && command.isKeyword(i+1, "ROLE", "USER"):
+			command.mapNameList(i+2, editor.roles)
+		case command.isKeyword(i, "GRANTED") && command.isKeyword(i+1, "BY"):
+			command.mapName(i+2, editor.roles)
+		}
+	}
+	if command.isKeyword(0, "CREATE") && command.isKeyword(1, "USER") && command.isKeyword(2, "MAPPING") && command.isKeyword(3, "FOR") {
+		command.mapName(4, editor.roles)
+	}
+	if command.isKeyword(0, "CREATE") && command.isKeyword(1, "POLICY") {
+		if to := command.findKeyword(2, "TO"); to != -1 {
+			command.mapNameList(to+1, editor.roles)
+		}
+	}
+	if grant := command.grantIndex(); grant != -1 {
+		if !command.isPrivilegeCommand() {
+			// The roles whose membership is granted
+			command.mapNameList(grant+1, editor.roles)
+		}
+		grantee := "TO"
+		if command.isKeyword(grant, "REVOKE") {
+			grantee = "FROM"
+		}
+		if to := command.findKeyword(grant+1, grantee); to != -1 {
+			command.mapNameList(to+1, editor.roles)
+		}
+	}
+}
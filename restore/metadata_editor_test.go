@@ -0,0 +1,92 @@
+package restore_test
+
+import (
+	"github.com/cloudberrydb/gpbackup/restore"
+	"github.com/cloudberrydb/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/metadata_editor tests", func() {
+	roles := map[string]string{"prod_owner": "staging_owner", `"Prod Reader"`: "staging_reader"}
+	tablespaces := map[string]string{"fast_ssd": "pg_default"}
+	Describe("EditStatements", func() {
+		It("maps roles where a role is expected", func() {
+			editor := restore.NewMetadataEditor(roles, nil, false, false)
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "prod_owner", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.prod_owner (\n\tprod_owner integer\n) DISTRIBUTED BY (prod_owner);\n"},
+				{Schema: "public", Name: "prod_owner", ObjectType: "TABLE", Statement: "\n\nREVOKE ALL ON TABLE public.prod_owner FROM PUBLIC;\nREVOKE ALL ON TABLE public.prod_owner FROM prod_owner;\nGRANT SELECT ON TABLE public.prod_owner TO \"Prod Reader\", other;\nGRANT ALL ON TABLE public.prod_owner TO prod_owner WITH GRANT OPTION;\n"},
+				{Schema: "", Name: "plpythonu", ObjectType: "LANGUAGE", Statement: "\n\nCREATE PROCEDURAL LANGUAGE plpythonu;\nALTER FUNCTION pg_catalog.plpython_call_handler() OWNER TO prod_owner;\n"},
+				{Schema: "", Name: "", ObjectType: "DEFAULT PRIVILEGES", Statement: "\n\nALTER DEFAULT PRIVILEGES FOR ROLE prod_owner IN SCHEMA public GRANT SELECT ON TABLES TO \"Prod Reader\";\n"},
+				{Schema: "", Name: "other", ObjectType: "ROLE GRANT", Statement: "\nGRANT prod_owner TO other WITH ADMIN OPTION GRANTED BY prod_owner;"},
+				{Schema: "", Name: "prod_owner", ObjectType: "USER MAPPING", Statement: "\n\nCREATE USER MAPPING FOR prod_owner\n\tSERVER myserver;\n"},
+			}
+
+			statements = editor.EditStatements(statements)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{
+				{Schema: "public", Name: "prod_owner", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.prod_owner (\n\tprod_owner integer\n) DISTRIBUTED BY (prod_owner);\n"},
+				{Schema: "public", Name: "prod_owner", ObjectType: "TABLE", Statement: "\n\nREVOKE ALL ON TABLE public.prod_owner FROM PUBLIC;\nREVOKE ALL ON TABLE public.prod_owner FROM staging_owner;\nGRANT SELECT ON TABLE public.prod_owner TO staging_reader, other;\nGRANT ALL ON TABLE public.prod_owner TO staging_owner WITH GRANT OPTION;\n"},
+				{Schema: "", Name: "plpythonu", ObjectType: "LANGUAGE", Statement: "\n\nCREATE PROCEDURAL LANGUAGE plpythonu;\nALTER FUNCTION pg_catalog.plpython_call_handler() OWNER TO staging_owner;\n"},
+				{Schema: "", Name: "", ObjectType: "DEFAULT PRIVILEGES", Statement: "\n\nALTER DEFAULT PRIVILEGES FOR ROLE staging_owner IN SCHEMA public GRANT SELECT ON TABLES TO staging_reader;\n"},
+				{Schema: "", Name: "other", ObjectType: "ROLE GRANT", Statement: "\nGRANT staging_owner TO other WITH ADMIN OPTION GRANTED BY staging_owner;"},
+				{Schema: "", Name: "prod_owner", ObjectType: "USER MAPPING", Statement: "\n\nCREATE USER MAPPING FOR staging_owner\n\tSERVER myserver;\n"},
+			}))
+		})
+		It("maps the tablespaces of tables, indexes and databases", func() {
+			editor := restore.NewMetadataEditor(nil, tablespaces, false, false)
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "t", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.t (\n\ti integer DEFAULT 'TABLESPACE fast_ssd'\n) TABLESPACE fast_ssd DISTRIBUTED BY (i);\n"},
+				{Schema: "public", Name: "t_idx", ObjectType: "INDEX", Statement: "\n\nCREATE INDEX t_idx ON public.t USING btree (i);\nALTER INDEX public.t_idx SET TABLESPACE fast_ssd;\n"},
+				{Schema: "", Name: "db", ObjectType: "DATABASE", Statement: "\n\nCREATE DATABASE db TEMPLATE template0 TABLESPACE fast_ssd;"},
+			}
+
+			statements = editor.EditStatements(statements)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{
+				{Schema: "public", Name: "t", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.t (\n\ti integer DEFAULT 'TABLESPACE fast_ssd'\n) TABLESPACE pg_default DISTRIBUTED BY (i);\n"},
+				{Schema: "public", Name: "t_idx", ObjectType: "INDEX", Statement: "\n\nCREATE INDEX t_idx ON public.t USING btree (i);\nALTER INDEX public.t_idx SET TABLESPACE pg_default;\n"},
+				{Schema: "", Name: "db", ObjectType: "DATABASE", Statement: "\n\nCREATE DATABASE db TEMPLATE template0 TABLESPACE pg_default;"},
+			}))
+		})
+		It("leaves out the global statements of mapped roles and tablespaces", func() {
+			editor := restore.NewMetadataEditor(roles, tablespaces, false, false)
+			statements := []toc.StatementWithType{
+				{Name: "prod_owner", ObjectType: "ROLE", Statement: "\n\nCREATE ROLE prod_owner;\nALTER ROLE prod_owner WITH LOGIN;"},
+				{Name: "prod_owner", ObjectType: "ROLE GUCS", Statement: "\n\nALTER ROLE prod_owner SET search_path TO public;"},
+				{Name: "other", ObjectType: "ROLE", Statement: "\n\nCREATE ROLE other;\nALTER ROLE other WITH LOGIN;"},
+				{Name: "fast_ssd", ObjectType: "TABLESPACE", Statement: "\n\nCREATE TABLESPACE fast_ssd LOCATION '/data/ssd';"},
+			}
+
+			statements = editor.EditStatements(statements)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{
+				{Name: "other", ObjectType: "ROLE", Statement: "\n\nCREATE ROLE other;\nALTER ROLE other WITH LOGIN;"},
+			}))
+		})
+		It("leaves out ownership and privilege commands", func() {
+			editor := restore.NewMetadataEditor(nil, nil, true, true)
+			statements := []toc.StatementWithType{
+				{Schema: "", Name: "plpythonu", ObjectType: "LANGUAGE", Statement: "\n\nCREATE PROCEDURAL LANGUAGE plpythonu;\nALTER FUNCTION pg_catalog.plpython_call_handler() OWNER TO prod_owner;\n"},
+				{Schema: "public", Name: "t", ObjectType: "TABLE", Statement: "\n\nREVOKE ALL ON TABLE public.t FROM PUBLIC;\nGRANT SELECT ON TABLE public.t TO other;\n"},
+				{Schema: "", Name: "", ObjectType: "DEFAULT PRIVILEGES", Statement: "\n\nALTER DEFAULT PRIVILEGES FOR ROLE prod_owner GRANT SELECT ON TABLES TO other;\n"},
+				{Schema: "public", Name: "f", ObjectType: "FUNCTION", Statement: "\n\nCREATE FUNCTION public.f() RETURNS void AS $$GRANT ALL ON TABLE public.t TO other;$$ LANGUAGE sql;\n"},
+				{Schema: "", Name: "other", ObjectType: "ROLE GRANT", Statement: "\nGRANT prod_owner TO other;"},
+			}
+
+			statements = editor.EditStatements(statements)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{
+				{Schema: "", Name: "plpythonu", ObjectType: "LANGUAGE", Statement: "\n\nCREATE PROCEDURAL LANGUAGE plpythonu;\n"},
+				{Schema: "public", Name: "f", ObjectType: "FUNCTION", Statement: "\n\nCREATE FUNCTION public.f() RETURNS void AS $$GRANT ALL ON TABLE public.t TO other;$$ LANGUAGE sql;\n"},
+				{Schema: "", Name: "other", ObjectType: "ROLE GRANT", Statement: "\nGRANT prod_owner TO other;"},
+			}))
+		})
+		It("edits nothing without an editor", func() {
+			var noEditor *restore.MetadataEditor
+			statements := []toc.StatementWithType{{Schema: "public", Name: "t", ObjectType: "TABLE", Statement: "\n\nGRANT ALL ON TABLE public.t TO prod_owner;\n"}}
+			Expect(noEditor.EditStatements(statements)).To(Equal(statements))
+		})
+	})
+})
@@ -25,8 +25,14 @@ func executeStatementsForConn(statements chan toc.StatementWithType, fatalErr *e
 		if wasTerminated || *fatalErr != nil {
 			return
 		}
+		if restoreJournal.IsStatementComplete(statement) {
+			progressBar.Increment()
+			continue
+		}
 		_, err := connectionPool.Exec(statement.Statement, whichConn)
-		if err != nil {
+		if err == nil {
+			restoreJournal.RecordStatement(statement)
+		} else {
 			gplog.Verbose("Error encountered when executing statement: %s Error was: %s", strings.TrimSpace(statement.Statement), err.Error())
 			if MustGetFlagBool(options.ON_ERROR_CONTINUE) {
 				if executeInParallel {
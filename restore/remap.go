@@ -0,0 +1,250 @@
+package restore
+
+/*
+ * This file contains structs and functions related to restoring the schemas
+ * and tables of a backup under other names, given by --remap-file.
+ *
+ * Statements are remapped by scanning them for identifiers, skipping over
+ * string literals, dollar-quoted bodies and comments, rather than by replacing
+ * text, so that a schema whose name is a prefix of another schema's name is
+ * left alone.  Schema-qualified names, the names following the SCHEMA keyword,
+ * and the names in string literals cast to regclass or passed to setval are
+ * remapped; names in function bodies and unqualified names are not.
+ */
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
+)
+
+type Remapper struct {
+	schemas map[string]string
+	tables  map[string]options.FqnStruct
+}
+
+func NewRemapper(remapConfig utils.RemapConfig) (*Remapper, error) {
+	remapper := &Remapper{
+		schemas: make(map[string]string, len(remapConfig.Schemas)),
+		tables:  make(map[string]options.FqnStruct, len(remapConfig.Tables)),
+	}
+	for oldSchema, newSchema := range remapConfig.Schemas {
+		remapper.schemas[oldSchema] = newSchema
+	}
+	for oldFQN, newFQN := range remapConfig.Tables {
+		fqns, err := options.SeparateSchemaAndTable([]string{oldFQN, newFQN})
+		if err != nil {
+			return nil, err
+		}
+		remapper.tables[utils.MakeFQN(fqns[0].SchemaName, fqns[0].TableName)] = fqns[1]
+	}
+	return remapper, nil
+}
+
+func initializeRemapper() {
+	remapConfig, err := utils.ReadRemapFile(MustGetFlagString(options.REMAP_FILE))
+	gplog.FatalOnError(err)
+	remapper, err = NewRemapper(remapConfig)
+	gplog.FatalOnError(err)
+}
+
+/*
+ * Returns the schema and name under which the given object is restored.  A
+ * table remapped by name takes precedence over the remapping of its schema.
+ * A nil Remapper remaps nothing.
+ */
+func (remapper *Remapper) TableName(schema string, name string) (string, string) {
+	if remapper == nil {
+		return schema, name
+	}
+	if newTable, ok := remapper.tables[utils.MakeFQN(schema, name)]; ok {
+		return newTable.SchemaName, newTable.TableName
+	}
+	if newSchema, ok := remapper.schemas[schema]; ok {
+		return newSchema, name
+	}
+	return schema, name
+}
+
+func (remapper *Remapper) FQN(fqn string) string {
+	if remapper == nil {
+		return fqn
+	}
+	return remapper.remapNames(fqn)
+}
+
+func (remapper *Remapper) EditStatements(statements []toc.StatementWithType) {
+	if remapper == nil {
+		return
+	}
+	for i, statement := range statements {
+		if statement.ObjectType == "SCHEMA" {
+			if newSchema, ok := remapper.schemas[statement.Name]; ok {
+				statements[i].Schema = newSchema
+				statements[i].Name = newSchema
+			}
+		} else {
+			statements[i].Schema, statements[i].Name = remapper.TableName(statement.Schema, statement.Name)
+		}
+		statements[i].ReferenceObject = remapper.remapNames(statement.ReferenceObject)
+		statements[i].Statement = remapper.remapNames(statement.Statement)
+	}
+}
+
+var dollarQuoteRegex = regexp.MustCompile(`^\$([A-Za-z_\x80-\xff][A-Za-z0-9_\x80-\xff]*)?\$`)
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || c == '$' || (c >= '0' && c <= '9')
+}
+
+// Returns the end of the quoted or unquoted identifier starting at start
+func scanIdentifier(sql string, start int) int {
+	if sql[start] == '"' {
+		for i := start + 1; i < len(sql); i++ {
+			if sql[i] == '"' {
+				if i+1 < len(sql) && sql[i+1] == '"' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+		return len(sql)
+	}
+	end := start
+	for end < len(sql) && isIdentifierChar(sql[end]) {
+		end++
+	}
+	return end
+}
+
+// Returns the end of the string literal whose opening quote is at start
+func scanLiteral(sql string, start int, backslashEscapes bool) int {
+	for i := start + 1; i < len(sql); i++ {
+		switch {
+		case backslashEscapes && sql[i] == '\\':
+			i++
+		case sql[i] == '\'':
+			if i+1 < len(sql) && sql[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// Returns the end of the block comment starting at start, which may be nested
+func scanBlockComment(sql string, start int) int {
+	depth := 0
+	for i := start; i < len(sql)-1; i++ {
+		if sql[i] == '/' && sql[i+1] == '*' {
+			depth++
+			i++
+		} else if sql[i] == '*' && sql[i+1] == '/' {
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(sql)
+}
+
+func (remapper *Remapper) remapNames(sql string) string {
+	var remapped strings.Builder
+	afterSchemaKeyword := false
+	for i := 0; i < len(sql); {
+		end := i + 1
+		token := sql[i:end]
+		isSchemaKeyword := false
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			end = strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				end = len(sql)
+			} else {
+				end += i
+			}
+			token = sql[i:end]
+		case strings.HasPrefix(sql[i:], "/*"):
+			end = scanBlockComment(sql, i)
+			token = sql[i:end]
+		case sql[i] == '\'':
+			end = scanLiteral(sql, i, false)
+			token = sql[i:end]
+			if strings.HasPrefix(sql[end:], "::regclass") || strings.HasSuffix(sql[:i], "setval(") {
+				token = remapper.remapLiteral(token)
+			}
+		case sql[i] == '$' && (i == 0 || !isIdentifierChar(sql[i-1])):
+			if tag := dollarQuoteRegex.FindString(sql[i:]); tag != "" {
+				closing := strings.Index(sql[i+len(tag):], tag)
+				if closing == -1 {
+					end = len(sql)
+				} else {
+					end = i + len(tag) + closing + len(tag)
+				}
+				token = sql[i:end]
+			}
+		case sql[i] == '"' || isIdentifierStart(sql[i]):
+			end = scanIdentifier(sql, i)
+			token = sql[i:end]
+			// A name qualified by something other than a schema, such as a column by its table, is left alone
+			isQualified := i > 0 && sql[i-1] == '.'
+			switch {
+			case (token == "E" || token == "e") && end < len(sql) && sql[end] == '\'':
+				end = scanLiteral(sql, end, true)
+				token = sql[i:end]
+			case !isQualified && end+1 < len(sql) && sql[end] == '.' && (sql[end+1] == '"' || isIdentifierStart(sql[end+1])):
+				nameEnd := scanIdentifier(sql, end+1)
+				schema, name := remapper.TableName(token, sql[end+1:nameEnd])
+				token = utils.MakeFQN(schema, name)
+				end = nameEnd
+			case !isQualified:
+				if newSchema, ok := remapper.schemas[token]; ok && afterSchemaKeyword {
+					token = newSchema
+				}
+				isSchemaKeyword = strings.EqualFold(token, "SCHEMA")
+			}
+		case sql[i] == ' ' || sql[i] == '\t' || sql[i] == '\n' || sql[i] == '\r':
+			isSchemaKeyword = afterSchemaKeyword
+		}
+		remapped.WriteString(token)
+		afterSchemaKeyword = isSchemaKeyword
+		i = end
+	}
+	return remapped.String()
+}
+
+/*
+ * Remaps the name in a string literal such as 'public.foo'::regclass, which
+ * is itself written as a possibly quoted identifier.
+ */
+func (remapper *Remapper) remapLiteral(literal string) string {
+	if len(literal) < 2 || literal[len(literal)-1] != '\'' {
+		return literal
+	}
+	name := strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+	if name == "" || !(name[0] == '"' || isIdentifierStart(name[0])) {
+		return literal
+	}
+	schemaEnd := scanIdentifier(name, 0)
+	if schemaEnd+1 >= len(name) || name[schemaEnd] != '.' {
+		return literal
+	}
+	if scanIdentifier(name, schemaEnd+1) != len(name) {
+		return literal
+	}
+	schema, table := remapper.TableName(name[:schemaEnd], name[schemaEnd+1:])
+	return "'" + utils.EscapeSingleQuotes(utils.MakeFQN(schema, table)) + "'"
+}
@@ -0,0 +1,122 @@
+package restore_test
+
+import (
+	"github.com/cloudberrydb/gpbackup/restore"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/remap tests", func() {
+	var remapper *restore.Remapper
+	BeforeEach(func() {
+		var err error
+		remapper, err = restore.NewRemapper(utils.RemapConfig{
+			Schemas: map[string]string{"foo": "foo_new", `"Foo"`: "upper_foo"},
+			Tables:  map[string]string{"foo.bar": "staging.bar_copy"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+	Describe("TableName", func() {
+		It("remaps a table by name before remapping its schema", func() {
+			schema, name := remapper.TableName("foo", "bar")
+			Expect([]string{schema, name}).To(Equal([]string{"staging", "bar_copy"}))
+			schema, name = remapper.TableName("foo", "baz")
+			Expect([]string{schema, name}).To(Equal([]string{"foo_new", "baz"}))
+			schema, name = remapper.TableName("foobar", "baz")
+			Expect([]string{schema, name}).To(Equal([]string{"foobar", "baz"}))
+		})
+		It("remaps nothing without a remapper", func() {
+			var noRemapper *restore.Remapper
+			schema, name := noRemapper.TableName("foo", "bar")
+			Expect([]string{schema, name}).To(Equal([]string{"foo", "bar"}))
+		})
+	})
+	Describe("EditStatements", func() {
+		It("remaps schema-qualified names without corrupting schemas with the same prefix", func() {
+			statements := []toc.StatementWithType{
+				{
+					Schema: "foo", Name: "myview", ObjectType: "VIEW",
+					Statement: "\n\nCREATE VIEW foo.myview AS  SELECT bar.i, foobar.j\n   FROM foo.bar, foobar.foo;\n",
+				},
+				{
+					Schema: `"Foo"`, Name: "t", ObjectType: "TABLE",
+					Statement: "\n\nCREATE TABLE \"Foo\".t (\n\ti foo.mytype DEFAULT nextval('foo.seq'::regclass),\n\tj text DEFAULT 'foo.bar'\n) DISTRIBUTED BY (i);\n",
+				},
+			}
+
+			remapper.EditStatements(statements)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{
+				{
+					Schema: "foo_new", Name: "myview", ObjectType: "VIEW",
+					Statement: "\n\nCREATE VIEW foo_new.myview AS  SELECT bar.i, foobar.j\n   FROM staging.bar_copy, foobar.foo;\n",
+				},
+				{
+					Schema: "upper_foo", Name: "t", ObjectType: "TABLE",
+					Statement: "\n\nCREATE TABLE upper_foo.t (\n\ti foo_new.mytype DEFAULT nextval('foo_new.seq'::regclass),\n\tj text DEFAULT 'foo.bar'\n) DISTRIBUTED BY (i);\n",
+				},
+			}))
+		})
+		It("remaps the schema and table of postdata and statistics statements", func() {
+			statements := []toc.StatementWithType{
+				{
+					Schema: "foo", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "foo.bar",
+					Statement: "\n\nCREATE INDEX bar_idx ON foo.bar USING btree (i);\n",
+				},
+				{
+					Schema: "foo", Name: "bar", ObjectType: "STATISTICS",
+					Statement: "\n\nUPDATE pg_class\nSET\n\trelpages = 1::int,\n\treltuples = 1.000000::real\nWHERE oid = 'foo.bar'::regclass::oid;\n",
+				},
+			}
+
+			remapper.EditStatements(statements)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{
+				{
+					Schema: "foo_new", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "staging.bar_copy",
+					Statement: "\n\nCREATE INDEX bar_idx ON staging.bar_copy USING btree (i);\n",
+				},
+				{
+					Schema: "staging", Name: "bar_copy", ObjectType: "STATISTICS",
+					Statement: "\n\nUPDATE pg_class\nSET\n\trelpages = 1::int,\n\treltuples = 1.000000::real\nWHERE oid = 'staging.bar_copy'::regclass::oid;\n",
+				},
+			}))
+		})
+		It("remaps schema statements and sequence values", func() {
+			statements := []toc.StatementWithType{
+				{
+					Schema: "foo", Name: "foo", ObjectType: "SCHEMA",
+					Statement: "\n\nCREATE SCHEMA foo;\n\nCOMMENT ON SCHEMA foo IS 'SCHEMA foo';\n\nGRANT ALL ON SCHEMA foo TO testrole;\n",
+				},
+				{
+					Schema: "foo", Name: "seq", ObjectType: "SEQUENCE",
+					Statement: "\n\nCREATE SEQUENCE foo.seq;\n\nSELECT pg_catalog.setval('foo.seq', 3, true);\n",
+				},
+			}
+
+			remapper.EditStatements(statements)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{
+				{
+					Schema: "foo_new", Name: "foo_new", ObjectType: "SCHEMA",
+					Statement: "\n\nCREATE SCHEMA foo_new;\n\nCOMMENT ON SCHEMA foo_new IS 'SCHEMA foo';\n\nGRANT ALL ON SCHEMA foo_new TO testrole;\n",
+				},
+				{
+					Schema: "foo_new", Name: "seq", ObjectType: "SEQUENCE",
+					Statement: "\n\nCREATE SEQUENCE foo_new.seq;\n\nSELECT pg_catalog.setval('foo_new.seq', 3, true);\n",
+				},
+			}))
+		})
+		It("does not remap names in function bodies or comments", func() {
+			statement := "\n\nCREATE FUNCTION foo.f() RETURNS integer\n    AS $$SELECT count(*) FROM foo.bar$$\n    LANGUAGE sql; -- foo.bar\n"
+			statements := []toc.StatementWithType{{Schema: "foo", Name: "f", ObjectType: "FUNCTION", Statement: statement}}
+
+			remapper.EditStatements(statements)
+
+			Expect(statements[0].Statement).To(Equal("\n\nCREATE FUNCTION foo_new.f() RETURNS integer\n    AS $$SELECT count(*) FROM foo.bar$$\n    LANGUAGE sql; -- foo.bar\n"))
+		})
+	})
+})
@@ -8,7 +8,11 @@ import (
 	"github.com/cloudberrydb/gp-common-go-libs/cluster"
 	"github.com/cloudberrydb/gp-common-go-libs/gplog"
 	"github.com/cloudberrydb/gp-common-go-libs/iohelper"
+	"github.com/cloudberrydb/gpbackup/filepath"
+	"github.com/cloudberrydb/gpbackup/history"
 	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
 	"github.com/pkg/errors"
 )
 
@@ -49,6 +53,9 @@ func VerifyBackupFileCountOnSegments() {
 	fileCount := 2 // 1 for the actual data file, 1 for the segment TOC file
 	if !backupConfig.SingleDataFile {
 		fileCount = len(globalTOC.DataEntries)
+		if backupConfig.WithChecksums {
+			fileCount *= 2 // each data file has a checksum file alongside it
+		}
 	}
 
 	origSize, destSize, isResizeRestore := GetResizeClusterInfo()
@@ -73,6 +80,120 @@ func VerifyBackupFileCountOnSegments() {
 	}
 }
 
+/*
+ * Checks every data file of each backup in the restore plan against the
+ * checksum recorded by gpbackup --verify.  Each segment prints one line per
+ * file that is missing, is missing its checksum or does not match it, so an
+ * empty stdout means all of the segment's files are intact.  The data files
+ * expected on each segment are those of the tables in the backup's TOC, so a
+ * deleted data file is reported rather than overlooked.
+ */
+func VerifyDataFileChecksums() {
+	numFailed := 0
+	numUnverifiable := 0
+	for _, fpInfo := range GetBackupFPInfoListFromRestorePlan() {
+		if reason := GetUnverifiableReason(fpInfo); reason != "" {
+			gplog.Warn("Backup %s is unverifiable: %s", fpInfo.Timestamp, reason)
+			numUnverifiable++
+			continue
+		}
+		oids := make([]uint32, 0)
+		if !backupConfig.SingleDataFile {
+			tocfile := globalTOC
+			if fpInfo.Timestamp != globalFPInfo.Timestamp {
+				tocfile = toc.NewTOC(fpInfo.GetTOCFilePath())
+			}
+			for _, entry := range tocfile.DataEntries {
+				oids = append(oids, entry.Oid)
+			}
+		}
+		remoteOutput := globalCluster.GenerateAndExecuteCommand(fmt.Sprintf("Verifying data file checksums for backup %s", fpInfo.Timestamp), cluster.ON_SEGMENTS, func(contentID int) string {
+			return ConstructChecksumVerificationCommand(fpInfo, contentID, oids)
+		})
+		globalCluster.CheckClusterError(remoteOutput, "Unable to verify data file checksums", func(contentID int) string {
+			return fmt.Sprintf("Unable to verify data file checksums for backup %s", fpInfo.Timestamp)
+		})
+		for _, cmd := range remoteOutput.Commands {
+			for _, line := range strings.Split(strings.TrimSpace(cmd.Stdout), "\n") {
+				if line != "" {
+					gplog.Error("%s on segment %d on host %s", line, cmd.Content, cmd.Host)
+					numFailed++
+				}
+			}
+		}
+	}
+	if numFailed > 0 {
+		gplog.Fatal(errors.Errorf("%d data file(s) failed checksum verification", numFailed), "")
+	}
+	if numUnverifiable > 0 {
+		gplog.Warn("The data files of %d backup(s) in the restore plan were not verified", numUnverifiable)
+		gplog.Info("All verified data files match their recorded checksums")
+	} else {
+		gplog.Info("All data files match their recorded checksums")
+	}
+}
+
+/*
+ * Returns why the data files of a backup in the restore plan cannot be
+ * verified, or an empty string if they can.  The earlier backups of an
+ * incremental restore plan may have been taken without checksums, as only
+ * the backup being restored is required to have them.
+ */
+func GetUnverifiableReason(fpInfo filepath.FilePathInfo) string {
+	if MustGetFlagString(options.PLUGIN_CONFIG) != "" && !backupConfig.SingleDataFile {
+		return "the per-table data files of a backup taken with a plugin cannot be listed for verification"
+	}
+	if fpInfo.Timestamp == globalFPInfo.Timestamp {
+		return ""
+	}
+	configFile := fpInfo.GetConfigFilePath()
+	if !iohelper.FileExistsAndIsReadable(configFile) {
+		return fmt.Sprintf("its configuration file %s is not available", configFile)
+	}
+	if !history.ReadConfigFile(configFile).WithChecksums {
+		return fmt.Sprintf("it was taken without --%s", options.VERIFY)
+	}
+	tocFile := fpInfo.GetTOCFilePath()
+	if !backupConfig.SingleDataFile && !iohelper.FileExistsAndIsReadable(tocFile) {
+		return fmt.Sprintf("its table of contents file %s is not available", tocFile)
+	}
+	return ""
+}
+
+/*
+ * Returns the command checking the data files of a backup on a segment.  For
+ * a backup with a data file per table, the files are those of the tables with
+ * the given oids.
+ */
+func ConstructChecksumVerificationCommand(fpInfo filepath.FilePathInfo, contentID int, oids []uint32) string {
+	if backupConfig.SingleDataFile {
+		dataFile := fpInfo.GetTableBackupFilePath(contentID, 0, utils.GetPipeThroughProgram().Extension, true)
+		readCommand := fmt.Sprintf("cat %s", dataFile)
+		if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
+			readCommand = fmt.Sprintf("%s restore_data %s %s", pluginConfig.ExecutablePath, pluginConfig.ConfigPath, dataFile)
+		} else if backupConfig.EncryptionFingerprint != "" {
+			// The segment TOC is encrypted, so the checksum is read from the file written alongside the data file
+			return fmt.Sprintf(`if [[ ! -f "%[1]s%[2]s" ]]; then echo "No checksum recorded for %[1]s"; `+
+				`elif ! sha256sum < "%[1]s" | cmp -s - "%[1]s%[2]s"; then echo "Checksum mismatch for %[1]s"; fi`,
+				dataFile, utils.ChecksumFileExtension)
+		}
+		return fmt.Sprintf(`expected=$(sed -n 's/^checksum: *//p' %s); actual=$(%s | sha256sum | cut -d' ' -f1); `+
+			`if [[ -z "$expected" ]]; then echo "No checksum recorded for %[3]s"; `+
+			`elif [[ "$expected" != "$actual" ]]; then echo "Checksum mismatch for %[3]s"; fi`,
+			fpInfo.GetSegmentTOCFilePath(contentID), readCommand, dataFile)
+	}
+	oidStrs := make([]string, len(oids))
+	for i, oid := range oids {
+		oidStrs[i] = strconv.FormatUint(uint64(oid), 10)
+	}
+	dataFileTemplate := fmt.Sprintf("%s/gpbackup_%d_%s_${oid}%s", fpInfo.GetDirForContent(contentID), contentID, fpInfo.Timestamp, utils.GetPipeThroughProgram().Extension)
+	return fmt.Sprintf(`for oid in %s; do f="%s"; `+
+		`if [[ ! -f "$f" ]]; then echo "Missing data file $f"; `+
+		`elif [[ ! -f "$f%[3]s" ]]; then echo "No checksum recorded for $f"; `+
+		`elif ! sha256sum < "$f" | cmp -s - "$f%[3]s"; then echo "Checksum mismatch for $f"; fi; done`,
+		strings.Join(oidStrs, " "), dataFileTemplate, utils.ChecksumFileExtension)
+}
+
 func VerifyMetadataFilePaths(withStats bool) {
 	filetypes := []string{"config", "table of contents", "metadata"}
 	missing := false
@@ -116,5 +116,91 @@ var _ = Describe("restore/remote tests", func() {
 			// Expect is implied, does not need to be explicitly called here
 			restore.VerifyBackupFileCountOnSegments()
 		})
+		It("counts a checksum file for every data file when the backup has checksums", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				Commands: []cluster.ShellCommand{
+					cluster.ShellCommand{Stdout: "4"},
+					cluster.ShellCommand{Stdout: "4"},
+				},
+			}
+			restore.SetBackupConfig(&history.BackupConfig{SingleDataFile: false, WithChecksums: true})
+			testCluster.Executor = testExecutor
+			restore.SetCluster(testCluster)
+			restore.VerifyBackupFileCountOnSegments()
+		})
+	})
+	Describe("VerifyDataFileChecksums", func() {
+		BeforeEach(func() {
+			restore.SetBackupConfig(&history.BackupConfig{WithChecksums: true, RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170101010101"}}})
+			restore.SetCluster(testCluster)
+		})
+		It("succeeds when no segment reports a bad data file", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				Commands: []cluster.ShellCommand{
+					cluster.ShellCommand{Content: 0, Stdout: ""},
+					cluster.ShellCommand{Content: 1, Stdout: "\n"},
+				},
+			}
+			restore.VerifyDataFileChecksums()
+			Expect((*testExecutor).NumExecutions).To(Equal(1))
+		})
+		It("panics with the number of bad data files across all segments", func() {
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{
+				Commands: []cluster.ShellCommand{
+					cluster.ShellCommand{Content: 0, Stdout: "Checksum mismatch for /data/gpseg0/backups/20170101/20170101010101/gpbackup_0_20170101010101_1234\n"},
+					cluster.ShellCommand{Content: 1, Stdout: "Checksum mismatch for /data/gpseg1/backups/20170101/20170101010101/gpbackup_1_20170101010101_1234\nNo checksum recorded for /data/gpseg1/backups/20170101/20170101010101/gpbackup_1_20170101010101_2345\n"},
+				},
+			}
+			defer testhelper.ShouldPanicWithMessage("3 data file(s) failed checksum verification")
+			restore.VerifyDataFileChecksums()
+		})
+		It("compares the data file of each table in the TOC with its checksum file", func() {
+			restore.SetTOC(&toc.TOC{DataEntries: []toc.CoordinatorDataEntry{{Schema: "public", Name: "foo", Oid: 1234}, {Schema: "public", Name: "bar", Oid: 2345}}})
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+			restore.VerifyDataFileChecksums()
+
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc[0].CommandString).To(ContainSubstring(`for oid in 1234 2345; do f="/data/gpseg0/backups/20170101/20170101010101/gpbackup_0_20170101010101_${oid}`))
+			Expect(cc[0].CommandString).To(ContainSubstring(`if [[ ! -f "$f" ]]; then echo "Missing data file $f"`))
+			Expect(cc[0].CommandString).To(ContainSubstring(`sha256sum < "$f" | cmp -s - "$f.sha256"`))
+		})
+		It("skips the earlier backups of the restore plan that cannot be verified", func() {
+			restore.SetBackupConfig(&history.BackupConfig{WithChecksums: true, RestorePlan: []history.RestorePlanEntry{{Timestamp: "20161231010101"}, {Timestamp: "20170101010101"}}})
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+			restore.VerifyDataFileChecksums()
+
+			Expect(testExecutor.ClusterCommands).To(HaveLen(1))
+			Expect(testExecutor.ClusterCommands[0][0].CommandString).To(ContainSubstring("gpbackup_0_20170101010101_${oid}"))
+		})
+		It("reports the per-table data files of a plugin backup as unverifiable", func() {
+			cmdFlags.Set(options.PLUGIN_CONFIG, "/tmp/plugin_config.yaml")
+			defer cmdFlags.Set(options.PLUGIN_CONFIG, "")
+
+			Expect(restore.GetUnverifiableReason(testFPInfo)).To(Equal("the per-table data files of a backup taken with a plugin cannot be listed for verification"))
+		})
+		It("reports an earlier backup of the restore plan without a configuration file as unverifiable", func() {
+			earlierFPInfo := filepath.NewFilePathInfo(testCluster, "", "20161231010101", "gpseg")
+
+			Expect(restore.GetUnverifiableReason(earlierFPInfo)).To(Equal("its configuration file /data/gpseg-1/backups/20161231/20161231010101/gpbackup_20161231010101_config.yaml is not available"))
+			Expect(restore.GetUnverifiableReason(testFPInfo)).To(BeEmpty())
+		})
+		It("compares a single data file with the checksum in its segment TOC", func() {
+			restore.SetBackupConfig(&history.BackupConfig{WithChecksums: true, SingleDataFile: true, RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170101010101"}}})
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+			restore.VerifyDataFileChecksums()
+
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc[0].CommandString).To(ContainSubstring(`expected=$(sed -n 's/^checksum: *//p' /data/gpseg0/backups/20170101/20170101010101/gpbackup_0_20170101010101_toc.yaml)`))
+			Expect(cc[0].CommandString).To(ContainSubstring(`actual=$(cat /data/gpseg0/backups/20170101/20170101010101/gpbackup_0_20170101010101 | sha256sum | cut -d' ' -f1)`))
+		})
+		It("compares an encrypted single data file with the checksum written alongside it", func() {
+			restore.SetBackupConfig(&history.BackupConfig{WithChecksums: true, SingleDataFile: true, EncryptionFingerprint: "0123456789abcdef", RestorePlan: []history.RestorePlanEntry{{Timestamp: "20170101010101"}}})
+			testExecutor.ClusterOutput = &cluster.RemoteOutput{}
+			restore.VerifyDataFileChecksums()
+
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc[0].CommandString).ToNot(ContainSubstring("toc.yaml"))
+			Expect(cc[0].CommandString).To(ContainSubstring(`sha256sum < "/data/gpseg0/backups/20170101/20170101010101/gpbackup_0_20170101010101" | cmp -s - "/data/gpseg0/backups/20170101/20170101010101/gpbackup_0_20170101010101.sha256"`))
+		})
 	})
 })
@@ -11,6 +11,7 @@ import (
 
 	"github.com/cloudberrydb/gp-common-go-libs/cluster"
 	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
 	"github.com/cloudberrydb/gpbackup/filepath"
 	"github.com/cloudberrydb/gpbackup/history"
 	"github.com/cloudberrydb/gpbackup/options"
@@ -29,6 +30,7 @@ func DoInit(cmd *cobra.Command) {
 	gplog.InitializeLogging("gprestore", "")
 	SetCmdFlags(cmd.Flags())
 	_ = cmd.MarkFlagRequired(options.TIMESTAMP)
+	reportTimings = report.NewTimings()
 	utils.InitializeSignalHandler(DoCleanup, "restore process", &wasTerminated)
 }
 
@@ -42,9 +44,32 @@ func DoValidation(cmd *cobra.Command) {
 	gplog.FatalOnError(err)
 	err = utils.ValidateFullPath(MustGetFlagString(options.PLUGIN_CONFIG))
 	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.ENCRYPTION_KEY_FILE))
+	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.REPORT_JSON_FILE))
+	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.METRICS_FILE))
+	gplog.FatalOnError(err)
+	err = utils.ValidateFullPath(MustGetFlagString(options.NOTIFICATION_CONFIG))
+	gplog.FatalOnError(err)
+	if MustGetFlagString(options.NOTIFICATION_CONFIG) != "" {
+		_, err = report.ReadNotificationConfig(MustGetFlagString(options.NOTIFICATION_CONFIG))
+		gplog.FatalOnError(err)
+	}
+	err = utils.ValidateFullPath(MustGetFlagString(options.HOOK_CONFIG))
+	gplog.FatalOnError(err)
 	if !filepath.IsValidTimestamp(MustGetFlagString(options.TIMESTAMP)) {
 		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", MustGetFlagString(options.TIMESTAMP)), "")
 	}
+	if MustGetFlagInt(options.COMPRESSION_WORKERS) < 1 {
+		gplog.Fatal(errors.Errorf("--compression-workers %d is invalid. Must be at least 1", MustGetFlagInt(options.COMPRESSION_WORKERS)), "")
+	}
+	if MustGetFlagString(options.RESUME) != "" && !filepath.IsValidTimestamp(MustGetFlagString(options.RESUME)) {
+		gplog.Fatal(errors.Errorf("Timestamp %s is invalid.  Timestamps must be in the format YYYYMMDDHHMMSS.", MustGetFlagString(options.RESUME)), "")
+	}
+	if onConflict := MustGetFlagString(options.ON_CONFLICT); onConflict != "" && onConflict != ON_CONFLICT_UPDATE && onConflict != ON_CONFLICT_SKIP {
+		gplog.Fatal(errors.Errorf("--on-conflict %s is invalid. Valid values are %s and %s", onConflict, ON_CONFLICT_UPDATE, ON_CONFLICT_SKIP), "")
+	}
 }
 
 // This function handles setup that must be done after parsing flags.
@@ -54,6 +79,9 @@ func DoSetup() {
 
 	utils.CheckGpexpandRunning(utils.RestorePreventedByGpexpandMessage)
 	restoreStartTime = history.CurrentTimestamp()
+	if MustGetFlagString(options.RESUME) != "" {
+		restoreStartTime = MustGetFlagString(options.RESUME)
+	}
 	backupTimestamp := MustGetFlagString(options.TIMESTAMP)
 	gplog.Info("Restore Key = %s", backupTimestamp)
 
@@ -70,11 +98,27 @@ func DoSetup() {
 	err = opts.QuoteExcludeRelations(connectionPool)
 	gplog.FatalOnError(err)
 
+	if MustGetFlagString(options.REMAP_FILE) != "" {
+		initializeRemapper()
+	}
+	if MustGetFlagString(options.ROLE_MAP_FILE) != "" || MustGetFlagString(options.TABLESPACE_MAP_FILE) != "" ||
+		MustGetFlagBool(options.NO_OWNER) || MustGetFlagBool(options.NO_PRIVILEGES) {
+		initializeMetadataEditor()
+	}
+
 	segConfig := cluster.MustGetSegmentConfiguration(connectionPool)
 	globalCluster = cluster.NewCluster(segConfig)
 	segPrefix, err = filepath.ParseSegPrefix(MustGetFlagString(options.BACKUP_DIR))
 	gplog.FatalOnError(err)
 	globalFPInfo = filepath.NewFilePathInfo(globalCluster, MustGetFlagString(options.BACKUP_DIR), backupTimestamp, segPrefix)
+	if MustGetFlagString(options.HOOK_CONFIG) != "" {
+		hookConfig, err = utils.ReadHookConfig(MustGetFlagString(options.HOOK_CONFIG))
+		gplog.FatalOnError(err)
+	}
+
+	if MustGetFlagString(options.ENCRYPTION_KEY_FILE) != "" {
+		initializeEncryption()
+	}
 
 	// Get restore metadata from plugin
 	if MustGetFlagString(options.PLUGIN_CONFIG) != "" {
@@ -88,8 +132,32 @@ func DoSetup() {
 	gplog.Info("gpbackup version = %s", backupConfig.BackupVersion)
 	gplog.Info("gprestore version = %s", GetVersion())
 	gplog.Info("Database Version = %s", connectionPool.Version.VersionString)
+	if len(backupConfig.MaskingRules) > 0 {
+		gplog.Warn("Backup %s was taken with masking rules, so the data of its masked columns is not the original data", backupTimestamp)
+	}
+	if backupConfig.Sampled() {
+		gplog.Warn("Backup %s is a %g%% sample of the rows of each table", backupTimestamp, backupConfig.SamplePercent)
+	}
 
 	BackupConfigurationValidation()
+	if MustGetFlagBool(options.VERIFY_ONLY) {
+		// Nothing will be restored, so the restore database need not exist
+		if !backupConfig.WithChecksums {
+			gplog.Fatal(errors.Errorf("Backup %s was not taken with --%s, so it has no checksums to verify", backupTimestamp, options.VERIFY), "")
+		}
+		return
+	}
+	if MustGetFlagBool(options.LIST) {
+		// Nothing will be restored, so the restore database need not exist
+		return
+	}
+	if MustGetFlagString(options.USE_LIST) != "" {
+		initializeUseList()
+	}
+	if MustGetFlagBool(options.SWAP) {
+		initializeSwapper()
+	}
+	initializeRestoreJournal()
 	metadataFilename := globalFPInfo.GetMetadataFilePath()
 	if !backupConfig.DataOnly {
 		gplog.Verbose("Metadata will be restored from %s", metadataFilename)
@@ -98,7 +166,13 @@ func DoSetup() {
 	if MustGetFlagString(options.REDIRECT_DB) != "" {
 		unquotedRestoreDatabase = MustGetFlagString(options.REDIRECT_DB)
 	}
-	ValidateDatabaseExistence(unquotedRestoreDatabase, MustGetFlagBool(options.CREATE_DB), backupConfig.IncludeTableFiltered || backupConfig.DataOnly)
+	isResumed := MustGetFlagString(options.RESUME) != ""
+	createDB := MustGetFlagBool(options.CREATE_DB)
+	if createDB && isResumed && DatabaseExists(unquotedRestoreDatabase) {
+		// The database was created before the restore failed
+		createDB = false
+	}
+	ValidateDatabaseExistence(unquotedRestoreDatabase, createDB, backupConfig.IncludeTableFiltered || backupConfig.DataOnly)
 	if MustGetFlagBool(options.WITH_GLOBALS) {
 		restoreGlobal(metadataFilename)
 	} else if MustGetFlagBool(options.CREATE_DB) {
@@ -115,7 +189,7 @@ func DoSetup() {
 	 * For on-error-continue, we will see the same errors later when we try to run SQL,
 	 * but since they will not stop the restore, it is not necessary to log them twice.
 	 */
-	if !MustGetFlagBool(options.CREATE_DB) && !MustGetFlagBool(options.ON_ERROR_CONTINUE) && !MustGetFlagBool(options.INCREMENTAL) {
+	if !MustGetFlagBool(options.CREATE_DB) && !MustGetFlagBool(options.ON_ERROR_CONTINUE) && !MustGetFlagBool(options.INCREMENTAL) && !isResumed {
 		relationsToRestore := GenerateRestoreRelationList(*opts)
 		if opts.RedirectSchema != "" {
 			fqns, err := options.SeparateSchemaAndTable(relationsToRestore)
@@ -126,8 +200,14 @@ func DoSetup() {
 			}
 			relationsToRestore = redirectRelationsToRestore
 		}
+		for i, relation := range relationsToRestore {
+			relationsToRestore[i] = remapper.FQN(relation)
+		}
 		ValidateRelationsInRestoreDatabase(connectionPool, relationsToRestore)
 	}
+	if swapper != nil {
+		gplog.FatalOnError(swapper.ValidateLiveTables(0))
+	}
 
 	if opts.RedirectSchema != "" {
 		ValidateRedirectSchema(connectionPool, opts.RedirectSchema)
@@ -141,6 +221,15 @@ func DoRestore() {
 	isMetadataOnly := backupConfig.MetadataOnly || MustGetFlagBool(options.METADATA_ONLY)
 	isIncremental := MustGetFlagBool(options.INCREMENTAL)
 
+	if MustGetFlagBool(options.VERIFY_ONLY) {
+		VerifyDataFileChecksums()
+		return
+	}
+	if MustGetFlagBool(options.LIST) {
+		PrintListing()
+		return
+	}
+
 	if isIncremental {
 		verifyIncrementalState()
 	}
@@ -168,9 +257,13 @@ func DoRestore() {
 
 	if MustGetFlagBool(options.WITH_STATS) && backupConfig.WithStatistics {
 		restoreStatistics()
-	} else if MustGetFlagBool(options.RUN_ANALYZE) && totalTablesRestored > 0 {
+	} else if (MustGetFlagBool(options.RUN_ANALYZE) || swapper != nil) && totalTablesRestored > 0 {
 		runAnalyze(filteredDataEntries)
 	}
+
+	if swapper != nil {
+		swapTables()
+	}
 }
 
 func createDatabase(metadataFilename string) {
@@ -198,12 +291,15 @@ func restoreGlobal(metadataFilename string) {
 		objectTypes = append(objectTypes, "DATABASE")
 	}
 	gplog.Info("Restoring global metadata")
+	runHooks("before", "globals")
+	defer reportTimings.StartSection("globals")()
 	statements := GetRestoreMetadataStatements("global", metadataFilename, objectTypes, []string{})
 	if MustGetFlagString(options.REDIRECT_DB) != "" {
 		quotedDBName := utils.QuoteIdent(connectionPool, MustGetFlagString(options.REDIRECT_DB))
 		statements = toc.SubstituteRedirectDatabaseInStatements(statements, backupConfig.DatabaseName, quotedDBName)
 	}
 	statements = toc.RemoveActiveRole(connectionPool.User, statements)
+	statements = metadataEditor.EditStatements(statements)
 	numErrors := ExecuteRestoreMetadataStatements(statements, "Global objects", nil, utils.PB_VERBOSE, false)
 
 	if numErrors > 0 {
@@ -211,6 +307,7 @@ func restoreGlobal(metadataFilename string) {
 	} else {
 		gplog.Info("Global database metadata restore complete")
 	}
+	runHooks("after", "globals")
 }
 
 func verifyIncrementalState() {
@@ -277,15 +374,26 @@ func restorePredata(metadataFilename string) {
 		return
 	}
 	gplog.Info("Restoring pre-data metadata")
+	runHooks("before", "predata")
+	defer reportTimings.StartSection("predata")()
 	// if not incremental restore - assume database is empty and just filter based on user input
 	filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
 	var schemaStatements []toc.StatementWithType
-	if opts.RedirectSchema == "" {
+	excludeObjectTypes := []string{"SCHEMA"}
+	if swapper != nil {
+		// The schemas and sequences of the live tables are used as they are
+		excludeObjectTypes = append(excludeObjectTypes, "SEQUENCE", "SEQUENCE OWNER")
+	} else if opts.RedirectSchema == "" {
 		schemaStatements = GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{"SCHEMA"}, []string{}, filters)
 	}
-	statements := GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{}, []string{"SCHEMA"}, filters)
+	statements := GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{}, excludeObjectTypes, filters)
 
 	editStatementsRedirectSchema(statements, opts.RedirectSchema)
+	swapper.EditStatements(statements)
+	remapper.EditStatements(schemaStatements)
+	remapper.EditStatements(statements)
+	schemaStatements = metadataEditor.EditStatements(schemaStatements)
+	statements = metadataEditor.EditStatements(statements)
 	progressBar := utils.NewProgressBar(len(schemaStatements)+len(statements), "Pre-data objects restored: ", utils.PB_VERBOSE)
 	progressBar.Start()
 
@@ -300,6 +408,7 @@ func restorePredata(metadataFilename string) {
 	} else {
 		gplog.Info("Pre-data metadata restore complete")
 	}
+	runHooks("after", "predata")
 }
 
 func restoreSequenceValues(metadataFilename string) {
@@ -314,6 +423,7 @@ func restoreSequenceValues(metadataFilename string) {
 	// Extract out the setval calls for each SEQUENCE object
 	var sequenceValueStatements []toc.StatementWithType
 	statements := GetRestoreMetadataStatementsFiltered("predata", metadataFilename, []string{"SEQUENCE"}, []string{}, filters)
+	remapper.EditStatements(statements)
 	re := regexp.MustCompile(`SELECT pg_catalog.setval\(.*`)
 	for _, statement := range statements {
 		matches := re.FindStringSubmatch(statement.Statement)
@@ -394,9 +504,16 @@ func restoreData() (int, map[string][]toc.CoordinatorDataEntry) {
 		restorePlanTableFQNs := entry.TableFQNs
 		filteredDataEntriesForTimestamp := tocfile.GetDataEntriesMatching(opts.IncludedSchemas,
 			opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations, restorePlanTableFQNs)
+		filteredDataEntriesForTimestamp = filterListedDataEntries(filteredDataEntriesForTimestamp, listedTableOrder)
 		filteredDataEntries[entry.Timestamp] = filteredDataEntriesForTimestamp
 		totalTables += len(filteredDataEntriesForTimestamp)
 	}
+	partialTables = GetPartialTables(filteredDataEntries, opts.RedirectSchema)
+	if MustGetFlagString(options.ON_CONFLICT) != "" {
+		initializePrimaryKeys(globalFPInfo.GetMetadataFilePath())
+	}
+	runHooks("before", "data")
+	defer reportTimings.StartSection("data")()
 	dataProgressBar := utils.NewProgressBar(totalTables, "Tables restored: ", utils.PB_INFO)
 	dataProgressBar.Start()
 
@@ -408,6 +525,13 @@ func restoreData() (int, map[string][]toc.CoordinatorDataEntry) {
 	}
 
 	dataProgressBar.Finish()
+	tableSizes := make(map[uint32]int64)
+	for _, entries := range filteredDataEntries {
+		for _, entry := range entries {
+			tableSizes[entry.Oid] = entry.EstimatedSize
+		}
+	}
+	reportTimings.SetEstimatedBytes(tableSizes)
 	if wasTerminated {
 		gplog.Info("Data restore incomplete")
 	} else if numErrors > 0 {
@@ -415,6 +539,7 @@ func restoreData() (int, map[string][]toc.CoordinatorDataEntry) {
 	} else {
 		gplog.Info("Data restore complete")
 	}
+	runHooks("after", "data")
 
 	return totalTables, filteredDataEntries
 }
@@ -424,11 +549,16 @@ func restorePostdata(metadataFilename string) {
 		return
 	}
 	gplog.Info("Restoring post-data metadata")
+	runHooks("before", "postdata")
+	defer reportTimings.StartSection("postdata")()
 
 	filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
 
 	statements := GetRestoreMetadataStatementsFiltered("postdata", metadataFilename, []string{}, []string{}, filters)
 	editStatementsRedirectSchema(statements, opts.RedirectSchema)
+	swapper.EditStatements(statements)
+	remapper.EditStatements(statements)
+	statements = metadataEditor.EditStatements(statements)
 	firstBatch, secondBatch, thirdBatch := BatchPostdataStatements(statements)
 	progressBar := utils.NewProgressBar(len(statements), "Post-data objects restored: ", utils.PB_VERBOSE)
 	progressBar.Start()
@@ -445,6 +575,7 @@ func restorePostdata(metadataFilename string) {
 	} else {
 		gplog.Info("Post-data metadata restore complete")
 	}
+	runHooks("after", "postdata")
 }
 
 func restoreStatistics() {
@@ -453,11 +584,14 @@ func restoreStatistics() {
 	}
 	statisticsFilename := globalFPInfo.GetStatisticsFilePath()
 	gplog.Info("Restoring query planner statistics from %s", statisticsFilename)
+	runHooks("before", "statistics")
+	defer reportTimings.StartSection("statistics")()
 
 	filters := NewFilters(opts.IncludedSchemas, opts.ExcludedSchemas, opts.IncludedRelations, opts.ExcludedRelations)
 
 	statements := GetRestoreMetadataStatementsFiltered("statistics", statisticsFilename, []string{}, []string{}, filters)
 	editStatementsRedirectSchema(statements, opts.RedirectSchema)
+	remapper.EditStatements(statements)
 	numErrors := ExecuteRestoreMetadataStatements(statements, "Table statistics", nil, utils.PB_VERBOSE, false)
 
 	if numErrors > 0 {
@@ -465,6 +599,7 @@ func restoreStatistics() {
 	} else {
 		gplog.Info("Query planner statistics restore complete")
 	}
+	runHooks("after", "statistics")
 }
 
 func runAnalyze(filteredDataEntries map[string][]toc.CoordinatorDataEntry) {
@@ -472,20 +607,21 @@ func runAnalyze(filteredDataEntries map[string][]toc.CoordinatorDataEntry) {
 		return
 	}
 	gplog.Info("Running ANALYZE on restored tables")
+	defer reportTimings.StartSection("analyze")()
 
 	var analyzeStatements []toc.StatementWithType
 	for _, dataEntries := range filteredDataEntries {
 		for _, entry := range dataEntries {
-			tableSchema := entry.Schema
+			tableSchema, tableName := remapper.TableName(entry.Schema, entry.Name)
 			if opts.RedirectSchema != "" {
 				tableSchema = opts.RedirectSchema
 			}
-			tableFQN := utils.MakeFQN(tableSchema, entry.Name)
+			tableFQN := utils.MakeFQN(tableSchema, tableName)
 			analyzeCommand := fmt.Sprintf("ANALYZE %s", tableFQN)
 
 			newAnalyzeStatement := toc.StatementWithType{
 				Schema:    tableSchema,
-				Name:      entry.Name,
+				Name:      tableName,
 				Statement: analyzeCommand,
 			}
 			analyzeStatements = append(analyzeStatements, newAnalyzeStatement)
@@ -589,8 +725,20 @@ func DoTeardown() {
 		}
 		reportFilename := globalFPInfo.GetRestoreReportFilePath(restoreStartTime)
 		origSize, destSize, _ := GetResizeClusterInfo()
-		report.WriteRestoreReportFile(reportFilename, globalFPInfo.Timestamp, restoreStartTime, connectionPool, version, origSize, destSize, errMsg)
+		report.WriteRestoreReportFile(reportFilename, globalFPInfo.Timestamp, restoreStartTime, connectionPool, version, origSize, destSize, errMsg, partialTables)
+		jsonReport := report.NewRestoreJSONReport(globalFPInfo.Timestamp, restoreStartTime, connectionPool, version, origSize, destSize, errMsg,
+			partialTables, reportTimings, getErrorTableNames(errorTablesMetadata), getErrorTableNames(errorTablesData))
+		report.WriteJSONReportFiles(jsonReport, globalFPInfo.GetRestoreJSONReportFilePath(restoreStartTime), MustGetFlagString(options.REPORT_JSON_FILE))
+		if metricsFilename := MustGetFlagString(options.METRICS_FILE); metricsFilename != "" {
+			err := report.WriteMetricsFile(metricsFilename, connectionPool.DBName, restoreStartTime, operating.System.Now(), jsonReport)
+			if err != nil {
+				gplog.Error(fmt.Sprintf("%v", err))
+			}
+		}
 		report.EmailReport(globalCluster, globalFPInfo.Timestamp, reportFilename, "gprestore", !restoreFailed)
+		if notificationConfig := MustGetFlagString(options.NOTIFICATION_CONFIG); notificationConfig != "" {
+			report.SendNotifications(notificationConfig, "gprestore", reportFilename, jsonReport)
+		}
 		if pluginConfig != nil {
 			pluginConfig.CleanupPluginForRestore(globalCluster, globalFPInfo)
 			pluginConfig.DeletePluginConfigWhenEncrypting(globalCluster)
@@ -603,7 +751,23 @@ func DoTeardown() {
 			// tables with data errors
 			writeErrorTables(false)
 		}
+		if restoreJournal != nil {
+			if !restoreFailed && gplog.GetErrorCode() == 0 {
+				removeRestoreJournal()
+			} else {
+				restoreJournal.Close()
+				gplog.Info("Run gprestore again with --%s %s to resume this restore", options.RESUME, restoreStartTime)
+			}
+		}
+	}
+}
+
+func getErrorTableNames(errorTables map[string]Empty) []string {
+	tableNames := make([]string, 0, len(errorTables))
+	for tableName := range errorTables {
+		tableNames = append(tableNames, tableName)
 	}
+	return tableNames
 }
 
 func writeErrorTables(isMetadata bool) {
@@ -649,6 +813,12 @@ func DoCleanup(restoreFailed bool) {
 	}()
 
 	gplog.Verbose("Beginning cleanup")
+	if restoreFailed && globalFPInfo.Timestamp != "" {
+		err := hookConfig.RunHooks(getHookContext(), "failure", "")
+		if err != nil {
+			gplog.Warn("%v", err)
+		}
+	}
 	if backupConfig != nil && backupConfig.SingleDataFile {
 		fpInfoList := GetBackupFPInfoListFromRestorePlan()
 		for _, fpInfo := range fpInfoList {
@@ -669,3 +839,25 @@ func DoCleanup(restoreFailed bool) {
 		connectionPool.Close()
 	}
 }
+
+func getHookContext() utils.HookContext {
+	database := MustGetFlagString(options.REDIRECT_DB)
+	if database == "" && backupConfig != nil {
+		database = utils.UnquoteIdent(backupConfig.DatabaseName)
+	}
+	return utils.HookContext{
+		Utility:          "gprestore",
+		Timestamp:        globalFPInfo.Timestamp,
+		RestoreTimestamp: restoreStartTime,
+		Database:         database,
+		BackupDir:        globalFPInfo.GetDirForContent(-1),
+	}
+}
+
+func runHooks(event string, phase string) {
+	if hookConfig == nil {
+		return
+	}
+	err := hookConfig.RunHooks(getHookContext(), event, phase)
+	gplog.FatalOnError(err)
+}
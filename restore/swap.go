@@ -0,0 +1,529 @@
+package restore
+
+/*
+ * This file contains structs and functions related to restoring tables with
+ * --swap, which replaces live tables without readers ever seeing them empty
+ * or half-loaded.
+ *
+ * Each selected table is restored under a shadow name in its schema, and its
+ * indexes, constraints and extended statistics under temporary names, by
+ * remapping the statements that create them.  Once the data and post-data of
+ * the shadow tables are restored and the shadow tables are analyzed, all of
+ * them are swapped in one transaction: each live table is renamed out of the
+ * way, its shadow table takes its name, and the live table is dropped.  The
+ * new table keeps the owner and the table and column privileges of the table
+ * it replaces and takes over the sequences owned by its columns, the views on
+ * the live table are redefined on the new table, and the foreign keys of other
+ * tables referencing the live table are recreated to reference the new table.
+ *
+ * The sequences of the backup are not restored, so each table must already
+ * exist for the sequences used by its columns to exist, and materialized views
+ * cannot be redefined without refreshing them, so a table that one depends on
+ * cannot be swapped.  Both are checked before anything is restored.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gpbackup/options"
+	"github.com/cloudberrydb/gpbackup/toc"
+	"github.com/cloudberrydb/gpbackup/utils"
+	"github.com/pkg/errors"
+)
+
+// An index, constraint or extended statistics object of a table restored with --swap
+type SwapObject struct {
+	ObjectType string
+	Schema     string
+	Name       string
+	TempName   string
+}
+
+type SwapTable struct {
+	Schema     string
+	Name       string
+	ShadowName string
+	OldName    string
+	Objects    []SwapObject
+}
+
+type Swapper struct {
+	tables  []*SwapTable
+	byTable map[string]*SwapTable
+}
+
+/*
+ * Returns a Swapper for the given tables of the backup.  The shadow and
+ * temporary names include the suffix, so that those of a failed restore are
+ * not mistaken for those of another.
+ */
+func NewSwapper(tocfile *toc.TOC, relations []string, suffix string) (*Swapper, error) {
+	swapper := &Swapper{tables: make([]*SwapTable, 0), byTable: make(map[string]*SwapTable)}
+	for _, relation := range relations {
+		var table *SwapTable
+		for _, entry := range tocfile.PredataEntries {
+			if entry.ObjectType != "TABLE" {
+				continue
+			}
+			if entry.ReferenceObject == relation {
+				return nil, errors.Errorf("Table %s is partitioned, so it cannot be restored with --%s", relation, options.SWAP)
+			}
+			if utils.MakeFQN(entry.Schema, entry.Name) == relation {
+				if entry.ReferenceObject != "" {
+					return nil, errors.Errorf("Table %s is a partition, so it cannot be restored with --%s", relation, options.SWAP)
+				}
+				table = &SwapTable{Schema: entry.Schema, Name: entry.Name}
+			}
+		}
+		if table == nil {
+			return nil, errors.Errorf("Relation %s is not a table in the backup, so it cannot be restored with --%s", relation, options.SWAP)
+		}
+		for _, entry := range tocfile.DataEntries {
+			if entry.PartitionRoot != "" && (utils.MakeFQN(entry.Schema, entry.Name) == relation || utils.MakeFQN(entry.Schema, entry.PartitionRoot) == relation) {
+				return nil, errors.Errorf("Table %s is partitioned, so it cannot be restored with --%s", relation, options.SWAP)
+			}
+		}
+		table.ShadowName = fmt.Sprintf("gprestore_swap_%s_%d", suffix, len(swapper.tables)+1)
+		table.OldName = fmt.Sprintf("gprestore_old_%s_%d", suffix, len(swapper.tables)+1)
+		swapper.tables = append(swapper.tables, table)
+		swapper.byTable[relation] = table
+	}
+
+	seen := make(map[string]bool)
+	for _, entries := range [][]toc.MetadataEntry{tocfile.PredataEntries, tocfile.PostdataEntries} {
+		for _, entry := range entries {
+			table, ok := swapper.byTable[entry.ReferenceObject]
+			if !ok || (entry.ObjectType != "INDEX" && entry.ObjectType != "CONSTRAINT" && entry.ObjectType != "STATISTICS") {
+				continue
+			}
+			key := fmt.Sprintf("%s %s %s", entry.ReferenceObject, entry.ObjectType, utils.MakeFQN(entry.Schema, entry.Name))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			table.Objects = append(table.Objects, SwapObject{
+				ObjectType: entry.ObjectType,
+				Schema:     entry.Schema,
+				Name:       entry.Name,
+				TempName:   fmt.Sprintf("%s_%d", table.ShadowName, len(table.Objects)+1),
+			})
+		}
+	}
+	return swapper, nil
+}
+
+func initializeSwapper() {
+	if backupConfig.MetadataOnly {
+		gplog.Fatal(errors.Errorf("Backup %s has no data, so it cannot be restored with --%s", backupConfig.Timestamp, options.SWAP), "")
+	} else if backupConfig.DataOnly {
+		gplog.Fatal(errors.Errorf("Backup %s has no metadata, so it cannot be restored with --%s", backupConfig.Timestamp, options.SWAP), "")
+	}
+	var err error
+	swapper, err = NewSwapper(globalTOC, opts.IncludedRelations, restoreStartTime)
+	gplog.FatalOnError(err)
+	remapper = swapper.Remapper()
+}
+
+/*
+ * Checks that each table exists in the restore database and that no
+ * materialized view depends on it, before anything is restored.
+ */
+func (swapper *Swapper) ValidateLiveTables(whichConn int) error {
+	for _, table := range swapper.tables {
+		fqn := utils.MakeFQN(table.Schema, table.Name)
+		var exists bool
+		err := connectionPool.Get(&exists, fmt.Sprintf("SELECT to_regclass('%s') IS NOT NULL", utils.EscapeSingleQuotes(fqn)), whichConn)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.Errorf("Table %s does not exist in the restore database, so it cannot be restored with --%s, which does not restore the sequences its columns may use", fqn, options.SWAP)
+		}
+		materializedViews := make([]string, 0)
+		query := fmt.Sprintf(`
+SELECT DISTINCT quote_ident(n.nspname) || '.' || quote_ident(v.relname) AS string
+FROM pg_depend d
+	JOIN pg_rewrite r ON r.oid = d.objid
+	JOIN pg_class v ON v.oid = r.ev_class
+	JOIN pg_namespace n ON n.oid = v.relnamespace
+WHERE d.classid = 'pg_rewrite'::regclass
+	AND d.refclassid = 'pg_class'::regclass
+	AND d.refobjid = '%s'::regclass
+	AND v.relkind = 'm'
+ORDER BY 1`, utils.EscapeSingleQuotes(fqn))
+		err = connectionPool.Select(&materializedViews, query, whichConn)
+		if err != nil {
+			return err
+		}
+		if len(materializedViews) > 0 {
+			return errors.Errorf("Table %s is used by materialized view %s, so it cannot be restored with --%s", fqn, strings.Join(materializedViews, ", "), options.SWAP)
+		}
+	}
+	return nil
+}
+
+/*
+ * Returns a Remapper restoring the tables under their shadow names, and their
+ * indexes and extended statistics, whose names are schema-qualified wherever
+ * they are referenced, under their temporary names.
+ */
+func (swapper *Swapper) Remapper() *Remapper {
+	remapper := &Remapper{schemas: make(map[string]string), tables: make(map[string]options.FqnStruct)}
+	for _, table := range swapper.tables {
+		remapper.tables[utils.MakeFQN(table.Schema, table.Name)] = options.FqnStruct{SchemaName: table.Schema, TableName: table.ShadowName}
+		for _, object := range table.Objects {
+			if object.ObjectType != "CONSTRAINT" {
+				remapper.tables[utils.MakeFQN(object.Schema, object.Name)] = options.FqnStruct{SchemaName: object.Schema, TableName: object.TempName}
+			}
+		}
+	}
+	return remapper
+}
+
+/*
+ * Renames the indexes and constraints of the tables in the unqualified places
+ * in which their statements name them, which the Remapper leaves alone.  The
+ * statements must not have been remapped yet.  A nil Swapper edits nothing.
+ */
+func (swapper *Swapper) EditStatements(statements []toc.StatementWithType) {
+	if swapper == nil {
+		return
+	}
+	for i, statement := range statements {
+		table, ok := swapper.byTable[statement.ReferenceObject]
+		if !ok {
+			continue
+		}
+		for _, object := range table.Objects {
+			if object.ObjectType != statement.ObjectType || object.Name != statement.Name || object.ObjectType == "STATISTICS" {
+				continue
+			}
+			statements[i].Statement = renameAfterKeyword(statement.Statement, object.ObjectType, object.Name, object.TempName)
+			if object.ObjectType == "CONSTRAINT" {
+				statements[i].Name = object.TempName
+			}
+		}
+	}
+}
+
+// Renames the unqualified name following each occurrence of the keyword
+func renameAfterKeyword(sql string, keyword string, oldName string, newName string) string {
+	command := newSQLCommand(tokenizeSQL(sql))
+	for i := range command.words {
+		isQualified := i+2 < len(command.words) && command.tokens[command.words[i+2]].text == "."
+		if command.isKeyword(i, keyword) && !isQualified {
+			command.mapName(i+1, map[string]string{oldName: newName})
+		}
+	}
+	var edited strings.Builder
+	for _, token := range command.tokens {
+		edited.WriteString(token.text)
+	}
+	return edited.String()
+}
+
+func swapTables() {
+	if wasTerminated {
+		return
+	}
+	gplog.Info("Swapping restored tables into place")
+	defer reportTimings.StartSection("swap")()
+	err := swapper.SwapTables(0)
+	if err != nil {
+		shadowTables := make([]string, 0)
+		for _, table := range swapper.tables {
+			shadowTables = append(shadowTables, utils.MakeFQN(table.Schema, table.ShadowName))
+		}
+		gplog.Fatal(errors.Wrapf(err, "Unable to swap the restored tables into place. The tables were left as they were, and the restored tables were left in %s", strings.Join(shadowTables, ", ")), "")
+	}
+	gplog.Info("Swapped %d tables into place", len(swapper.tables))
+}
+
+/*
+ * Swaps all of the shadow tables into place in one transaction, so that
+ * readers see either the live tables or the restored ones, and so that a
+ * table that cannot be swapped leaves all of them as they were.
+ */
+func (swapper *Swapper) SwapTables(whichConn int) error {
+	err := connectionPool.Begin(whichConn)
+	if err != nil {
+		return err
+	}
+	for _, table := range swapper.tables {
+		err = swapper.swapTable(table, whichConn)
+		if err != nil {
+			_ = connectionPool.Rollback(whichConn)
+			return err
+		}
+	}
+	return connectionPool.Commit(whichConn)
+}
+
+type tablePrivilege struct {
+	Grantee   string
+	Privilege string
+	Grantable bool
+}
+
+type columnPrivilege struct {
+	Column    string
+	Grantee   string
+	Privilege string
+	Grantable bool
+}
+
+type referencingForeignKey struct {
+	Table      string
+	Name       string
+	Definition string
+}
+
+type ownedSequence struct {
+	Sequence string
+	Column   string
+}
+
+type dependentView struct {
+	Name       string
+	Options    string
+	Definition string
+}
+
+func (swapper *Swapper) swapTable(table *SwapTable, whichConn int) error {
+	fqn := utils.MakeFQN(table.Schema, table.Name)
+	var exists bool
+	err := connectionPool.Get(&exists, fmt.Sprintf("SELECT to_regclass('%s') IS NOT NULL", utils.EscapeSingleQuotes(fqn)), whichConn)
+	if err == nil && !exists {
+		err = errors.New("the table was dropped during the restore")
+	} else if err == nil {
+		err = swapper.replaceTable(table, whichConn)
+	}
+	for _, object := range table.Objects {
+		if err != nil {
+			break
+		}
+		switch object.ObjectType {
+		case "INDEX":
+			err = execSwapStatement(fmt.Sprintf("ALTER INDEX %s RENAME TO %s", utils.MakeFQN(object.Schema, object.TempName), object.Name), whichConn)
+		case "STATISTICS":
+			err = execSwapStatement(fmt.Sprintf("ALTER STATISTICS %s RENAME TO %s", utils.MakeFQN(object.Schema, object.TempName), object.Name), whichConn)
+		case "CONSTRAINT":
+			err = execSwapStatement(fmt.Sprintf("ALTER TABLE %s RENAME CONSTRAINT %s TO %s", fqn, object.TempName, object.Name), whichConn)
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Unable to swap table %s into place", fqn)
+	}
+	return nil
+}
+
+func execSwapStatement(statement string, whichConn int) error {
+	gplog.Verbose(`Executing "%s" on coordinator`, statement)
+	_, err := connectionPool.Exec(statement, whichConn)
+	return err
+}
+
+/*
+ * Gives the shadow table the owner and privileges of the live table and the
+ * sequences owned by its columns, swaps the names of the tables, redefines
+ * the views and foreign keys referencing the live table on the shadow table,
+ * and drops the live table.  The views and foreign keys are read before the
+ * tables are renamed, so that their definitions name the table rather than
+ * the name it is moved to, and the privileges of the shadow table are read
+ * once it has its new owner, as changing the owner changes them.
+ *
+ * The foreign keys of other tables being swapped are dropped along with those
+ * tables, and their shadow tables already reference the shadow table, so they
+ * are not recreated.  Privileges on columns that the shadow table does not
+ * have are not carried over.
+ */
+func (swapper *Swapper) replaceTable(table *SwapTable, whichConn int) error {
+	fqn := utils.MakeFQN(table.Schema, table.Name)
+	shadowFQN := utils.MakeFQN(table.Schema, table.ShadowName)
+	var owner string
+	err := connectionPool.Get(&owner, fmt.Sprintf("SELECT quote_ident(pg_get_userbyid(relowner)) FROM pg_class WHERE oid = '%s'::regclass", utils.EscapeSingleQuotes(fqn)), whichConn)
+	if err != nil {
+		return err
+	}
+	privileges, err := getTablePrivileges(fqn, whichConn)
+	if err != nil {
+		return err
+	}
+	columnPrivileges, err := getColumnPrivileges(fqn, shadowFQN, whichConn)
+	if err != nil {
+		return err
+	}
+	sequences := make([]ownedSequence, 0)
+	sequenceQuery := fmt.Sprintf(`
+SELECT quote_ident(n.nspname) || '.' || quote_ident(s.relname) AS sequence,
+	quote_ident(a.attname) AS column
+FROM pg_depend d
+	JOIN pg_class s ON s.oid = d.objid
+	JOIN pg_namespace n ON n.oid = s.relnamespace
+	JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+WHERE d.classid = 'pg_class'::regclass
+	AND d.refclassid = 'pg_class'::regclass
+	AND d.refobjid = '%s'::regclass
+	AND d.deptype = 'a'
+	AND s.relkind = 'S'
+ORDER BY 1`, utils.EscapeSingleQuotes(fqn))
+	err = connectionPool.Select(&sequences, sequenceQuery, whichConn)
+	if err != nil {
+		return err
+	}
+	views := make([]dependentView, 0)
+	viewQuery := fmt.Sprintf(`
+SELECT DISTINCT quote_ident(n.nspname) || '.' || quote_ident(v.relname) AS name,
+	coalesce(array_to_string(v.reloptions, ', '), '') AS options,
+	pg_get_viewdef(v.oid) AS definition
+FROM pg_depend d
+	JOIN pg_rewrite r ON r.oid = d.objid
+	JOIN pg_class v ON v.oid = r.ev_class
+	JOIN pg_namespace n ON n.oid = v.relnamespace
+WHERE d.classid = 'pg_rewrite'::regclass
+	AND d.refclassid = 'pg_class'::regclass
+	AND d.refobjid = '%s'::regclass
+	AND v.relkind = 'v'
+ORDER BY 1`, utils.EscapeSingleQuotes(fqn))
+	err = connectionPool.Select(&views, viewQuery, whichConn)
+	if err != nil {
+		return err
+	}
+	foreignKeys := make([]referencingForeignKey, 0)
+	inheritedClause := ""
+	if connectionPool.Version.AtLeast("7") {
+		// The foreign keys of partitions are dropped and recreated with those of their partitioned table
+		inheritedClause = "\n\tAND con.conparentid = 0"
+	}
+	foreignKeyQuery := fmt.Sprintf(`
+SELECT quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS table,
+	quote_ident(con.conname) AS name,
+	pg_get_constraintdef(con.oid) AS definition
+FROM pg_constraint con
+	JOIN pg_class c ON c.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE con.contype = 'f'
+	AND con.confrelid = '%s'::regclass
+	AND con.conrelid <> con.confrelid%s
+ORDER BY 1, 2`, utils.EscapeSingleQuotes(fqn), inheritedClause)
+	err = connectionPool.Select(&foreignKeys, foreignKeyQuery, whichConn)
+	if err != nil {
+		return err
+	}
+
+	err = execSwapStatement(fmt.Sprintf("ALTER TABLE %s OWNER TO %s", shadowFQN, owner), whichConn)
+	if err != nil {
+		return err
+	}
+	shadowPrivileges, err := getTablePrivileges(shadowFQN, whichConn)
+	if err != nil {
+		return err
+	}
+	shadowColumnPrivileges, err := getColumnPrivileges(shadowFQN, shadowFQN, whichConn)
+	if err != nil {
+		return err
+	}
+	shadowGrantees := make([]string, 0)
+	for _, privilege := range shadowPrivileges {
+		shadowGrantees = append(shadowGrantees, privilege.Grantee)
+	}
+	for _, privilege := range shadowColumnPrivileges {
+		shadowGrantees = append(shadowGrantees, privilege.Grantee)
+	}
+	statements := make([]string, 0)
+	revoked := make(map[string]bool)
+	for _, grantee := range shadowGrantees {
+		// Revoking the privileges on a table also revokes those on its columns
+		if !revoked[grantee] {
+			statements = append(statements, fmt.Sprintf("REVOKE ALL ON TABLE %s FROM %s", shadowFQN, grantee))
+			revoked[grantee] = true
+		}
+	}
+	for _, privilege := range privileges {
+		statement := fmt.Sprintf("GRANT %s ON TABLE %s TO %s", privilege.Privilege, shadowFQN, privilege.Grantee)
+		if privilege.Grantable {
+			statement += " WITH GRANT OPTION"
+		}
+		statements = append(statements, statement)
+	}
+	for _, privilege := range columnPrivileges {
+		statement := fmt.Sprintf("GRANT %s (%s) ON TABLE %s TO %s", privilege.Privilege, privilege.Column, shadowFQN, privilege.Grantee)
+		if privilege.Grantable {
+			statement += " WITH GRANT OPTION"
+		}
+		statements = append(statements, statement)
+	}
+	for _, sequence := range sequences {
+		statements = append(statements, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s", sequence.Sequence, shadowFQN, sequence.Column))
+	}
+	for _, foreignKey := range foreignKeys {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", foreignKey.Table, foreignKey.Name))
+	}
+	statements = append(statements,
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", fqn, table.OldName),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", shadowFQN, table.Name))
+	for _, view := range views {
+		withOptions := ""
+		if view.Options != "" {
+			withOptions = fmt.Sprintf(" WITH (%s)", view.Options)
+		}
+		definition := strings.TrimSuffix(strings.TrimSpace(view.Definition), ";")
+		statements = append(statements, fmt.Sprintf("CREATE OR REPLACE VIEW %s%s AS %s", view.Name, withOptions, definition))
+	}
+	for _, foreignKey := range foreignKeys {
+		if _, isSwapped := swapper.byTable[foreignKey.Table]; !isSwapped {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", foreignKey.Table, foreignKey.Name, foreignKey.Definition))
+		}
+	}
+	statements = append(statements, fmt.Sprintf("DROP TABLE %s", utils.MakeFQN(table.Schema, table.OldName)))
+	for _, statement := range statements {
+		err = execSwapStatement(statement, whichConn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ * Returns the privileges granted on the table, with the default privileges of
+ * its owner in place of a null ACL so that they carry over as well.
+ */
+func getTablePrivileges(fqn string, whichConn int) ([]tablePrivilege, error) {
+	query := fmt.Sprintf(`
+SELECT CASE WHEN a.grantee = 0 THEN 'PUBLIC' ELSE quote_ident(pg_get_userbyid(a.grantee)) END AS grantee,
+	a.privilege_type AS privilege,
+	a.is_grantable AS grantable
+FROM pg_class c,
+	aclexplode(coalesce(c.relacl, acldefault('r', c.relowner))) a
+WHERE c.oid = '%s'::regclass
+ORDER BY 1, 2`, utils.EscapeSingleQuotes(fqn))
+	privileges := make([]tablePrivilege, 0)
+	err := connectionPool.Select(&privileges, query, whichConn)
+	return privileges, err
+}
+
+/*
+ * Returns the privileges granted on the columns of the table that the other
+ * table also has, which is the table itself to return all of them.
+ */
+func getColumnPrivileges(fqn string, columnsOf string, whichConn int) ([]columnPrivilege, error) {
+	query := fmt.Sprintf(`
+SELECT quote_ident(a.attname) AS column,
+	CASE WHEN p.grantee = 0 THEN 'PUBLIC' ELSE quote_ident(pg_get_userbyid(p.grantee)) END AS grantee,
+	p.privilege_type AS privilege,
+	p.is_grantable AS grantable
+FROM pg_attribute a,
+	aclexplode(a.attacl) p
+WHERE a.attrelid = '%s'::regclass
+	AND a.attnum > 0
+	AND NOT a.attisdropped
+	AND a.attacl IS NOT NULL
+	AND EXISTS (SELECT 1 FROM pg_attribute o WHERE o.attrelid = '%s'::regclass AND o.attname = a.attname AND NOT o.attisdropped)
+ORDER BY 1, 2, 3`, utils.EscapeSingleQuotes(fqn), utils.EscapeSingleQuotes(columnsOf))
+	privileges := make([]columnPrivilege, 0)
+	err := connectionPool.Select(&privileges, query, whichConn)
+	return privileges, err
+}
@@ -0,0 +1,181 @@
+package restore_test
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudberrydb/gpbackup/restore"
+	"github.com/cloudberrydb/gpbackup/toc"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("restore/swap tests", func() {
+	var tocfile *toc.TOC
+	BeforeEach(func() {
+		tocfile = &toc.TOC{
+			PredataEntries: []toc.MetadataEntry{
+				{Schema: "public", Name: "foo", ObjectType: "TABLE"},
+				{Schema: "public", Name: "foo_pkey", ObjectType: "CONSTRAINT", ReferenceObject: "public.foo"},
+				{Schema: "public", Name: "foo_pkey", ObjectType: "CONSTRAINT", ReferenceObject: "public.foo"},
+				{Schema: "public", Name: "bar", ObjectType: "TABLE"},
+				{Schema: "public", Name: "parent", ObjectType: "TABLE"},
+				{Schema: "public", Name: "child", ObjectType: "TABLE", ReferenceObject: "public.parent"},
+				{Schema: "public", Name: "myview", ObjectType: "VIEW"},
+			},
+			PostdataEntries: []toc.MetadataEntry{
+				{Schema: "public", Name: "foo_idx", ObjectType: "INDEX", ReferenceObject: "public.foo"},
+				{Schema: "stats", Name: "foo_stat", ObjectType: "STATISTICS", ReferenceObject: "public.foo"},
+				{Schema: "public", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "public.bar"},
+			},
+		}
+	})
+	Describe("NewSwapper", func() {
+		It("returns an error for a relation that is not a table", func() {
+			_, err := restore.NewSwapper(tocfile, []string{"public.myview"}, "20170101010101")
+			Expect(err).To(MatchError("Relation public.myview is not a table in the backup, so it cannot be restored with --swap"))
+		})
+		It("returns an error for a partitioned table or a partition", func() {
+			_, err := restore.NewSwapper(tocfile, []string{"public.parent"}, "20170101010101")
+			Expect(err).To(MatchError("Table public.parent is partitioned, so it cannot be restored with --swap"))
+			_, err = restore.NewSwapper(tocfile, []string{"public.child"}, "20170101010101")
+			Expect(err).To(MatchError("Table public.child is a partition, so it cannot be restored with --swap"))
+		})
+	})
+	Describe("ValidateLiveTables", func() {
+		var swapper *restore.Swapper
+		BeforeEach(func() {
+			var err error
+			swapper, err = restore.NewSwapper(tocfile, []string{"public.foo"}, "20170101010101")
+			Expect(err).ToNot(HaveOccurred())
+		})
+		It("accepts a table that exists and has no materialized views", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('public.foo') IS NOT NULL")).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery("relkind = 'm'").WillReturnRows(sqlmock.NewRows([]string{"string"}))
+
+			Expect(swapper.ValidateLiveTables(0)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("returns an error for a table that does not exist", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('public.foo') IS NOT NULL")).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+			err := swapper.ValidateLiveTables(0)
+
+			Expect(err).To(MatchError("Table public.foo does not exist in the restore database, so it cannot be restored with --swap, which does not restore the sequences its columns may use"))
+		})
+		It("returns an error for a table used by a materialized view", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('public.foo') IS NOT NULL")).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery("relkind = 'm'").WillReturnRows(sqlmock.NewRows([]string{"string"}).AddRow("public.foo_matview"))
+
+			err := swapper.ValidateLiveTables(0)
+
+			Expect(err).To(MatchError("Table public.foo is used by materialized view public.foo_matview, so it cannot be restored with --swap"))
+		})
+	})
+	Describe("EditStatements", func() {
+		It("restores the tables and their indexes, constraints and statistics under other names", func() {
+			swapper, err := restore.NewSwapper(tocfile, []string{"public.foo"}, "20170101010101")
+			Expect(err).ToNot(HaveOccurred())
+			statements := []toc.StatementWithType{
+				{Schema: "public", Name: "foo", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.foo (\n\ti integer\n) DISTRIBUTED BY (i);\n"},
+				{Schema: "public", Name: "foo_pkey", ObjectType: "CONSTRAINT", ReferenceObject: "public.foo", Statement: "\n\nALTER TABLE ONLY public.foo ADD CONSTRAINT foo_pkey PRIMARY KEY (i);\n"},
+				{Schema: "public", Name: "foo_idx", ObjectType: "INDEX", ReferenceObject: "public.foo", Statement: "\n\nCREATE INDEX foo_idx ON public.foo USING btree (i);"},
+				{Schema: "stats", Name: "foo_stat", ObjectType: "STATISTICS", ReferenceObject: "public.foo", Statement: "\n\nCREATE STATISTICS stats.foo_stat (dependencies) ON i, j FROM public.foo;"},
+				{Schema: "public", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "public.bar", Statement: "\n\nCREATE INDEX bar_idx ON public.bar USING btree (i);"},
+			}
+
+			swapper.EditStatements(statements)
+			swapper.Remapper().EditStatements(statements)
+
+			Expect(statements).To(Equal([]toc.StatementWithType{
+				{Schema: "public", Name: "gprestore_swap_20170101010101_1", ObjectType: "TABLE", Statement: "\n\nCREATE TABLE public.gprestore_swap_20170101010101_1 (\n\ti integer\n) DISTRIBUTED BY (i);\n"},
+				{Schema: "public", Name: "gprestore_swap_20170101010101_1_1", ObjectType: "CONSTRAINT", ReferenceObject: "public.gprestore_swap_20170101010101_1", Statement: "\n\nALTER TABLE ONLY public.gprestore_swap_20170101010101_1 ADD CONSTRAINT gprestore_swap_20170101010101_1_1 PRIMARY KEY (i);\n"},
+				{Schema: "public", Name: "gprestore_swap_20170101010101_1_2", ObjectType: "INDEX", ReferenceObject: "public.gprestore_swap_20170101010101_1", Statement: "\n\nCREATE INDEX gprestore_swap_20170101010101_1_2 ON public.gprestore_swap_20170101010101_1 USING btree (i);"},
+				{Schema: "stats", Name: "gprestore_swap_20170101010101_1_3", ObjectType: "STATISTICS", ReferenceObject: "public.gprestore_swap_20170101010101_1", Statement: "\n\nCREATE STATISTICS stats.gprestore_swap_20170101010101_1_3 (dependencies) ON i, j FROM public.gprestore_swap_20170101010101_1;"},
+				{Schema: "public", Name: "bar_idx", ObjectType: "INDEX", ReferenceObject: "public.bar", Statement: "\n\nCREATE INDEX bar_idx ON public.bar USING btree (i);"},
+			}))
+		})
+	})
+	Describe("SwapTables", func() {
+		var swapper *restore.Swapper
+		BeforeEach(func() {
+			var err error
+			swapper, err = restore.NewSwapper(tocfile, []string{"public.foo"}, "20170101010101")
+			Expect(err).ToNot(HaveOccurred())
+			mock.ExpectBegin()
+			mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL SERIALIZABLE").WillReturnResult(sqlmock.NewResult(0, 0))
+		})
+		expectObjectRenames := func() {
+			mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE public.foo RENAME CONSTRAINT gprestore_swap_20170101010101_1_1 TO foo_pkey")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("ALTER INDEX public.gprestore_swap_20170101010101_1_2 RENAME TO foo_idx")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec(regexp.QuoteMeta("ALTER STATISTICS stats.gprestore_swap_20170101010101_1_3 RENAME TO foo_stat")).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		It("returns an error when the live table was dropped during the restore", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('public.foo') IS NOT NULL")).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			mock.ExpectRollback()
+
+			err := swapper.SwapTables(0)
+
+			Expect(err).To(MatchError("Unable to swap table public.foo into place: the table was dropped during the restore"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("replaces the live table, keeping its owner, privileges, sequences, views and referencing foreign keys", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('public.foo') IS NOT NULL")).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT quote_ident(pg_get_userbyid(relowner)) FROM pg_class WHERE oid = 'public.foo'::regclass")).
+				WillReturnRows(sqlmock.NewRows([]string{"owner"}).AddRow("app_owner"))
+			mock.ExpectQuery("relacl").WillReturnRows(sqlmock.NewRows([]string{"grantee", "privilege", "grantable"}).
+				AddRow("app_owner", "SELECT", false).AddRow("reader", "SELECT", true))
+			mock.ExpectQuery("attacl").WillReturnRows(sqlmock.NewRows([]string{"column", "grantee", "privilege", "grantable"}).
+				AddRow("i", "auditor", "SELECT", false).AddRow("i", "writer", "UPDATE", true))
+			mock.ExpectQuery("deptype = 'a'").WillReturnRows(sqlmock.NewRows([]string{"sequence", "column"}).AddRow("public.foo_i_seq", "i"))
+			mock.ExpectQuery("pg_get_viewdef").WillReturnRows(sqlmock.NewRows([]string{"name", "options", "definition"}).
+				AddRow("public.foo_view", "", " SELECT foo.i\n   FROM foo;").
+				AddRow("public.secure_view", "security_barrier=true", " SELECT foo.i\n   FROM foo\n  WHERE foo.i > 0;"))
+			mock.ExpectQuery("contype = 'f'").WillReturnRows(sqlmock.NewRows([]string{"table", "name", "definition"}).
+				AddRow("public.orders", "orders_foo_fkey", "FOREIGN KEY (foo_i) REFERENCES public.foo(i)"))
+			mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE public.gprestore_swap_20170101010101_1 OWNER TO app_owner")).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("relacl").WillReturnRows(sqlmock.NewRows([]string{"grantee", "privilege", "grantable"}).
+				AddRow("PUBLIC", "SELECT", false).AddRow("app_owner", "INSERT", false).AddRow("app_owner", "SELECT", false))
+			mock.ExpectQuery("attacl").WillReturnRows(sqlmock.NewRows([]string{"column", "grantee", "privilege", "grantable"}).
+				AddRow("i", "auditor", "SELECT", false).AddRow("i", "backup_reader", "SELECT", false))
+			for _, statement := range []string{
+				"REVOKE ALL ON TABLE public.gprestore_swap_20170101010101_1 FROM PUBLIC",
+				"REVOKE ALL ON TABLE public.gprestore_swap_20170101010101_1 FROM app_owner",
+				"REVOKE ALL ON TABLE public.gprestore_swap_20170101010101_1 FROM auditor",
+				"REVOKE ALL ON TABLE public.gprestore_swap_20170101010101_1 FROM backup_reader",
+				"GRANT SELECT ON TABLE public.gprestore_swap_20170101010101_1 TO app_owner",
+				"GRANT SELECT ON TABLE public.gprestore_swap_20170101010101_1 TO reader WITH GRANT OPTION",
+				"GRANT SELECT (i) ON TABLE public.gprestore_swap_20170101010101_1 TO auditor",
+				"GRANT UPDATE (i) ON TABLE public.gprestore_swap_20170101010101_1 TO writer WITH GRANT OPTION",
+				"ALTER SEQUENCE public.foo_i_seq OWNED BY public.gprestore_swap_20170101010101_1.i",
+				"ALTER TABLE public.orders DROP CONSTRAINT orders_foo_fkey",
+				"ALTER TABLE public.foo RENAME TO gprestore_old_20170101010101_1",
+				"ALTER TABLE public.gprestore_swap_20170101010101_1 RENAME TO foo",
+				"CREATE OR REPLACE VIEW public.foo_view AS SELECT foo.i\n   FROM foo",
+				"CREATE OR REPLACE VIEW public.secure_view WITH (security_barrier=true) AS SELECT foo.i\n   FROM foo\n  WHERE foo.i > 0",
+				"ALTER TABLE public.orders ADD CONSTRAINT orders_foo_fkey FOREIGN KEY (foo_i) REFERENCES public.foo(i)",
+				"DROP TABLE public.gprestore_old_20170101010101_1",
+			} {
+				mock.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+			expectObjectRenames()
+			mock.ExpectCommit()
+
+			Expect(swapper.SwapTables(0)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+		It("rolls back the swap when it fails", func() {
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass('public.foo') IS NOT NULL")).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT quote_ident(pg_get_userbyid(relowner)) FROM pg_class WHERE oid = 'public.foo'::regclass")).
+				WillReturnError(errors.New("permission denied"))
+			mock.ExpectRollback()
+
+			err := swapper.SwapTables(0)
+
+			Expect(err).To(MatchError("Unable to swap table public.foo into place: permission denied"))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
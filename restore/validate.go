@@ -205,7 +205,7 @@ func getFilterRelationsInBackupSet(relationList []string) []string {
 	return keys
 }
 
-func ValidateDatabaseExistence(unquotedDBName string, createDatabase bool, isFiltered bool) {
+func DatabaseExists(unquotedDBName string) bool {
 	qry := fmt.Sprintf(`
 SELECT CASE
 	WHEN EXISTS (SELECT 1 FROM pg_database WHERE datname='%s') THEN 'true'
@@ -213,6 +213,11 @@ SELECT CASE
 END AS string;`, utils.EscapeSingleQuotes(unquotedDBName))
 	databaseExists, err := strconv.ParseBool(dbconn.MustSelectString(connectionPool, qry))
 	gplog.FatalOnError(err)
+	return databaseExists
+}
+
+func ValidateDatabaseExistence(unquotedDBName string, createDatabase bool, isFiltered bool) {
+	databaseExists := DatabaseExists(unquotedDBName)
 	if !databaseExists {
 		if isFiltered {
 			gplog.Fatal(errors.Errorf(`Database "%s" must be created manually to restore table-filtered or data-only backups.`, unquotedDBName), "")
@@ -241,6 +246,7 @@ func ValidateBackupFlagCombinations() {
 		gplog.Fatal(errors.Errorf("The --copy-queue-size flag can only be used if the backup was taken with --single-data-file"), "")
 	}
 	validateBackupFlagPluginCombinations()
+	validateBackupFlagEncryptionCombinations()
 }
 
 func validateBackupFlagPluginCombinations() {
@@ -251,6 +257,18 @@ func validateBackupFlagPluginCombinations() {
 	}
 }
 
+func validateBackupFlagEncryptionCombinations() {
+	key := utils.GetEncryptionKey()
+	if backupConfig.EncryptionFingerprint != "" && key == nil {
+		gplog.Fatal(errors.Errorf("Backup was encrypted. The --encryption-key-file flag must be used to restore."), "")
+	} else if backupConfig.EncryptionFingerprint == "" && key != nil {
+		gplog.Fatal(errors.Errorf("The --encryption-key-file flag cannot be used to restore a backup taken without encryption."), "")
+	} else if key != nil && backupConfig.EncryptionFingerprint != utils.GetKeyFingerprint(key) {
+		gplog.Fatal(errors.Errorf("Backup was encrypted with the key with fingerprint %s, but the given key has fingerprint %s.",
+			backupConfig.EncryptionFingerprint, utils.GetKeyFingerprint(key)), "")
+	}
+}
+
 func ValidateFlagCombinations(flags *pflag.FlagSet) {
 	options.CheckExclusiveFlags(flags, options.DATA_ONLY, options.WITH_GLOBALS)
 	options.CheckExclusiveFlags(flags, options.DATA_ONLY, options.CREATE_DB)
@@ -262,8 +280,16 @@ func ValidateFlagCombinations(flags *pflag.FlagSet) {
 
 	options.CheckExclusiveFlags(flags, options.METADATA_ONLY, options.DATA_ONLY)
 	options.CheckExclusiveFlags(flags, options.PLUGIN_CONFIG, options.BACKUP_DIR)
+	options.CheckExclusiveFlags(flags, options.PLUGIN_CONFIG, options.ENCRYPTION_KEY_FILE)
 	options.CheckExclusiveFlags(flags, options.TRUNCATE_TABLE, options.METADATA_ONLY, options.INCREMENTAL)
 	options.CheckExclusiveFlags(flags, options.TRUNCATE_TABLE, options.REDIRECT_SCHEMA)
+	options.CheckExclusiveFlags(flags, options.REDIRECT_SCHEMA, options.REMAP_FILE)
+	for _, flagName := range []string{options.ROLE_MAP_FILE, options.TABLESPACE_MAP_FILE, options.NO_OWNER, options.NO_PRIVILEGES} {
+		// These only change how metadata is restored
+		if flags.Changed(flagName) && flags.Changed(options.DATA_ONLY) {
+			gplog.Fatal(errors.Errorf("Cannot use --%s with --%s", flagName, options.DATA_ONLY), "")
+		}
+	}
 
 	if flags.Changed(options.REDIRECT_SCHEMA) {
 		// Redirect schema not compatible with any exclude flags
@@ -285,7 +311,41 @@ func ValidateFlagCombinations(flags *pflag.FlagSet) {
 	if flags.Changed(options.INCREMENTAL) && !flags.Changed(options.DATA_ONLY) {
 		gplog.Fatal(errors.Errorf("Cannot use --incremental without --data-only"), "")
 	}
+	options.CheckExclusiveFlags(flags, options.ON_CONFLICT, options.TRUNCATE_TABLE, options.INCREMENTAL)
+	if flags.Changed(options.ON_CONFLICT) && !flags.Changed(options.DATA_ONLY) {
+		gplog.Fatal(errors.Errorf("Cannot use --on-conflict without --data-only"), "")
+	}
+	if flags.Changed(options.SWAP) {
+		if !(flags.Changed(options.INCLUDE_RELATION) || flags.Changed(options.INCLUDE_RELATION_FILE)) {
+			gplog.Fatal(errors.Errorf("Cannot use --swap without --include-table or --include-table-file"), "")
+		}
+		// --swap restores both the metadata and the data of new tables, and only swaps them into place if all of it was restored
+		for _, flagName := range []string{options.METADATA_ONLY, options.DATA_ONLY, options.INCREMENTAL, options.TRUNCATE_TABLE, options.ON_CONFLICT,
+			options.REDIRECT_SCHEMA, options.REMAP_FILE, options.CREATE_DB, options.WITH_STATS, options.ON_ERROR_CONTINUE, options.RESUME, options.LIST, options.USE_LIST} {
+			if flags.Changed(flagName) {
+				gplog.Fatal(errors.Errorf("Cannot use --%s with --%s", flagName, options.SWAP), "")
+			}
+		}
+	}
 	options.CheckExclusiveFlags(flags, options.RUN_ANALYZE, options.WITH_STATS)
+	options.CheckExclusiveFlags(flags, options.RESUME, options.VERIFY_ONLY)
+	options.CheckExclusiveFlags(flags, options.LIST, options.USE_LIST, options.VERIFY_ONLY)
+	if flags.Changed(options.LIST) {
+		// --list prints the entries of the whole backup, and restores nothing
+		for _, flagName := range []string{options.METADATA_ONLY, options.DATA_ONLY, options.CREATE_DB, options.WITH_GLOBALS, options.INCREMENTAL, options.RESIZE_CLUSTER, options.RESUME} {
+			if flags.Changed(flagName) {
+				gplog.Fatal(errors.Errorf("Cannot use --%s with --%s", flagName, options.LIST), "")
+			}
+		}
+	}
+	if flags.Changed(options.VERIFY_ONLY) {
+		// --verify-only reads the backup files as they are, without restoring them anywhere
+		for _, flagName := range []string{options.METADATA_ONLY, options.CREATE_DB, options.WITH_GLOBALS, options.INCREMENTAL, options.RESIZE_CLUSTER} {
+			if flags.Changed(flagName) {
+				gplog.Fatal(errors.Errorf("Cannot use --%s with --%s", flagName, options.VERIFY_ONLY), "")
+			}
+		}
+	}
 }
 
 func ValidateSafeToResizeCluster() {
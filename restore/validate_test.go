@@ -395,6 +395,41 @@ var _ = Describe("restore/validate tests", func() {
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --exclude-schema-file /tmp/file2", false),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-table schema.table2 --metadata-only", true),
 			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-table schema.table2 --data-only", true),
+			Entry("--redirect-schema combos", "--redirect-schema schema1 --include-table schema.table2 --remap-file /tmp/file", false),
+			Entry("--remap-file combos", "--remap-file /tmp/file", true),
+			Entry("--remap-file combos", "--remap-file /tmp/file --exclude-schema schema2", true),
+			Entry("--role-map combos", "--role-map /tmp/file --tablespace-map /tmp/file --no-owner --no-privileges", true),
+			Entry("--role-map combos", "--role-map /tmp/file --with-globals --metadata-only", true),
+			Entry("--role-map combos", "--role-map /tmp/file --data-only", false),
+			Entry("--tablespace-map combos", "--tablespace-map /tmp/file --data-only", false),
+			Entry("--no-owner combos", "--no-owner --data-only", false),
+			Entry("--no-privileges combos", "--no-privileges --data-only", false),
+			Entry("--list combos", "--list", true),
+			Entry("--list combos", "--list --use-list /tmp/file", false),
+			Entry("--list combos", "--list --data-only", false),
+			Entry("--list combos", "--list --with-globals", false),
+			Entry("--use-list combos", "--use-list /tmp/file --with-globals --include-schema schema1", true),
+			Entry("--use-list combos", "--use-list /tmp/file --verify-only", false),
+			Entry("--on-conflict combos", "--on-conflict update --data-only", true),
+			Entry("--on-conflict combos", "--on-conflict skip --data-only --include-table schema.table2", true),
+			Entry("--on-conflict combos", "--on-conflict update", false),
+			Entry("--on-conflict combos", "--on-conflict update --data-only --truncate-table --include-table schema.table2", false),
+			Entry("--on-conflict combos", "--on-conflict update --data-only --incremental", false),
+			Entry("--swap combos", "--swap --include-table schema.table2", true),
+			Entry("--swap combos", "--swap --include-table-file /tmp/file --run-analyze", true),
+			Entry("--swap combos", "--swap", false),
+			Entry("--swap combos", "--swap --include-schema schema2", false),
+			Entry("--swap combos", "--swap --include-table schema.table2 --data-only", false),
+			Entry("--swap combos", "--swap --include-table schema.table2 --truncate-table", false),
+			Entry("--swap combos", "--swap --include-table schema.table2 --on-error-continue", false),
+			Entry("--swap combos", "--swap --include-table schema.table2 --with-stats", false),
+			Entry("--verify-only combos", "--verify-only", true),
+			Entry("--verify-only combos", "--verify-only --include-table schema.table2", true),
+			Entry("--verify-only combos", "--verify-only --metadata-only", false),
+			Entry("--verify-only combos", "--verify-only --create-db", false),
+			Entry("--verify-only combos", "--verify-only --resize-cluster", false),
+			Entry("--resume combos", "--resume 20170101010101 --on-error-continue", true),
+			Entry("--resume combos", "--resume 20170101010101 --verify-only", false),
 		)
 	})
 	Describe("ValidateBackupFlagCombinations", func() {
@@ -415,5 +450,33 @@ var _ = Describe("restore/validate tests", func() {
 				Fail("invalid flag combination passed validation check")
 			}
 		})
+		Describe("encryption", func() {
+			key := []byte(strings.Repeat("k", 32))
+			AfterEach(func() {
+				utils.SetEncryptionKey(nil)
+			})
+			It("accepts the key the backup was encrypted with", func() {
+				utils.SetEncryptionKey(key)
+				restore.SetBackupConfig(&history.BackupConfig{EncryptionFingerprint: utils.GetKeyFingerprint(key)})
+				restore.ValidateBackupFlagCombinations()
+			})
+			It("fatals if an encrypted backup is restored without a key", func() {
+				restore.SetBackupConfig(&history.BackupConfig{EncryptionFingerprint: utils.GetKeyFingerprint(key)})
+				defer testhelper.ShouldPanicWithMessage("Backup was encrypted. The --encryption-key-file flag must be used to restore.")
+				restore.ValidateBackupFlagCombinations()
+			})
+			It("fatals if a key is given for an unencrypted backup", func() {
+				utils.SetEncryptionKey(key)
+				restore.SetBackupConfig(&history.BackupConfig{})
+				defer testhelper.ShouldPanicWithMessage("The --encryption-key-file flag cannot be used to restore a backup taken without encryption.")
+				restore.ValidateBackupFlagCombinations()
+			})
+			It("fatals if the key does not match the one the backup was encrypted with", func() {
+				utils.SetEncryptionKey(key)
+				restore.SetBackupConfig(&history.BackupConfig{EncryptionFingerprint: "0123456789abcdef"})
+				defer testhelper.ShouldPanicWithMessage("Backup was encrypted with the key with fingerprint 0123456789abcdef")
+				restore.ValidateBackupFlagCombinations()
+			})
+		})
 	})
 })
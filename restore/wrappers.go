@@ -1,7 +1,9 @@
 package restore
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	path "path/filepath"
 	"strconv"
 	"strings"
@@ -52,7 +54,7 @@ func filtersEmpty(filters Filters) bool {
 }
 
 func SetLoggerVerbosity() {
-	if MustGetFlagBool(options.QUIET) {
+	if MustGetFlagBool(options.QUIET) || MustGetFlagBool(options.LIST) {
 		gplog.SetVerbosity(gplog.LOGERROR)
 	} else if MustGetFlagBool(options.DEBUG) {
 		gplog.SetVerbosity(gplog.LOGDEBUG)
@@ -108,7 +110,10 @@ SET default_with_oids = off;
 	// If we're restoring to a different-sized cluster, disable the
 	// distribution key check because the data won't necessarily
 	// match initially and will be redistributed after the restore.
-	if resizeRestore {
+	//
+	// Sampling rows by following foreign keys can also leave rows on a
+	// segment other than the one they are distributed to.
+	if resizeRestore || backupConfig.SampleForeignKeys {
 		setupQuery += "SET gp_enable_segment_copy_checking TO off;\n"
 	}
 
@@ -127,6 +132,16 @@ func SetMaxCsvLineLengthQuery(connectionPool *dbconn.DBConn) string {
 	return ""
 }
 
+func initializeEncryption() {
+	keyFile := MustGetFlagString(options.ENCRYPTION_KEY_FILE)
+	key, err := utils.ReadEncryptionKeyFile(keyFile)
+	gplog.FatalOnError(err)
+	utils.SetEncryptionKey(key)
+	if !MustGetFlagBool(options.METADATA_ONLY) && !MustGetFlagBool(options.VERIFY_ONLY) {
+		utils.CheckEncryptionKeyFileOnAllHosts(globalCluster, keyFile)
+	}
+}
+
 func InitializeBackupConfig() {
 	backupConfig = history.ReadConfigFile(globalFPInfo.GetConfigFilePath())
 	utils.InitializePipeThroughParameters(backupConfig.Compressed, backupConfig.CompressionType, 0)
@@ -235,6 +250,19 @@ func FindHistoricalPluginVersion(timestamp string) string {
  * Metadata and/or data restore wrapper functions
  */
 
+/*
+ * Statements are read from the metadata file by their byte offsets, which are
+ * offsets into the plaintext, so an encrypted file is decrypted into memory.
+ */
+func mustOpenMetadataFileForReading(filename string) io.ReaderAt {
+	if utils.GetEncryptionKey() == nil {
+		return iohelper.MustOpenFileForReading(filename)
+	}
+	contents, err := utils.ReadBackupFile(filename)
+	gplog.FatalOnError(err)
+	return bytes.NewReader(contents)
+}
+
 func GetRestoreMetadataStatements(section string, filename string, includeObjectTypes []string, excludeObjectTypes []string) []toc.StatementWithType {
 	var statements []toc.StatementWithType
 	statements = GetRestoreMetadataStatementsFiltered(section, filename, includeObjectTypes, excludeObjectTypes, Filters{})
@@ -242,7 +270,7 @@ func GetRestoreMetadataStatements(section string, filename string, includeObject
 }
 
 func GetRestoreMetadataStatementsFiltered(section string, filename string, includeObjectTypes []string, excludeObjectTypes []string, filters Filters) []toc.StatementWithType {
-	metadataFile := iohelper.MustOpenFileForReading(filename)
+	metadataFile := mustOpenMetadataFileForReading(filename)
 	var statements []toc.StatementWithType
 	var inSchemas, exSchemas, inRelations, exRelations []string
 	if !filtersEmpty(filters) {
@@ -3,7 +3,6 @@ package toc
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"regexp"
 	"strings"
 
@@ -24,6 +23,8 @@ type TOC struct {
 
 type SegmentTOC struct {
 	DataEntries map[uint]SegmentDataEntry
+	Checksum    string `yaml:",omitempty"`
+	Framed      bool   `yaml:",omitempty"`
 }
 
 type MetadataEntry struct {
@@ -43,15 +44,26 @@ type CoordinatorDataEntry struct {
 	RowsCopied      int64
 	PartitionRoot   string
 	IsReplicated    bool
+	EstimatedSize   int64  `yaml:",omitempty"`
+	RowFilter       string `yaml:",omitempty"`
 }
 
+/*
+ * StartByte and EndByte are offsets in the uncompressed data.  When the data
+ * file is Framed, each table is written in its own frame, and FrameStart
+ * and FrameEnd are the offsets of that frame in the data file.
+ */
 type SegmentDataEntry struct {
-	StartByte uint64
-	EndByte   uint64
+	StartByte  uint64
+	EndByte    uint64
+	FrameStart uint64 `yaml:",omitempty"`
+	FrameEnd   uint64 `yaml:",omitempty"`
 }
 
 type IncrementalEntries struct {
-	AO map[string]AOEntry
+	AO                 map[string]AOEntry
+	Heap               map[string]HeapEntry `yaml:",omitempty"`
+	HeapStatsTimestamp string               `yaml:",omitempty"`
 }
 
 type AOEntry struct {
@@ -59,9 +71,24 @@ type AOEntry struct {
 	LastDDLTimestamp string
 }
 
+/*
+ * Heap tables have no modcount, so a change to one is detected from its
+ * relfilenode, its last DDL timestamp, and its tuple statistics summed across
+ * the segments.  The statistics are only comparable between backups with the
+ * same HeapStatsTimestamp, the latest time the statistics of any segment were
+ * reset or its server was started.
+ */
+type HeapEntry struct {
+	Relfilenode      uint32
+	TuplesInserted   int64
+	TuplesUpdated    int64
+	TuplesDeleted    int64
+	LastDDLTimestamp string
+}
+
 func NewTOC(filename string) *TOC {
 	toc := &TOC{}
-	contents, err := ioutil.ReadFile(filename)
+	contents, err := utils.ReadBackupFile(filename)
 	gplog.FatalOnError(err)
 	err = yaml.Unmarshal(contents, toc)
 	gplog.FatalOnError(err)
@@ -70,7 +97,7 @@ func NewTOC(filename string) *TOC {
 
 func NewSegmentTOC(filename string) *SegmentTOC {
 	toc := &SegmentTOC{}
-	contents, err := ioutil.ReadFile(filename)
+	contents, err := utils.ReadBackupFile(filename)
 	gplog.FatalOnError(err)
 	err = yaml.Unmarshal(contents, toc)
 	gplog.FatalOnError(err)
@@ -276,12 +303,21 @@ func (toc *TOC) AddMetadataEntry(section string, entry MetadataEntry, start, end
 	*toc.metadataEntryMap[section] = append(*toc.metadataEntryMap[section], entry)
 }
 
-func (toc *TOC) AddCoordinatorDataEntry(schema string, name string, oid uint32, attributeString string, rowsCopied int64, PartitionRoot string, distPolicy string) {
+/*
+ * Returns the added entry, so that optional fields such as EstimatedSize can
+ * be set on it.
+ */
+func (toc *TOC) AddCoordinatorDataEntry(schema string, name string, oid uint32, attributeString string, rowsCopied int64, PartitionRoot string, distPolicy string) *CoordinatorDataEntry {
 	isReplicated := strings.Contains(distPolicy, "REPLICATED")
-	toc.DataEntries = append(toc.DataEntries, CoordinatorDataEntry{schema, name, oid, attributeString, rowsCopied, PartitionRoot, isReplicated})
+	toc.DataEntries = append(toc.DataEntries, CoordinatorDataEntry{Schema: schema, Name: name, Oid: oid, AttributeString: attributeString, RowsCopied: rowsCopied, PartitionRoot: PartitionRoot, IsReplicated: isReplicated})
+	return &toc.DataEntries[len(toc.DataEntries)-1]
 }
 
 func (toc *SegmentTOC) AddSegmentDataEntry(oid uint, startByte uint64, endByte uint64) {
 	// We use uint for oid since the flags package does not have a uint32 flag
-	toc.DataEntries[oid] = SegmentDataEntry{startByte, endByte}
+	toc.DataEntries[oid] = SegmentDataEntry{StartByte: startByte, EndByte: endByte}
+}
+
+func (toc *SegmentTOC) AddFramedSegmentDataEntry(oid uint, startByte uint64, endByte uint64, frameStart uint64, frameEnd uint64) {
+	toc.DataEntries[oid] = SegmentDataEntry{startByte, endByte, frameStart, frameEnd}
 }
@@ -2,6 +2,8 @@ package toc_test
 
 import (
 	"bytes"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/cloudberrydb/gpbackup/testutils"
@@ -440,4 +442,40 @@ COMMENT ON DATABASE "db-special-chär$" IS 'this is a database comment';`}
 			Expect(roots).To(BeEmpty())
 		})
 	})
+	Describe("SegmentTOC", func() {
+		var tocDir string
+		BeforeEach(func() {
+			var err error
+			tocDir, err = os.MkdirTemp("", "segment_toc")
+			Expect(err).ToNot(HaveOccurred())
+		})
+		AfterEach(func() {
+			_ = os.RemoveAll(tocDir)
+		})
+		It("writes and reads frame offsets for a framed data file", func() {
+			segmentTOC := &toc.SegmentTOC{DataEntries: make(map[uint]toc.SegmentDataEntry), Framed: true}
+			segmentTOC.AddFramedSegmentDataEntry(1, 0, 100, 0, 40)
+			segmentTOC.AddFramedSegmentDataEntry(2, 100, 100, 40, 60)
+			segmentTOC.AddFramedSegmentDataEntry(3, 100, 250, 60, 120)
+			filename := path.Join(tocDir, "gpbackup_0_20170101010101_toc.yaml")
+			Expect(segmentTOC.WriteToFileAndMakeReadOnly(filename)).To(Succeed())
+
+			resultTOC := toc.NewSegmentTOC(filename)
+			Expect(resultTOC.Framed).To(BeTrue())
+			Expect(resultTOC.DataEntries).To(Equal(map[uint]toc.SegmentDataEntry{
+				1: {StartByte: 0, EndByte: 100, FrameStart: 0, FrameEnd: 40},
+				2: {StartByte: 100, EndByte: 100, FrameStart: 40, FrameEnd: 60},
+				3: {StartByte: 100, EndByte: 250, FrameStart: 60, FrameEnd: 120},
+			}))
+		})
+		It("reads a TOC without frame offsets as not framed", func() {
+			filename := path.Join(tocDir, "gpbackup_0_20170101010101_toc.yaml")
+			contents := "dataentries:\n  1:\n    startbyte: 0\n    endbyte: 100\n"
+			Expect(os.WriteFile(filename, []byte(contents), 0644)).To(Succeed())
+
+			resultTOC := toc.NewSegmentTOC(filename)
+			Expect(resultTOC.Framed).To(BeFalse())
+			Expect(resultTOC.DataEntries).To(Equal(map[uint]toc.SegmentDataEntry{1: {StartByte: 0, EndByte: 100}}))
+		})
+	})
 })
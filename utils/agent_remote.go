@@ -121,7 +121,7 @@ func VerifyHelperVersionOnSegments(version string, c *cluster.Cluster) {
 	}
 }
 
-func StartGpbackupHelpers(c *cluster.Cluster, fpInfo filepath.FilePathInfo, operation string, pluginConfigFile string, compressStr string, onErrorContinue bool, isFilter bool, wasTerminated *bool, copyQueue int, isSingleDataFile bool, resizeCluster bool, origSize int, destSize int) {
+func StartGpbackupHelpers(c *cluster.Cluster, fpInfo filepath.FilePathInfo, operation string, pluginConfigFile string, compressStr string, onErrorContinue bool, isFilter bool, wasTerminated *bool, copyQueue int, isSingleDataFile bool, withChecksum bool, encryptionKeyFile string, resizeCluster bool, origSize int, destSize int) {
 	// A mutex lock for cleaning up and starting gpbackup helpers prevents a
 	// race condition that causes gpbackup_helpers to be orphaned if
 	// gpbackup_helper cleanup happens before they are started.
@@ -150,6 +150,14 @@ func StartGpbackupHelpers(c *cluster.Cluster, fpInfo filepath.FilePathInfo, oper
 	if isSingleDataFile {
 		singleDataFileStr = " --single-data-file"
 	}
+	checksumStr := ""
+	if withChecksum {
+		checksumStr = " --with-checksum"
+	}
+	encryptionStr := ""
+	if encryptionKeyFile != "" {
+		encryptionStr = fmt.Sprintf(" --encryption-key-file %s", encryptionKeyFile)
+	}
 	resizeStr := ""
 	if resizeCluster {
 		resizeStr = fmt.Sprintf(" --resize-cluster --orig-seg-count %d --dest-seg-count %d", origSize, destSize)
@@ -161,8 +169,8 @@ func StartGpbackupHelpers(c *cluster.Cluster, fpInfo filepath.FilePathInfo, oper
 		pipeFile := fpInfo.GetSegmentPipeFilePath(contentID)
 		backupFile := fpInfo.GetTableBackupFilePath(contentID, 0, GetPipeThroughProgram().Extension, true)
 		replicatedOidFile := fpInfo.GetSegmentHelperFilePath(contentID, "replicated_oid")
-		helperCmdStr := fmt.Sprintf(`gpbackup_helper %s --toc-file %s --oid-file %s --pipe-file %s --data-file "%s" --content %d%s%s%s%s%s%s --copy-queue-size %d --replication-file %s`,
-			operation, tocFile, oidFile, pipeFile, backupFile, contentID, pluginStr, compressStr, onErrorContinueStr, filterStr, singleDataFileStr, resizeStr, copyQueue, replicatedOidFile)
+		helperCmdStr := fmt.Sprintf(`gpbackup_helper %s --toc-file %s --oid-file %s --pipe-file %s --data-file "%s" --content %d%s%s%s%s%s%s%s%s --copy-queue-size %d --replication-file %s`,
+			operation, tocFile, oidFile, pipeFile, backupFile, contentID, pluginStr, compressStr, onErrorContinueStr, filterStr, singleDataFileStr, checksumStr, encryptionStr, resizeStr, copyQueue, replicatedOidFile)
 		// we run these commands in sequence to ensure that any failure is critical; the last command ensures the agent process was successfully started
 		return fmt.Sprintf(`cat << HEREDOC > %[1]s && chmod +x %[1]s && ( nohup %[1]s &> /dev/null &)
 #!/bin/bash
@@ -246,3 +254,21 @@ func CreateSkipFileOnSegments(oid string, tableName string, c *cluster.Cluster,
 		return fmt.Sprintf("Could not create skip file %s_skip_%s on segments", fpInfo.GetSegmentPipeFilePath(contentID), oid)
 	})
 }
+
+/*
+ * Removes the backup directory for the given timestamp on the coordinator and
+ * every segment.  Errors are logged rather than treated as fatal, so callers
+ * must check the return value before treating the backup as deleted.
+ */
+func DeleteBackupDirectoriesOnAllHosts(c *cluster.Cluster, fpInfo filepath.FilePathInfo) bool {
+	remoteOutput := c.GenerateAndExecuteCommand(fmt.Sprintf("Removing backup directories for timestamp %s", fpInfo.Timestamp),
+		cluster.ON_SEGMENTS|cluster.INCLUDE_COORDINATOR,
+		func(contentID int) string {
+			return fmt.Sprintf("rm -rf %s", fpInfo.GetDirForContent(contentID))
+		})
+	errMsg := fmt.Sprintf("Unable to remove backup directories for timestamp %s", fpInfo.Timestamp)
+	c.CheckClusterError(remoteOutput, errMsg, func(contentID int) string {
+		return fmt.Sprintf("Unable to remove backup directory %s on segment %d on host %s", fpInfo.GetDirForContent(contentID), contentID, c.GetHostForContent(contentID))
+	}, true)
+	return remoteOutput.NumErrors == 0
+}
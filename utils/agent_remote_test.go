@@ -126,18 +126,32 @@ var _ = Describe("agent remote", func() {
 	Describe("StartGpbackupHelpers()", func() {
 		It("Correctly propagates --on-error-continue flag to gpbackup_helper", func() {
 			wasTerminated := false
-			utils.StartGpbackupHelpers(testCluster, fpInfo, "operation", "/tmp/pluginConfigFile.yml", " compressStr", true, false, &wasTerminated, 1, true, false, 0, 0)
+			utils.StartGpbackupHelpers(testCluster, fpInfo, "operation", "/tmp/pluginConfigFile.yml", " compressStr", true, false, &wasTerminated, 1, true, false, "", false, 0, 0)
 
 			cc := testExecutor.ClusterCommands[0]
 			Expect(cc[1].CommandString).To(ContainSubstring(" --on-error-continue"))
 		})
 		It("Correctly propagates --copy-queue-size value to gpbackup_helper", func() {
 			wasTerminated := false
-			utils.StartGpbackupHelpers(testCluster, fpInfo, "operation", "/tmp/pluginConfigFile.yml", " compressStr", false, false, &wasTerminated, 4, true, false, 0, 0)
+			utils.StartGpbackupHelpers(testCluster, fpInfo, "operation", "/tmp/pluginConfigFile.yml", " compressStr", false, false, &wasTerminated, 4, true, false, "", false, 0, 0)
 
 			cc := testExecutor.ClusterCommands[0]
 			Expect(cc[1].CommandString).To(ContainSubstring(" --copy-queue-size 4"))
 		})
+		It("Correctly propagates --with-checksum flag to gpbackup_helper", func() {
+			wasTerminated := false
+			utils.StartGpbackupHelpers(testCluster, fpInfo, "operation", "/tmp/pluginConfigFile.yml", " compressStr", false, false, &wasTerminated, 1, true, true, "", false, 0, 0)
+
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc[1].CommandString).To(ContainSubstring(" --single-data-file --with-checksum"))
+		})
+		It("Correctly propagates --encryption-key-file value to gpbackup_helper", func() {
+			wasTerminated := false
+			utils.StartGpbackupHelpers(testCluster, fpInfo, "operation", "", " compressStr", false, false, &wasTerminated, 1, true, false, "/home/gpadmin/backup.key", false, 0, 0)
+
+			cc := testExecutor.ClusterCommands[0]
+			Expect(cc[1].CommandString).To(ContainSubstring(" --encryption-key-file /home/gpadmin/backup.key"))
+		})
 	})
 	Describe("CheckAgentErrorsOnSegments", func() {
 		It("constructs the correct ssh call to check for the existance of an error file on each segment", func() {
@@ -155,6 +169,25 @@ var _ = Describe("agent remote", func() {
 		})
 
 	})
+	Describe("DeleteBackupDirectoriesOnAllHosts", func() {
+		It("removes the timestamp directory on the coordinator and every segment", func() {
+			success := utils.DeleteBackupDirectoriesOnAllHosts(testCluster, fpInfo)
+			Expect(success).To(BeTrue())
+
+			cc := testExecutor.ClusterCommands[0]
+			Expect(len(cc)).To(Equal(3))
+			Expect(cc[0].CommandString).To(ContainSubstring("rm -rf /data/gpseg-1/backups/11112233/11112233445566"))
+			Expect(cc[1].CommandString).To(ContainSubstring("rm -rf /data/gpseg0/backups/11112233/11112233445566"))
+			Expect(cc[2].CommandString).To(ContainSubstring("rm -rf /data/gpseg1/backups/11112233/11112233445566"))
+		})
+		It("reports failure without panicking when a segment cannot be cleaned up", func() {
+			remoteOutput.NumErrors = 1
+			remoteOutput.FailedCommands = []*cluster.ShellCommand{{Content: 1, Host: "remotehost1", Stderr: "permission denied"}}
+
+			success := utils.DeleteBackupDirectoriesOnAllHosts(testCluster, fpInfo)
+			Expect(success).To(BeFalse())
+		})
+	})
 })
 
 type testWriter struct {
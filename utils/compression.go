@@ -33,6 +33,17 @@ func InitializePipeThroughParameters(compress bool, compressionType string, comp
 		pipeThroughProgram = PipeThroughProgram{Name: "zstd", OutputCommand: fmt.Sprintf("zstd --compress -%d -c", compressionLevel), InputCommand: "zstd --decompress -c", Extension: ".zst"}
 		return
 	}
+
+	if compressionType == "lz4" {
+		pipeThroughProgram = PipeThroughProgram{Name: "lz4", OutputCommand: fmt.Sprintf("lz4 -c -%d", compressionLevel), InputCommand: "lz4 -d -c", Extension: ".lz4"}
+		return
+	}
+
+	// Data is not compressed, but single data files are still written in per-table frames
+	if compressionType == "none" {
+		pipeThroughProgram = PipeThroughProgram{Name: "none", OutputCommand: "cat -", InputCommand: "cat -", Extension: ".raw"}
+		return
+	}
 }
 
 func GetPipeThroughProgram() PipeThroughProgram {
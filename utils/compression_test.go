@@ -69,5 +69,31 @@ var _ = Describe("utils/compression tests", func() {
 			resultProgram := utils.GetPipeThroughProgram()
 			structmatcher.ExpectStructsToMatch(&expectedProgram, &resultProgram)
 		})
+		It("initializes to use lz4 when passed compression type lz4 and a level", func() {
+			originalProgram := utils.GetPipeThroughProgram()
+			defer utils.SetPipeThroughProgram(originalProgram)
+			expectedProgram := utils.PipeThroughProgram{
+				Name:          "lz4",
+				OutputCommand: "lz4 -c -7",
+				InputCommand:  "lz4 -d -c",
+				Extension:     ".lz4",
+			}
+			utils.InitializePipeThroughParameters(true, "lz4", 7)
+			resultProgram := utils.GetPipeThroughProgram()
+			structmatcher.ExpectStructsToMatch(&expectedProgram, &resultProgram)
+		})
+		It("initializes to pass data through uncompressed when passed compression type none", func() {
+			originalProgram := utils.GetPipeThroughProgram()
+			defer utils.SetPipeThroughProgram(originalProgram)
+			expectedProgram := utils.PipeThroughProgram{
+				Name:          "none",
+				OutputCommand: "cat -",
+				InputCommand:  "cat -",
+				Extension:     ".raw",
+			}
+			utils.InitializePipeThroughParameters(true, "none", 1)
+			resultProgram := utils.GetPipeThroughProgram()
+			structmatcher.ExpectStructsToMatch(&expectedProgram, &resultProgram)
+		})
 	})
 })
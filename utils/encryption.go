@@ -0,0 +1,337 @@
+package utils
+
+/*
+ * This file contains the streaming AES-GCM encryption used for backups taken
+ * with --encryption-key-file.
+ */
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/cluster"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+)
+
+/*
+ * An encrypted file starts with a header holding encryptionMagic, the key
+ * fingerprint and a random nonce prefix, followed by chunks of at most
+ * encryptionChunkSize plaintext bytes.  Each chunk is preceded by the length
+ * of its ciphertext, with the high bit set on the last chunk.  The length is
+ * authenticated along with the chunk, so a truncated file fails to decrypt
+ * instead of being restored short.
+ */
+const (
+	encryptionMagic     = "GPBKENC1"
+	encryptionKeySize   = 32
+	encryptionChunkSize = 64 * 1024
+	fingerprintSize     = 8
+	noncePrefixSize     = 8
+	finalChunkFlag      = uint32(1) << 31
+)
+
+var encryptionKey []byte
+
+func SetEncryptionKey(key []byte) {
+	encryptionKey = key
+}
+
+func GetEncryptionKey() []byte {
+	return encryptionKey
+}
+
+// The key file holds a 256-bit key written as 64 hexadecimal characters
+func ReadEncryptionKeyFile(filename string) ([]byte, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Errorf("Unable to read encryption key file %s: %v", filename, err)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(contents)))
+	if err != nil || len(key) != encryptionKeySize {
+		return nil, errors.Errorf("Encryption key file %s must contain a %d-bit key written as %d hexadecimal characters",
+			filename, encryptionKeySize*8, encryptionKeySize*2)
+	}
+	return key, nil
+}
+
+func GetKeyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:fingerprintSize])
+}
+
+func newEncryptionCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(noncePrefix []byte, counter uint32) []byte {
+	nonce := make([]byte, noncePrefixSize+4)
+	copy(nonce, noncePrefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	return nonce
+}
+
+type EncryptingWriter struct {
+	writeHandle io.WriteCloser
+	aead        cipher.AEAD
+	noncePrefix []byte
+	counter     uint32
+	buffer      []byte
+}
+
+func NewEncryptingWriter(writeHandle io.WriteCloser, key []byte) (*EncryptingWriter, error) {
+	aead, err := newEncryptionCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	noncePrefix := make([]byte, noncePrefixSize)
+	_, err = rand.Read(noncePrefix)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, _ := hex.DecodeString(GetKeyFingerprint(key))
+	header := append([]byte(encryptionMagic), fingerprint...)
+	header = append(header, noncePrefix...)
+	_, err = writeHandle.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptingWriter{
+		writeHandle: writeHandle,
+		aead:        aead,
+		noncePrefix: noncePrefix,
+		buffer:      make([]byte, 0, encryptionChunkSize),
+	}, nil
+}
+
+func (writer *EncryptingWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, since the last chunk must be marked as such
+		if len(writer.buffer) == encryptionChunkSize {
+			err = writer.writeChunk(false)
+			if err != nil {
+				return n, err
+			}
+		}
+		copied := copy(writer.buffer[len(writer.buffer):encryptionChunkSize], p)
+		writer.buffer = writer.buffer[:len(writer.buffer)+copied]
+		p = p[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+func (writer *EncryptingWriter) writeChunk(final bool) error {
+	if writer.counter == math.MaxUint32 {
+		return errors.New("Too much data to encrypt with a single nonce prefix")
+	}
+	length := uint32(len(writer.buffer) + writer.aead.Overhead())
+	if final {
+		length |= finalChunkFlag
+	}
+	chunk := make([]byte, 4, 4+len(writer.buffer)+writer.aead.Overhead())
+	binary.BigEndian.PutUint32(chunk, length)
+	chunk = writer.aead.Seal(chunk, chunkNonce(writer.noncePrefix, writer.counter), writer.buffer, chunk[:4])
+	_, err := writer.writeHandle.Write(chunk)
+	if err != nil {
+		return err
+	}
+	writer.counter++
+	writer.buffer = writer.buffer[:0]
+	return nil
+}
+
+// Seals the remaining data as the last chunk before closing the underlying handle
+func (writer *EncryptingWriter) Close() error {
+	err := writer.writeChunk(true)
+	closeErr := writer.writeHandle.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+type DecryptingReader struct {
+	readHandle  io.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	counter     uint32
+	plaintext   []byte
+	finished    bool
+}
+
+/*
+ * The key fingerprint is checked before any data is read, so a wrong key
+ * fails immediately with a clear message rather than as a corrupt chunk.
+ */
+func NewDecryptingReader(readHandle io.Reader, key []byte) (*DecryptingReader, error) {
+	header := make([]byte, len(encryptionMagic)+fingerprintSize+noncePrefixSize)
+	_, err := io.ReadFull(readHandle, header)
+	if err != nil || string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, errors.New("Data was not encrypted with --encryption-key-file")
+	}
+	fingerprint := hex.EncodeToString(header[len(encryptionMagic) : len(encryptionMagic)+fingerprintSize])
+	if fingerprint != GetKeyFingerprint(key) {
+		return nil, errors.Errorf("Data was encrypted with the key with fingerprint %s, but the given key has fingerprint %s", fingerprint, GetKeyFingerprint(key))
+	}
+	aead, err := newEncryptionCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &DecryptingReader{
+		readHandle:  readHandle,
+		aead:        aead,
+		noncePrefix: header[len(encryptionMagic)+fingerprintSize:],
+	}, nil
+}
+
+func (reader *DecryptingReader) Read(p []byte) (int, error) {
+	for len(reader.plaintext) == 0 {
+		if reader.finished {
+			return 0, io.EOF
+		}
+		err := reader.readChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, reader.plaintext)
+	reader.plaintext = reader.plaintext[n:]
+	return n, nil
+}
+
+func (reader *DecryptingReader) readChunk() error {
+	lengthBytes := make([]byte, 4)
+	_, err := io.ReadFull(reader.readHandle, lengthBytes)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("Encrypted data is truncated")
+	} else if err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint32(lengthBytes)
+	final := length&finalChunkFlag != 0
+	length &^= finalChunkFlag
+	if length < uint32(reader.aead.Overhead()) || length > uint32(encryptionChunkSize+reader.aead.Overhead()) {
+		return errors.New("Encrypted data is corrupt")
+	}
+	ciphertext := make([]byte, length)
+	_, err = io.ReadFull(reader.readHandle, ciphertext)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("Encrypted data is truncated")
+	} else if err != nil {
+		return err
+	}
+	reader.plaintext, err = reader.aead.Open(ciphertext[:0], chunkNonce(reader.noncePrefix, reader.counter), ciphertext, lengthBytes)
+	if err != nil {
+		return errors.New("Encrypted data is corrupt or was modified")
+	}
+	reader.counter++
+	reader.finished = final
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func EncryptBytes(plaintext []byte, key []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer, err := NewEncryptingWriter(nopWriteCloser{&buffer}, key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = writer.Write(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func DecryptBytes(ciphertext []byte, key []byte) ([]byte, error) {
+	reader, err := NewDecryptingReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(reader)
+}
+
+func IsEncrypted(contents []byte) bool {
+	return bytes.HasPrefix(contents, []byte(encryptionMagic))
+}
+
+/*
+ * Replaces a plaintext file written by gpbackup with its encrypted form.  The
+ * encrypted file is written alongside and renamed over the original, so the
+ * file is never left partially encrypted.
+ */
+func EncryptFile(filename string, key []byte) error {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	encrypted, err := EncryptBytes(contents, key)
+	if err != nil {
+		return errors.Errorf("Unable to encrypt %s: %v", filename, err)
+	}
+	tempFilename := filename + ".encrypting"
+	err = WriteToFileAndMakeReadOnly(tempFilename, encrypted)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFilename, filename)
+}
+
+/*
+ * Reads a backup file, decrypting it with the key given to
+ * SetEncryptionKey if the backup was taken with --encryption-key-file.
+ */
+func ReadBackupFile(filename string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil || !IsEncrypted(contents) {
+		return contents, err
+	}
+	if encryptionKey == nil {
+		return nil, errors.Errorf("File %s is encrypted. Use --encryption-key-file to provide the key used for the backup", filename)
+	}
+	contents, err = DecryptBytes(contents, encryptionKey)
+	if err != nil {
+		return nil, errors.Errorf("Unable to decrypt %s: %v", filename, err)
+	}
+	return contents, nil
+}
+
+/*
+ * gpbackup_helper reads the key file on each segment host rather than having
+ * the key copied around the cluster, so it must already exist on every host.
+ */
+func CheckEncryptionKeyFileOnAllHosts(c *cluster.Cluster, keyFile string) {
+	remoteOutput := c.GenerateAndExecuteCommand("Checking encryption key file on all hosts", cluster.ON_HOSTS, func(contentID int) string {
+		return fmt.Sprintf("test -r %s", keyFile)
+	})
+	c.CheckClusterError(remoteOutput, "Encryption key file not readable on all hosts", func(contentID int) string {
+		return fmt.Sprintf("Encryption key file %s not readable on host %s", keyFile, c.GetHostForContent(contentID))
+	})
+}
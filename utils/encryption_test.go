@@ -0,0 +1,132 @@
+package utils_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/encryption tests", func() {
+	key := bytes.Repeat([]byte{0x01}, 32)
+	otherKey := bytes.Repeat([]byte{0x02}, 32)
+
+	Describe("ReadEncryptionKeyFile", func() {
+		AfterEach(func() {
+			operating.System = operating.InitializeSystemFunctions()
+		})
+		It("reads a hex-encoded 256-bit key, ignoring surrounding whitespace", func() {
+			operating.System.ReadFile = func(filename string) ([]byte, error) {
+				return []byte(strings.Repeat("01", 32) + "\n"), nil
+			}
+			readKey, err := utils.ReadEncryptionKeyFile("/tmp/key")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readKey).To(Equal(key))
+		})
+		It("rejects a key of the wrong length", func() {
+			operating.System.ReadFile = func(filename string) ([]byte, error) {
+				return []byte(strings.Repeat("01", 16)), nil
+			}
+			_, err := utils.ReadEncryptionKeyFile("/tmp/key")
+			Expect(err).To(MatchError("Encryption key file /tmp/key must contain a 256-bit key written as 64 hexadecimal characters"))
+		})
+		It("rejects a key that is not hexadecimal", func() {
+			operating.System.ReadFile = func(filename string) ([]byte, error) {
+				return []byte(strings.Repeat("zz", 32)), nil
+			}
+			_, err := utils.ReadEncryptionKeyFile("/tmp/key")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Describe("EncryptBytes and DecryptBytes", func() {
+		It("round trips empty data", func() {
+			encrypted, err := utils.EncryptBytes([]byte{}, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(utils.IsEncrypted(encrypted)).To(BeTrue())
+
+			decrypted, err := utils.DecryptBytes(encrypted, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(BeEmpty())
+		})
+		It("round trips data spanning several chunks", func() {
+			plaintext := bytes.Repeat([]byte("gpbackup"), 40000)
+			encrypted, err := utils.EncryptBytes(plaintext, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bytes.Contains(encrypted, []byte("gpbackupgpbackup"))).To(BeFalse())
+
+			decrypted, err := utils.DecryptBytes(encrypted, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decrypted).To(Equal(plaintext))
+		})
+		It("fails immediately with the wrong key", func() {
+			encrypted, _ := utils.EncryptBytes([]byte("data"), key)
+			_, err := utils.DecryptBytes(encrypted, otherKey)
+			Expect(err).To(MatchError(ContainSubstring("Data was encrypted with the key with fingerprint " + utils.GetKeyFingerprint(key))))
+		})
+		It("detects truncated data", func() {
+			plaintext := bytes.Repeat([]byte("gpbackup"), 40000)
+			encrypted, _ := utils.EncryptBytes(plaintext, key)
+			_, err := utils.DecryptBytes(encrypted[:len(encrypted)/2], key)
+			Expect(err).To(MatchError("Encrypted data is truncated"))
+		})
+		It("detects data that was dropped at a chunk boundary", func() {
+			plaintext := bytes.Repeat([]byte("a"), 64*1024+10)
+			encrypted, _ := utils.EncryptBytes(plaintext, key)
+			// header, then the length and ciphertext of the first full chunk
+			firstChunkEnd := 24 + 4 + 64*1024 + 16
+			_, err := utils.DecryptBytes(encrypted[:firstChunkEnd], key)
+			Expect(err).To(MatchError("Encrypted data is truncated"))
+		})
+		It("detects modified data", func() {
+			encrypted, _ := utils.EncryptBytes([]byte("some table data"), key)
+			encrypted[len(encrypted)-1] ^= 0xFF
+			_, err := utils.DecryptBytes(encrypted, key)
+			Expect(err).To(MatchError("Encrypted data is corrupt or was modified"))
+		})
+	})
+	Describe("ReadBackupFile", func() {
+		var tempDir string
+		BeforeEach(func() {
+			tempDir, _ = ioutil.TempDir("", "encryption_test")
+		})
+		AfterEach(func() {
+			utils.SetEncryptionKey(nil)
+			_ = os.RemoveAll(tempDir)
+		})
+		It("returns an unencrypted file as is", func() {
+			filename := tempDir + "/plain"
+			_ = ioutil.WriteFile(filename, []byte("plain contents"), 0644)
+			contents, err := utils.ReadBackupFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("plain contents"))
+		})
+		It("decrypts a file encrypted with EncryptFile", func() {
+			filename := tempDir + "/encrypted"
+			_ = ioutil.WriteFile(filename, []byte("secret contents"), 0644)
+			Expect(utils.EncryptFile(filename, key)).To(Succeed())
+			onDisk, _ := ioutil.ReadFile(filename)
+			Expect(utils.IsEncrypted(onDisk)).To(BeTrue())
+			info, _ := os.Stat(filename)
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0444)))
+
+			utils.SetEncryptionKey(key)
+			contents, err := utils.ReadBackupFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("secret contents"))
+		})
+		It("asks for the key when reading an encrypted file without one", func() {
+			filename := tempDir + "/encrypted"
+			_ = ioutil.WriteFile(filename, []byte("secret contents"), 0644)
+			Expect(utils.EncryptFile(filename, key)).To(Succeed())
+
+			_, err := utils.ReadBackupFile(filename)
+			Expect(err).To(MatchError(ContainSubstring("is encrypted. Use --encryption-key-file")))
+		})
+	})
+})
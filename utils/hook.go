@@ -0,0 +1,184 @@
+package utils
+
+/*
+ * This file contains structs and functions for running user-defined hooks on
+ * the coordinator before and after each phase of a backup or restore, and when
+ * a backup or restore fails.
+ */
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+var HookPhases = []string{"globals", "predata", "data", "postdata", "statistics"}
+
+/*
+ * A hook is run for the events of the utility under which it is listed, such
+ * as before_globals or after_data, or for the failure event.  A hook that fails
+ * only logs a warning unless AbortOnFailure is set, in which case the backup or
+ * restore fails as well.  A Timeout of 0 lets the hook run for as long as it
+ * needs to.
+ */
+type Hook struct {
+	Command        string        `yaml:"command"`
+	AbortOnFailure bool          `yaml:"abort_on_failure"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+type HookConfig struct {
+	Hooks map[string]map[string][]Hook `yaml:"hooks"`
+}
+
+/*
+ * The information passed to hooks in GP_HOOK_* environment variables
+ */
+type HookContext struct {
+	Utility          string
+	Timestamp        string
+	RestoreTimestamp string
+	Database         string
+	BackupDir        string
+}
+
+func isValidHookEvent(event string) bool {
+	if event == "failure" {
+		return true
+	}
+	for _, phase := range HookPhases {
+		if event == "before_"+phase || event == "after_"+phase {
+			return true
+		}
+	}
+	return false
+}
+
+func ReadHookConfig(filename string) (*HookConfig, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := &HookConfig{}
+	err = yaml.UnmarshalStrict(contents, config)
+	if err != nil {
+		return nil, errors.Errorf("Unable to parse hook config file %s: %v", filename, err)
+	}
+	for utility, events := range config.Hooks {
+		if utility != "gpbackup" && utility != "gprestore" {
+			return nil, errors.Errorf("Invalid utility %s in hook config file %s; valid utilities are gpbackup and gprestore", utility, filename)
+		}
+		for event, hooks := range events {
+			if !isValidHookEvent(event) {
+				return nil, errors.Errorf("Invalid event %s for %s in hook config file %s; valid events are failure and before_ or after_ followed by one of globals, predata, data, postdata, or statistics",
+					event, utility, filename)
+			}
+			for i, hook := range hooks {
+				if hook.Command == "" {
+					return nil, errors.Errorf("Hook %d for %s %s in hook config file %s has no command", i+1, utility, event, filename)
+				}
+				if hook.Timeout < 0 {
+					return nil, errors.Errorf("Hook %d for %s %s in hook config file %s cannot have a negative timeout", i+1, utility, event, filename)
+				}
+			}
+		}
+	}
+	return config, nil
+}
+
+/*
+ * Runs a user-supplied command with bash, adding env to the environment of
+ * gpbackup or gprestore, and kills it if it has not finished within timeout.
+ * A timeout of 0 lets the command run for as long as it needs to.  Returns
+ * the combined stdout and stderr of the command.  This is used for both hooks
+ * and notification commands.
+ */
+func RunCommandWithTimeout(command string, env []string, stdin io.Reader, timeout time.Duration) ([]byte, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	// Children of the command may keep its output open after it is killed
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, errors.Errorf("timed out after %s", timeout)
+	}
+	return output, err
+}
+
+func (hook Hook) run(env []string) error {
+	output, err := RunCommandWithTimeout(hook.Command, env, nil, hook.Timeout)
+	if len(output) > 0 {
+		gplog.Verbose("Output of hook %s: %s", hook.Command, bytes.TrimSpace(output))
+	}
+	return err
+}
+
+/*
+ * Runs the hooks for an event in the order they are listed, stopping at the
+ * first hook that fails with AbortOnFailure set.  The status of a phase is
+ * "running" before it and "success" or "success_with_errors" after it, using
+ * the same classification as the report.  Hooks for the failure event are
+ * given a status of "failure".  A nil HookConfig runs nothing.
+ */
+func (config *HookConfig) RunHooks(hookContext HookContext, event string, phase string) error {
+	if config == nil {
+		return nil
+	}
+	eventName := event
+	status := "failure"
+	if event == "before" {
+		eventName = event + "_" + phase
+		status = "running"
+	} else if event == "after" {
+		eventName = event + "_" + phase
+		status = "success"
+		if gplog.GetErrorCode() == 1 {
+			status = "success_with_errors"
+		}
+	}
+	hooks := config.Hooks[hookContext.Utility][eventName]
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	env := []string{
+		fmt.Sprintf("GP_HOOK_UTILITY=%s", hookContext.Utility),
+		fmt.Sprintf("GP_HOOK_EVENT=%s", eventName),
+		fmt.Sprintf("GP_HOOK_PHASE=%s", phase),
+		fmt.Sprintf("GP_HOOK_STATUS=%s", status),
+		fmt.Sprintf("GP_HOOK_TIMESTAMP=%s", hookContext.Timestamp),
+		fmt.Sprintf("GP_HOOK_DATABASE=%s", hookContext.Database),
+		fmt.Sprintf("GP_HOOK_BACKUP_DIR=%s", hookContext.BackupDir),
+	}
+	if hookContext.RestoreTimestamp != "" {
+		env = append(env, fmt.Sprintf("GP_HOOK_RESTORE_TIMESTAMP=%s", hookContext.RestoreTimestamp))
+	}
+	for _, hook := range hooks {
+		gplog.Info("Running %s hook %s", eventName, hook.Command)
+		err := hook.run(env)
+		if err == nil {
+			continue
+		}
+		if hook.AbortOnFailure {
+			return errors.Errorf("The %s hook %s failed: %v", eventName, hook.Command, err)
+		}
+		gplog.Warn("The %s hook %s failed: %v", eventName, hook.Command, err)
+	}
+	return nil
+}
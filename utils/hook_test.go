@@ -0,0 +1,192 @@
+package utils_test
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cloudberrydb/gp-common-go-libs/gplog"
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("utils/hook tests", func() {
+	var tempDir, configFilename, outputFilename string
+	hookContext := utils.HookContext{
+		Utility:   "gpbackup",
+		Timestamp: "20170101010101",
+		Database:  "testdb",
+		BackupDir: "/data/gpseg-1/backups/20170101/20170101010101",
+	}
+	writeConfig := func(contents string) {
+		Expect(os.WriteFile(configFilename, []byte(contents), 0644)).To(Succeed())
+	}
+	readOutput := func() string {
+		output, err := os.ReadFile(outputFilename)
+		Expect(err).ToNot(HaveOccurred())
+		return string(output)
+	}
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		tempDir = GinkgoT().TempDir()
+		configFilename = path.Join(tempDir, "hooks.yaml")
+		outputFilename = path.Join(tempDir, "output")
+	})
+	AfterEach(func() {
+		gplog.SetErrorCode(0)
+	})
+	Describe("ReadHookConfig", func() {
+		It("reads the hooks of each utility and event", func() {
+			writeConfig(`hooks:
+  gpbackup:
+    before_globals:
+    - command: /home/gpadmin/quiesce_etl.sh
+      abort_on_failure: true
+      timeout: 10m
+    failure:
+    - command: /home/gpadmin/page.sh
+  gprestore:
+    after_data:
+    - command: /home/gpadmin/start_jobs.sh`)
+			config, err := utils.ReadHookConfig(configFilename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Hooks["gpbackup"]["before_globals"]).To(HaveLen(1))
+			Expect(config.Hooks["gpbackup"]["before_globals"][0].AbortOnFailure).To(BeTrue())
+			Expect(config.Hooks["gpbackup"]["before_globals"][0].Timeout.Minutes()).To(Equal(10.0))
+			Expect(config.Hooks["gpbackup"]["failure"][0].Command).To(Equal("/home/gpadmin/page.sh"))
+			Expect(config.Hooks["gprestore"]["after_data"][0].Command).To(Equal("/home/gpadmin/start_jobs.sh"))
+		})
+		It("returns an error for an invalid event", func() {
+			writeConfig(`hooks:
+  gpbackup:
+    before_backup:
+    - command: echo`)
+			_, err := utils.ReadHookConfig(configFilename)
+			Expect(err).To(MatchError(ContainSubstring("Invalid event before_backup for gpbackup")))
+		})
+		It("returns an error for an invalid utility", func() {
+			writeConfig(`hooks:
+  gpbackup_manager:
+    failure:
+    - command: echo`)
+			_, err := utils.ReadHookConfig(configFilename)
+			Expect(err).To(MatchError(ContainSubstring("Invalid utility gpbackup_manager")))
+		})
+		It("returns an error for a hook without a command", func() {
+			writeConfig(`hooks:
+  gprestore:
+    after_data:
+    - abort_on_failure: true`)
+			_, err := utils.ReadHookConfig(configFilename)
+			Expect(err).To(MatchError(ContainSubstring("Hook 1 for gprestore after_data in hook config file " + configFilename + " has no command")))
+		})
+	})
+	Describe("RunHooks", func() {
+		It("runs the hooks of an event in order with the hook environment", func() {
+			writeConfig(fmt.Sprintf(`hooks:
+  gpbackup:
+    before_data:
+    - command: 'echo "$GP_HOOK_EVENT $GP_HOOK_PHASE $GP_HOOK_STATUS" >> %[1]s'
+    - command: 'echo "$GP_HOOK_UTILITY $GP_HOOK_TIMESTAMP $GP_HOOK_DATABASE $GP_HOOK_BACKUP_DIR" >> %[1]s'
+    after_data:
+    - command: 'echo "$GP_HOOK_EVENT" >> %[1]s'`, outputFilename))
+			config, err := utils.ReadHookConfig(configFilename)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.RunHooks(hookContext, "before", "data")).To(Succeed())
+			Expect(readOutput()).To(Equal("before_data data running\ngpbackup 20170101010101 testdb /data/gpseg-1/backups/20170101/20170101010101\n"))
+		})
+		It("gives the status of a phase that completed with errors", func() {
+			writeConfig(fmt.Sprintf(`hooks:
+  gprestore:
+    after_data:
+    - command: 'echo "$GP_HOOK_STATUS $GP_HOOK_RESTORE_TIMESTAMP" > %s'`, outputFilename))
+			config, err := utils.ReadHookConfig(configFilename)
+			Expect(err).ToNot(HaveOccurred())
+			gplog.SetErrorCode(1)
+
+			restoreContext := hookContext
+			restoreContext.Utility = "gprestore"
+			restoreContext.RestoreTimestamp = "20170102010101"
+			Expect(config.RunHooks(restoreContext, "after", "data")).To(Succeed())
+			Expect(readOutput()).To(Equal("success_with_errors 20170102010101\n"))
+		})
+		It("gives a status of failure to failure hooks", func() {
+			writeConfig(fmt.Sprintf(`hooks:
+  gpbackup:
+    failure:
+    - command: 'echo "$GP_HOOK_EVENT $GP_HOOK_STATUS" > %s'`, outputFilename))
+			config, err := utils.ReadHookConfig(configFilename)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.RunHooks(hookContext, "failure", "")).To(Succeed())
+			Expect(readOutput()).To(Equal("failure failure\n"))
+		})
+		It("warns and continues when a hook fails", func() {
+			writeConfig(fmt.Sprintf(`hooks:
+  gpbackup:
+    after_predata:
+    - command: exit 3
+    - command: 'echo ran > %s'`, outputFilename))
+			config, err := utils.ReadHookConfig(configFilename)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(config.RunHooks(hookContext, "after", "predata")).To(Succeed())
+			Expect(logfile).To(Say(`The after_predata hook exit 3 failed: exit status 3`))
+			Expect(readOutput()).To(Equal("ran\n"))
+		})
+		It("returns an error and runs no further hooks when a hook set to abort fails", func() {
+			writeConfig(fmt.Sprintf(`hooks:
+  gpbackup:
+    before_globals:
+    - command: exit 3
+      abort_on_failure: true
+    - command: 'echo ran > %s'`, outputFilename))
+			config, err := utils.ReadHookConfig(configFilename)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = config.RunHooks(hookContext, "before", "globals")
+			Expect(err).To(MatchError("The before_globals hook exit 3 failed: exit status 3"))
+			Expect(outputFilename).ToNot(BeAnExistingFile())
+		})
+		It("returns an error when a hook set to abort times out", func() {
+			writeConfig(`hooks:
+  gpbackup:
+    before_globals:
+    - command: sleep 5
+      abort_on_failure: true
+      timeout: 10ms`)
+			config, err := utils.ReadHookConfig(configFilename)
+			Expect(err).ToNot(HaveOccurred())
+
+			err = config.RunHooks(hookContext, "before", "globals")
+			Expect(err).To(MatchError("The before_globals hook sleep 5 failed: timed out after 10ms"))
+		})
+		It("runs nothing for a nil config", func() {
+			var config *utils.HookConfig
+			Expect(config.RunHooks(hookContext, "before", "globals")).To(Succeed())
+		})
+	})
+	Describe("RunCommandWithTimeout", func() {
+		It("runs the command with the given environment and stdin and returns its output", func() {
+			output, err := utils.RunCommandWithTimeout(`echo "$GP_TEST_VAR"; cat`, []string{"GP_TEST_VAR=value"}, strings.NewReader("input"), 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(output)).To(Equal("value\ninput"))
+		})
+		It("returns the output of a command that fails", func() {
+			output, err := utils.RunCommandWithTimeout("echo failed >&2; exit 3", nil, nil, 0)
+			Expect(err).To(MatchError("exit status 3"))
+			Expect(string(output)).To(Equal("failed\n"))
+		})
+		It("kills a command that does not finish within the timeout", func() {
+			_, err := utils.RunCommandWithTimeout("sleep 5", nil, nil, 10*time.Millisecond)
+			Expect(err).To(MatchError("timed out after 10ms"))
+		})
+	})
+})
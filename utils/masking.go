@@ -0,0 +1,147 @@
+package utils
+
+/*
+ * This file contains structs and functions for reading the rules with which
+ * columns of table data are masked during a backup.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+ * A masking rule replaces the data of a column with NULL ("nullify"), with a
+ * fixed Value ("value"), with the MD5 hash of its text prefixed with a secret
+ * Salt ("hash"), or with the result of an SQL Expression ("expression").  The
+ * replacement is read back into the column by the standard restore, so it
+ * must be valid for the type of the column; hash is meant for text columns,
+ * and nullify cannot be used on a NOT NULL column.
+ *
+ * Without the salt, the values of a column with few possible values, such as
+ * email addresses or phone numbers, could be recovered by hashing candidate
+ * values and comparing the results, so hash requires one.
+ */
+type MaskingRule struct {
+	Rule       string `yaml:"rule"`
+	Value      string `yaml:"value,omitempty"`
+	Expression string `yaml:"expression,omitempty"`
+	Salt       string `yaml:"salt,omitempty"`
+}
+
+func (rule MaskingRule) validate() error {
+	if rule.Rule != "hash" && rule.Salt != "" {
+		return errors.Errorf("rule %s does not take a salt", rule.Rule)
+	}
+	switch rule.Rule {
+	case "nullify", "hash":
+		if rule.Value != "" || rule.Expression != "" {
+			return errors.Errorf("rule %s takes neither a value nor an expression", rule.Rule)
+		}
+		if rule.Rule == "hash" && rule.Salt == "" {
+			return errors.New("rule hash requires a salt")
+		}
+	case "value":
+		if rule.Expression != "" {
+			return errors.New("rule value does not take an expression")
+		}
+	case "expression":
+		if strings.TrimSpace(rule.Expression) == "" {
+			return errors.New("rule expression requires an expression")
+		}
+		if rule.Value != "" {
+			return errors.New("rule expression does not take a value")
+		}
+	default:
+		return errors.Errorf("invalid rule %q; valid rules are nullify, value, hash, and expression", rule.Rule)
+	}
+	return nil
+}
+
+/*
+ * Returns the expression selected in place of the given quoted column when
+ * copying out the data of its table.
+ */
+func (rule MaskingRule) Projection(column string) string {
+	switch rule.Rule {
+	case "nullify":
+		return fmt.Sprintf("NULL AS %s", column)
+	case "value":
+		return fmt.Sprintf("'%s' AS %s", EscapeSingleQuotes(rule.Value), column)
+	case "hash":
+		return fmt.Sprintf("md5('%s' || %s::text) AS %s", EscapeSingleQuotes(rule.Salt), column, column)
+	default:
+		return fmt.Sprintf("(%s) AS %s", rule.Expression, column)
+	}
+}
+
+/*
+ * The masking rules file maps the fully-qualified names of tables to the
+ * rules for their columns, for example:
+ *
+ *   public.customers:
+ *     email:
+ *       rule: hash
+ *       salt: 8c1f0e5d2b
+ *     name:
+ *       rule: value
+ *       value: REDACTED
+ *     phone:
+ *       rule: expression
+ *       expression: "'XXX-' || right(phone, 4)"
+ *     ssn:
+ *       rule: nullify
+ */
+func ReadMaskingRulesFile(filename string) (map[string]map[string]MaskingRule, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	maskingRules := make(map[string]map[string]MaskingRule)
+	err = yaml.UnmarshalStrict(contents, &maskingRules)
+	if err != nil {
+		return nil, errors.Errorf("Unable to parse masking rules file %s: %v", filename, err)
+	}
+	fqns := make([]string, 0, len(maskingRules))
+	for fqn := range maskingRules {
+		fqns = append(fqns, fqn)
+	}
+	sort.Strings(fqns)
+	err = ValidateFQNs(fqns)
+	if err != nil {
+		return nil, err
+	}
+	for _, fqn := range fqns {
+		if len(maskingRules[fqn]) == 0 {
+			return nil, errors.Errorf("Table %s in masking rules file %s has no columns to mask", fqn, filename)
+		}
+		for column, rule := range maskingRules[fqn] {
+			err = rule.validate()
+			if err != nil {
+				return nil, errors.Errorf("Invalid masking rule for column %s of table %s in masking rules file %s: %v", column, fqn, filename, err)
+			}
+		}
+	}
+	return maskingRules, nil
+}
+
+/*
+ * The salts of hash rules are secret, so they are left out of the masking
+ * rules recorded in the backup configuration.
+ */
+func MaskingRulesWithoutSalts(maskingRules map[string]map[string]MaskingRule) map[string]map[string]MaskingRule {
+	recordedRules := make(map[string]map[string]MaskingRule, len(maskingRules))
+	for fqn, columnRules := range maskingRules {
+		recordedRules[fqn] = make(map[string]MaskingRule, len(columnRules))
+		for column, rule := range columnRules {
+			rule.Salt = ""
+			recordedRules[fqn][column] = rule
+		}
+	}
+	return recordedRules
+}
@@ -0,0 +1,111 @@
+package utils_test
+
+import (
+	"os"
+	"path"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/masking tests", func() {
+	var filename string
+	writeFile := func(contents string) {
+		Expect(os.WriteFile(filename, []byte(contents), 0644)).To(Succeed())
+	}
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		filename = path.Join(GinkgoT().TempDir(), "masking_rules.yaml")
+	})
+	Describe("ReadMaskingRulesFile", func() {
+		It("reads the masking rules of each column", func() {
+			writeFile(`public.customers:
+  email:
+    rule: hash
+    salt: 8c1f0e5d2b
+  name:
+    rule: value
+    value: REDACTED
+  phone:
+    rule: expression
+    expression: "'XXX-' || right(phone, 4)"
+  ssn:
+    rule: nullify`)
+			maskingRules, err := utils.ReadMaskingRulesFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(maskingRules).To(Equal(map[string]map[string]utils.MaskingRule{
+				"public.customers": {
+					"email": {Rule: "hash", Salt: "8c1f0e5d2b"},
+					"name":  {Rule: "value", Value: "REDACTED"},
+					"phone": {Rule: "expression", Expression: "'XXX-' || right(phone, 4)"},
+					"ssn":   {Rule: "nullify"},
+				},
+			}))
+		})
+		It("returns an error for an invalid rule", func() {
+			writeFile(`public.customers:
+  email:
+    rule: scramble`)
+			_, err := utils.ReadMaskingRulesFile(filename)
+			Expect(err).To(MatchError(`Invalid masking rule for column email of table public.customers in masking rules file ` + filename +
+				`: invalid rule "scramble"; valid rules are nullify, value, hash, and expression`))
+		})
+		It("returns an error for an expression rule without an expression", func() {
+			writeFile(`public.customers:
+  phone:
+    rule: expression`)
+			_, err := utils.ReadMaskingRulesFile(filename)
+			Expect(err).To(MatchError(ContainSubstring("rule expression requires an expression")))
+		})
+		It("returns an error for a hash rule without a salt", func() {
+			writeFile(`public.customers:
+  email:
+    rule: hash`)
+			_, err := utils.ReadMaskingRulesFile(filename)
+			Expect(err).To(MatchError(ContainSubstring("rule hash requires a salt")))
+		})
+		It("returns an error for a salt on a rule other than hash", func() {
+			writeFile(`public.customers:
+  ssn:
+    rule: nullify
+    salt: 8c1f0e5d2b`)
+			_, err := utils.ReadMaskingRulesFile(filename)
+			Expect(err).To(MatchError(ContainSubstring("rule nullify does not take a salt")))
+		})
+		It("returns an error for a table with no columns to mask", func() {
+			writeFile(`public.customers: {}`)
+			_, err := utils.ReadMaskingRulesFile(filename)
+			Expect(err).To(MatchError("Table public.customers in masking rules file " + filename + " has no columns to mask"))
+		})
+		It("returns an error for a table that is not fully-qualified", func() {
+			writeFile(`customers:
+  email:
+    rule: hash
+    salt: 8c1f0e5d2b`)
+			_, err := utils.ReadMaskingRulesFile(filename)
+			Expect(err).To(MatchError(ContainSubstring(`Table "customers" is not correctly fully-qualified`)))
+		})
+	})
+	Describe("MaskingRule.Projection", func() {
+		It("projects each rule in place of the column", func() {
+			Expect(utils.MaskingRule{Rule: "nullify"}.Projection(`"SSN"`)).To(Equal(`NULL AS "SSN"`))
+			Expect(utils.MaskingRule{Rule: "value", Value: "it's"}.Projection("name")).To(Equal(`'it''s' AS name`))
+			Expect(utils.MaskingRule{Rule: "hash", Salt: "it's"}.Projection("email")).To(Equal(`md5('it''s' || email::text) AS email`))
+			Expect(utils.MaskingRule{Rule: "expression", Expression: "left(phone, 3)"}.Projection("phone")).To(Equal(`(left(phone, 3)) AS phone`))
+		})
+	})
+	Describe("MaskingRulesWithoutSalts", func() {
+		It("leaves the salts out of the rules", func() {
+			maskingRules := map[string]map[string]utils.MaskingRule{
+				"public.customers": {"email": {Rule: "hash", Salt: "8c1f0e5d2b"}, "ssn": {Rule: "nullify"}},
+			}
+			Expect(utils.MaskingRulesWithoutSalts(maskingRules)).To(Equal(map[string]map[string]utils.MaskingRule{
+				"public.customers": {"email": {Rule: "hash"}, "ssn": {Rule: "nullify"}},
+			}))
+			Expect(maskingRules["public.customers"]["email"].Salt).To(Equal("8c1f0e5d2b"))
+		})
+	})
+})
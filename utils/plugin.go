@@ -96,6 +96,16 @@ func (plugin *PluginConfig) MustRestoreFile(filenamePath string) {
 	gplog.FatalOnError(err, string(output))
 }
 
+func (plugin *PluginConfig) DeleteBackup(timestamp string) error {
+	command := fmt.Sprintf("%s delete_backup %s %s", plugin.ExecutablePath, plugin.ConfigPath, timestamp)
+	gplog.Debug("%s", command)
+	output, err := exec.Command("bash", "-c", command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ERROR: Plugin failed to delete backup %s. %s", timestamp, string(output))
+	}
+	return nil
+}
+
 func (plugin *PluginConfig) CheckPluginExistsOnAllHosts(c *cluster.Cluster) string {
 	plugin.checkPluginAPIVersion(c)
 
@@ -0,0 +1,105 @@
+package utils
+
+/*
+ * This file contains structs and functions for reading the schemas and tables
+ * to which the objects of a backup are remapped during a restore.
+ */
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+ * The remap file maps the names of schemas, and the fully-qualified names of
+ * tables, in the backup to the names they are restored under, for example:
+ *
+ *   schemas:
+ *     sales: sales_staging
+ *   tables:
+ *     public.customers: staging.customers_copy
+ *
+ * Names are given as they would be written in SQL, so a name that needs to
+ * be quoted in SQL is quoted in the file as well.
+ */
+type RemapConfig struct {
+	Schemas map[string]string `yaml:"schemas"`
+	Tables  map[string]string `yaml:"tables"`
+}
+
+func ReadRemapFile(filename string) (RemapConfig, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return RemapConfig{}, err
+	}
+	remapConfig := RemapConfig{}
+	err = yaml.UnmarshalStrict(contents, &remapConfig)
+	if err != nil {
+		return RemapConfig{}, errors.Errorf("Unable to parse remap file %s: %v", filename, err)
+	}
+	if len(remapConfig.Schemas) == 0 && len(remapConfig.Tables) == 0 {
+		return RemapConfig{}, errors.Errorf("Remap file %s does not remap any schemas or tables", filename)
+	}
+	for oldSchema, newSchema := range remapConfig.Schemas {
+		for _, schema := range []string{oldSchema, newSchema} {
+			if schema == "" || strings.Contains(schema, ".") {
+				return RemapConfig{}, errors.Errorf(`Schema "%s" in remap file %s is invalid.  Please ensure the schema is not empty and does not contain a dot (.).`, schema, filename)
+			}
+		}
+	}
+	fqns := make([]string, 0, 2*len(remapConfig.Tables))
+	for oldFQN, newFQN := range remapConfig.Tables {
+		fqns = append(fqns, oldFQN, newFQN)
+	}
+	sort.Strings(fqns)
+	err = ValidateFQNs(fqns)
+	if err != nil {
+		return RemapConfig{}, err
+	}
+	return remapConfig, nil
+}
+
+/*
+ * The role and tablespace map files map the names of roles, or of
+ * tablespaces, in the backup to the names of existing roles or tablespaces
+ * to use in their place, for example:
+ *
+ *   prod_owner: staging_owner
+ *   '"Prod Admin"': staging_admin
+ *
+ * As in the remap file, names are given as they would be written in SQL.
+ */
+func ReadRoleMapFile(filename string) (map[string]string, error) {
+	return readNameMapFile(filename, "role")
+}
+
+func ReadTablespaceMapFile(filename string) (map[string]string, error) {
+	return readNameMapFile(filename, "tablespace")
+}
+
+func readNameMapFile(filename string, objectType string) (map[string]string, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	nameMap := make(map[string]string)
+	err = yaml.UnmarshalStrict(contents, &nameMap)
+	if err != nil {
+		return nil, errors.Errorf("Unable to parse %s map file %s: %v", objectType, filename, err)
+	}
+	if len(nameMap) == 0 {
+		return nil, errors.Errorf("The %s map file %s does not map any %ss", objectType, filename, objectType)
+	}
+	for oldName, newName := range nameMap {
+		for _, name := range []string{oldName, newName} {
+			if strings.TrimSpace(name) == "" {
+				return nil, errors.Errorf(`The %s map file %s maps "%s" to "%s".  Please ensure no name is empty.`, objectType, filename, oldName, newName)
+			}
+		}
+	}
+	return nameMap, nil
+}
@@ -0,0 +1,93 @@
+package utils_test
+
+import (
+	"os"
+	"path"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/remap tests", func() {
+	var filename string
+	writeFile := func(contents string) {
+		Expect(os.WriteFile(filename, []byte(contents), 0644)).To(Succeed())
+	}
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		filename = path.Join(GinkgoT().TempDir(), "remap.yaml")
+	})
+	Describe("ReadRemapFile", func() {
+		It("reads the schemas and tables to remap", func() {
+			writeFile(`schemas:
+  sales: sales_staging
+  '"Sales"': sales_upper
+tables:
+  public.customers: staging.customers_copy`)
+			remapConfig, err := utils.ReadRemapFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(remapConfig).To(Equal(utils.RemapConfig{
+				Schemas: map[string]string{"sales": "sales_staging", `"Sales"`: "sales_upper"},
+				Tables:  map[string]string{"public.customers": "staging.customers_copy"},
+			}))
+		})
+		It("returns an error for a file that remaps nothing", func() {
+			writeFile(`schemas: {}`)
+			_, err := utils.ReadRemapFile(filename)
+			Expect(err).To(MatchError("Remap file " + filename + " does not remap any schemas or tables"))
+		})
+		It("returns an error for a schema containing a dot", func() {
+			writeFile(`schemas:
+  sales: sales.staging`)
+			_, err := utils.ReadRemapFile(filename)
+			Expect(err).To(MatchError(ContainSubstring(`Schema "sales.staging" in remap file ` + filename + ` is invalid`)))
+		})
+		It("returns an error for a table that is not fully-qualified", func() {
+			writeFile(`tables:
+  public.customers: customers_copy`)
+			_, err := utils.ReadRemapFile(filename)
+			Expect(err).To(MatchError(ContainSubstring(`Table "customers_copy" is not correctly fully-qualified`)))
+		})
+		It("returns an error for an unknown section", func() {
+			writeFile(`views:
+  public.v: public.w`)
+			_, err := utils.ReadRemapFile(filename)
+			Expect(err).To(MatchError(ContainSubstring("Unable to parse remap file " + filename)))
+		})
+	})
+	Describe("ReadRoleMapFile", func() {
+		It("reads the roles to map", func() {
+			writeFile(`prod_owner: staging_owner
+'"Prod Admin"': staging_admin`)
+			roleMap, err := utils.ReadRoleMapFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(roleMap).To(Equal(map[string]string{"prod_owner": "staging_owner", `"Prod Admin"`: "staging_admin"}))
+		})
+		It("returns an error for a file that maps nothing", func() {
+			writeFile(``)
+			_, err := utils.ReadRoleMapFile(filename)
+			Expect(err).To(MatchError("The role map file " + filename + " does not map any roles"))
+		})
+		It("returns an error for a role mapped to an empty name", func() {
+			writeFile(`prod_owner: ''`)
+			_, err := utils.ReadRoleMapFile(filename)
+			Expect(err).To(MatchError(ContainSubstring(`The role map file ` + filename + ` maps "prod_owner" to ""`)))
+		})
+	})
+	Describe("ReadTablespaceMapFile", func() {
+		It("reads the tablespaces to map", func() {
+			writeFile(`fast_ssd: pg_default`)
+			tablespaceMap, err := utils.ReadTablespaceMapFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tablespaceMap).To(Equal(map[string]string{"fast_ssd": "pg_default"}))
+		})
+		It("returns an error for a file that is not a map of names", func() {
+			writeFile(`- fast_ssd`)
+			_, err := utils.ReadTablespaceMapFile(filename)
+			Expect(err).To(MatchError(ContainSubstring("Unable to parse tablespace map file " + filename)))
+		})
+	})
+})
@@ -0,0 +1,48 @@
+package utils
+
+/*
+ * This file contains functions for reading the row filters with which the
+ * data of individual tables is filtered during a backup.
+ */
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+/*
+ * The row filter file maps the fully-qualified names of tables to the WHERE
+ * clause predicates that the rows of their data must match to be backed up,
+ * for example:
+ *
+ *   public.customers: "region = 'EU'"
+ *   public.orders: "order_date >= '2020-01-01'"
+ */
+func ReadRowFilterFile(filename string) (map[string]string, error) {
+	contents, err := operating.System.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	rowFilters := make(map[string]string)
+	err = yaml.UnmarshalStrict(contents, &rowFilters)
+	if err != nil {
+		return nil, errors.Errorf("Unable to parse row filter file %s: %v", filename, err)
+	}
+	fqns := make([]string, 0, len(rowFilters))
+	for fqn, predicate := range rowFilters {
+		if strings.TrimSpace(predicate) == "" {
+			return nil, errors.Errorf("The row filter for table %s in row filter file %s is empty", fqn, filename)
+		}
+		fqns = append(fqns, fqn)
+	}
+	sort.Strings(fqns)
+	err = ValidateFQNs(fqns)
+	if err != nil {
+		return nil, err
+	}
+	return rowFilters, nil
+}
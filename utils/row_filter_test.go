@@ -0,0 +1,50 @@
+package utils_test
+
+import (
+	"os"
+	"path"
+
+	"github.com/cloudberrydb/gp-common-go-libs/operating"
+	"github.com/cloudberrydb/gpbackup/utils"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("utils/row_filter tests", func() {
+	var filename string
+	writeFile := func(contents string) {
+		Expect(os.WriteFile(filename, []byte(contents), 0644)).To(Succeed())
+	}
+	BeforeEach(func() {
+		operating.System = operating.InitializeSystemFunctions()
+		filename = path.Join(GinkgoT().TempDir(), "row_filters.yaml")
+	})
+	Describe("ReadRowFilterFile", func() {
+		It("reads the row filter of each table", func() {
+			writeFile(`public.customers: "region = 'EU'"
+sales.orders: order_date >= '2020-01-01'`)
+			rowFilters, err := utils.ReadRowFilterFile(filename)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rowFilters).To(Equal(map[string]string{
+				"public.customers": "region = 'EU'",
+				"sales.orders":     "order_date >= '2020-01-01'",
+			}))
+		})
+		It("returns an error for an empty row filter", func() {
+			writeFile(`public.customers: ""`)
+			_, err := utils.ReadRowFilterFile(filename)
+			Expect(err).To(MatchError("The row filter for table public.customers in row filter file " + filename + " is empty"))
+		})
+		It("returns an error for a table that is not fully-qualified", func() {
+			writeFile(`customers: "region = 'EU'"`)
+			_, err := utils.ReadRowFilterFile(filename)
+			Expect(err).To(MatchError(ContainSubstring(`Table "customers" is not correctly fully-qualified`)))
+		})
+		It("returns an error for a file that is not a map of tables to row filters", func() {
+			writeFile(`- public.customers`)
+			_, err := utils.ReadRowFilterFile(filename)
+			Expect(err).To(MatchError(ContainSubstring("Unable to parse row filter file " + filename)))
+		})
+	})
+})
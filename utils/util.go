@@ -27,6 +27,9 @@ import (
 const MINIMUM_GPDB4_VERSION = "1.1.0"
 const MINIMUM_GPDB5_VERSION = "1.1.0"
 
+// Appended to the name of a data file to get the file holding its checksum
+const ChecksumFileExtension = ".sha256"
+
 /*
  * General helper functions
  */
@@ -144,6 +147,8 @@ func ValidateCompressionTypeAndLevel(compressionType string, compressionLevel in
 	compressionLevelsForType := map[string]CompressionLevelsDescription{
 		"gzip": {Min: 1, Max: 9},
 		"zstd": {Min: 1, Max: 19},
+		"lz4":  {Min: 1, Max: 9},
+		"none": {Min: 1, Max: 1},
 	}
 
 	if levelsDescription, ok := compressionLevelsForType[compressionType]; ok {
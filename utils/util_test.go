@@ -140,6 +140,22 @@ var _ = Describe("utils/util tests", func() {
 			err := utils.ValidateCompressionTypeAndLevel(compressType, compressLevel)
 			Expect(err).To(MatchError("compression type 'zstd' only allows compression levels between 1 and 19, but the provided level is 20"))
 		})
+		It("validates a compression type 'lz4' and a level between 1 and 9", func() {
+			err := utils.ValidateCompressionTypeAndLevel("lz4", 9)
+			Expect(err).To(Not(HaveOccurred()))
+		})
+		It("panics if given a compression type 'lz4' and a compression level > 9", func() {
+			err := utils.ValidateCompressionTypeAndLevel("lz4", 12)
+			Expect(err).To(MatchError("compression type 'lz4' only allows compression levels between 1 and 9, but the provided level is 12"))
+		})
+		It("validates a compression type 'none' with the default level", func() {
+			err := utils.ValidateCompressionTypeAndLevel("none", 1)
+			Expect(err).To(Not(HaveOccurred()))
+		})
+		It("panics if given a compression type 'none' and any other compression level", func() {
+			err := utils.ValidateCompressionTypeAndLevel("none", 5)
+			Expect(err).To(MatchError("compression type 'none' only allows compression levels between 1 and 1, but the provided level is 5"))
+		})
 	})
 	Describe("UnquoteIdent", func() {
 		It("returns unchanged ident when passed a single char", func() {